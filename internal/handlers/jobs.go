@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// eventHeartbeatInterval keeps idle event streams open through proxies
+const eventHeartbeatInterval = 15 * time.Second
+
+// JobHandler handles HTTP requests for background optimization jobs
+type JobHandler struct {
+	service *services.JobService
+	logger  *slog.Logger
+}
+
+// NewJobHandler creates a new job handler instance
+func NewJobHandler(service *services.JobService, logger *slog.Logger) *JobHandler {
+	return &JobHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Router returns the routes for optimization jobs under /api/jobs
+func (h *JobHandler) Router() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/jobs", h.ListJobs).Methods(http.MethodGet)
+	router.HandleFunc("/api/jobs", h.CreateJob).Methods(http.MethodPost)
+	router.HandleFunc("/api/jobs/{id:[0-9]+}", h.GetJob).Methods(http.MethodGet)
+	router.HandleFunc("/api/jobs/{id:[0-9]+}/events", h.StreamJobEvents).Methods(http.MethodGet)
+	router.HandleFunc("/api/jobs/{id:[0-9]+}/cancel", h.CancelJob).Methods(http.MethodPost)
+	return router
+}
+
+// CreateJob handles POST /api/jobs
+func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.OptimizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.handleError(w, models.NewValidationError("invalid JSON in request body"))
+		return
+	}
+
+	job, err := h.service.Submit(&req, user.ID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusAccepted, models.OptimizationJobResponse{
+		Job:     job,
+		Message: "Optimization job queued",
+	})
+}
+
+// ListJobs handles GET /api/jobs
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := h.parseIntQuery(r, "limit", 50)
+	offset := h.parseIntQuery(r, "offset", 0)
+
+	jobs, total, err := h.service.GetJobs(user.ID, limit, offset)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.OptimizationJobResponse{
+		Jobs:  jobs,
+		Total: total,
+	})
+}
+
+// GetJob handles GET /api/jobs/{id}
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	job, err := h.service.GetJob(id, user.ID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.OptimizationJobResponse{Job: job})
+}
+
+// CancelJob handles POST /api/jobs/{id}/cancel
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	job, err := h.service.CancelJob(id, user.ID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	message := "Optimization job cancelled"
+	if job.Status == models.JobStatusRunning {
+		message = "Optimization job is stopping; the best layout found so far will be kept"
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.OptimizationJobResponse{
+		Job:     job,
+		Message: message,
+	})
+}
+
+// StreamJobEvents handles GET /api/jobs/{id}/events as Server-Sent Events.
+// A "progress" event carries the job whenever it changes and a final "done"
+// event carries it in its finished state, after which the stream ends.
+func (h *JobHandler) StreamJobEvents(w http.ResponseWriter, r *http.Request) {
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	job, updates, stop, err := h.service.WatchJob(id, user.ID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	send := func(event string, data interface{}) bool {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			h.logger.Error("Failed to encode job event", "error", err)
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	if job.IsFinished() {
+		send("done", job)
+		return
+	}
+	if !send("progress", job) {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || controller.Flush() != nil {
+				return
+			}
+		case update, ok := <-updates:
+			if !ok {
+				// The job has finished; send its stored final state
+				final, err := h.service.GetJob(id, user.ID)
+				if err != nil {
+					h.logger.Error("Failed to load finished job", "error", err, "id", id)
+					return
+				}
+				send("done", final)
+				return
+			}
+			if !send("progress", update) {
+				return
+			}
+		}
+	}
+}
+
+// Helper methods
+
+func (h *JobHandler) parseIDFromURL(r *http.Request) (int, error) {
+	idStr := mux.Vars(r)["id"]
+	if idStr == "" {
+		return 0, models.NewValidationError("ID is required")
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, models.NewValidationError("invalid ID format")
+	}
+
+	if id <= 0 {
+		return 0, models.NewValidationError("ID must be positive")
+	}
+
+	return id, nil
+}
+
+func (h *JobHandler) parseIntQuery(r *http.Request, param string, defaultValue int) int {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+
+	if parsed < 0 {
+		return defaultValue
+	}
+
+	return parsed
+}
+
+func (h *JobHandler) handleError(w http.ResponseWriter, err error) {
+	statusCode := models.GetHTTPStatusCode(err)
+	errorResponse := models.NewErrorResponse(err)
+
+	h.logger.Error("HTTP request failed",
+		"error", err.Error(),
+		"status", statusCode)
+
+	h.writeJSONResponse(w, statusCode, errorResponse)
+}
+
+func (h *JobHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", "error", err)
+	}
+}
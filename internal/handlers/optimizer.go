@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -26,10 +28,29 @@ func NewOptimizerHandler(service *services.OptimizerService, logger *slog.Logger
 	}
 }
 
+// Router returns the routes for stored optimizations under /api/optimizations/
+func (h *OptimizerHandler) Router() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/optimizations/compare", h.CompareOptimizations).Methods(http.MethodPost)
+	router.HandleFunc("/api/optimizations/batches/{batch_id:[0-9a-f]+}", h.GetOptimizationBatch).Methods(http.MethodGet)
+	router.HandleFunc("/api/optimizations/{id:[0-9]+}", h.GetOptimization).Methods(http.MethodGet)
+	router.HandleFunc("/api/optimizations/{id:[0-9]+}/export", h.ExportOptimization).Methods(http.MethodGet)
+	router.HandleFunc("/api/optimizations/{id:[0-9]+}/statistics", h.GetOptimizationStatistics).Methods(http.MethodGet)
+	router.HandleFunc("/api/optimizations/{id:[0-9]+}/rerun", h.RerunOptimization).Methods(http.MethodPost)
+	router.HandleFunc("/api/optimizations/{id:[0-9]+}/confirm", h.ConfirmOptimization).Methods(http.MethodPost)
+	router.HandleFunc("/api/optimizations/{id:[0-9]+}/validate", h.ValidateOptimization).Methods(http.MethodPost)
+	return router
+}
+
 // RunOptimization handles POST /api/optimize
 func (h *OptimizerHandler) RunOptimization(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("Handling run optimization request")
 
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	// Get user from context
 	user := services.GetUserFromContext(r.Context())
 	if user == nil {
@@ -44,17 +65,75 @@ func (h *OptimizerHandler) RunOptimization(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Run optimization
-	optimization, err := h.service.RunOptimization(&req, user.ID)
+	if req.SplitByGlass {
+		h.runOptimizationSet(w, r, &req, user.ID)
+		return
+	}
+
+	// Run optimization; a client disconnect cancels it through the request context
+	optimization, err := h.service.RunOptimization(r.Context(), &req, user.ID)
 	if err != nil {
 		h.handleError(w, err)
 		return
 	}
 
+	message := "Optimization completed successfully"
+	if optimization.Layout.Partial {
+		message = "Optimization stopped early; returning the best layout found"
+	}
+
 	// Return optimization result
 	h.writeJSONResponse(w, http.StatusCreated, models.OptimizationResponse{
 		Optimization: optimization,
-		Message:      "Optimization completed successfully",
+		Message:      message,
+	})
+}
+
+// runOptimizationSet runs a request split by glass type and returns the
+// linked optimizations
+func (h *OptimizerHandler) runOptimizationSet(w http.ResponseWriter, r *http.Request, req *models.OptimizationRequest, userID int64) {
+	results, err := h.service.RunOptimizationSet(r.Context(), req, userID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	optimizations := make([]models.Optimization, len(results))
+	message := fmt.Sprintf("%d optimizations completed successfully", len(results))
+	for i, optimization := range results {
+		optimizations[i] = *optimization
+		if optimization.Layout.Partial {
+			message = "Optimization stopped early; returning the best layouts found"
+		}
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, models.OptimizationResponse{
+		Optimizations: optimizations,
+		BatchID:       results[0].BatchID,
+		Total:         len(optimizations),
+		Message:       message,
+	})
+}
+
+// GetOptimizationBatch handles GET /api/optimizations/batches/{batch_id}
+func (h *OptimizerHandler) GetOptimizationBatch(w http.ResponseWriter, r *http.Request) {
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	batchID := mux.Vars(r)["batch_id"]
+	optimizations, err := h.service.GetOptimizationBatch(batchID, user.ID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.OptimizationResponse{
+		Optimizations: optimizations,
+		BatchID:       batchID,
+		Total:         len(optimizations),
 	})
 }
 
@@ -178,9 +257,8 @@ func (h *OptimizerHandler) ExportOptimization(w http.ResponseWriter, r *http.Req
 }
 
 // GetOptimizationStatistics handles GET /api/optimizations/{id}/statistics
-// GetOptimizerSettings handles GET /api/optimizer/settings
-func (h *OptimizerHandler) GetOptimizerSettings(w http.ResponseWriter, r *http.Request) {
-	h.logger.Debug("Handling get optimizer settings request")
+func (h *OptimizerHandler) GetOptimizationStatistics(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("Handling get optimization statistics request")
 
 	// Get user from context
 	user := services.GetUserFromContext(r.Context())
@@ -197,7 +275,7 @@ func (h *OptimizerHandler) GetOptimizerSettings(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Get optimization for settings context
+	// Get optimization
 	optimization, err := h.service.GetOptimization(id, user.ID)
 	if err != nil {
 		h.handleError(w, err)
@@ -216,7 +294,11 @@ func (h *OptimizerHandler) GetOptimizerSettings(w http.ResponseWriter, r *http.R
 		"total_pieces":        optimization.Layout.Statistics.TotalPieces,
 		"placed_pieces":       optimization.Layout.Statistics.PlacedPieces,
 		"unplaced_pieces":     optimization.Layout.Statistics.UnplacedPieces,
+		"largest_waste_area":  optimization.Layout.Statistics.LargestWasteArea,
+		"smallest_gap":        optimization.Layout.Statistics.SmallestGap,
 		"cutting_length":      optimization.Layout.Statistics.CuttingLength,
+		"travel_length":       optimization.Layout.Statistics.TravelLength,
+		"rapid_moves":         optimization.Layout.Statistics.RapidMoves,
 		"estimated_cut_time":  optimization.Layout.Statistics.CuttingTime,
 		"total_area":          optimization.TotalArea,
 		"used_area":           optimization.UsedArea,
@@ -279,12 +361,13 @@ func (h *OptimizerHandler) CompareOptimizations(w http.ResponseWriter, r *http.R
 		"best_by_utilization": nil,
 		"best_by_efficiency":  nil,
 		"fastest_algorithm":   nil,
+		"cheapest":            nil,
 	}
 
 	bestUtilization := 0.0
 	bestEfficiency := 0.0
 	fastestTime := float64(999999)
-	var bestUtilOpt, bestEffOpt, fastestOpt *models.Optimization
+	var bestUtilOpt, bestEffOpt, fastestOpt, cheapestOpt *models.Optimization
 
 	for i, opt := range optimizations {
 		optData := map[string]interface{}{
@@ -297,7 +380,12 @@ func (h *OptimizerHandler) CompareOptimizations(w http.ResponseWriter, r *http.R
 			"execution_time":      opt.ExecutionTime,
 			"placed_pieces":       opt.Layout.Statistics.PlacedPieces,
 			"total_pieces":        opt.Layout.Statistics.TotalPieces,
+			"unplaced_pieces":     opt.Layout.Statistics.UnplacedPieces,
+			"sheets_used":         opt.Layout.Statistics.SheetsUsed,
+			"largest_waste_area":  opt.Layout.Statistics.LargestWasteArea,
+			"smallest_gap":        opt.Layout.Statistics.SmallestGap,
 			"cutting_length":      opt.Layout.Statistics.CuttingLength,
+			"travel_length":       opt.Layout.Statistics.TravelLength,
 			"total_cost":          opt.TotalCost,
 		}
 
@@ -318,6 +406,11 @@ func (h *OptimizerHandler) CompareOptimizations(w http.ResponseWriter, r *http.R
 			fastestTime = opt.ExecutionTime
 			fastestOpt = opt
 		}
+
+		// Only layouts placing every piece compete on cost
+		if opt.Layout.Statistics.UnplacedPieces == 0 && (cheapestOpt == nil || opt.TotalCost < cheapestOpt.TotalCost) {
+			cheapestOpt = opt
+		}
 	}
 
 	// Set best performers
@@ -346,14 +439,21 @@ func (h *OptimizerHandler) CompareOptimizations(w http.ResponseWriter, r *http.R
 		}
 	}
 
+	if cheapestOpt != nil {
+		comparison["cheapest"] = map[string]interface{}{
+			"id":         cheapestOpt.ID,
+			"name":       cheapestOpt.Name,
+			"total_cost": cheapestOpt.TotalCost,
+		}
+	}
+
 	// Return comparison
 	h.writeJSONResponse(w, http.StatusOK, comparison)
 }
 
 // RerunOptimization handles POST /api/optimizations/{id}/rerun
-// AnalyzeOptimization handles POST /api/optimizations/{id}/analyze
-func (h *OptimizerHandler) AnalyzeOptimization(w http.ResponseWriter, r *http.Request) {
-	h.logger.Debug("Handling analyze optimization request")
+func (h *OptimizerHandler) RerunOptimization(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("Handling rerun optimization request")
 
 	// Get user from context
 	user := services.GetUserFromContext(r.Context())
@@ -379,36 +479,37 @@ func (h *OptimizerHandler) AnalyzeOptimization(w http.ResponseWriter, r *http.Re
 
 	// Parse optional new parameters
 	var req struct {
-		Name      string                  `json:"name,omitempty"`
-		Algorithm string                  `json:"algorithm,omitempty"`
-		Options   *models.OptimizeOptions `json:"options,omitempty"`
+		Name      string          `json:"name,omitempty"`
+		Algorithm string          `json:"algorithm,omitempty"`
+		Options   json.RawMessage `json:"options,omitempty"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		// Body is optional, continue with existing parameters
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		// An empty body reruns with the existing parameters
+		h.handleError(w, models.NewValidationError("invalid JSON in request body"))
+		return
 	}
 
-	// Create new optimization request based on existing one
-	optimizationReq := &models.OptimizationRequest{
-		Name:      optimization.Name + " (Rerun)",
-		SheetID:   optimization.SheetID,
-		Designs:   optimization.DesignList,
-		Algorithm: optimization.Algorithm,
-		Options:   models.OptimizeOptions{}, // Use default options
-	}
+	// Start from the request the optimization was run with, seed included,
+	// so that a rerun without overrides reproduces its layout
+	optimizationReq := h.service.RerunRequest(optimization)
 
-	// Override with new parameters if provided
+	// Override with new parameters if provided; options left out of the
+	// body keep the values the optimization was run with
 	if req.Name != "" {
 		optimizationReq.Name = req.Name
 	}
 	if req.Algorithm != "" {
 		optimizationReq.Algorithm = req.Algorithm
 	}
-	if req.Options != nil {
-		optimizationReq.Options = *req.Options
+	if len(req.Options) > 0 {
+		if err := json.Unmarshal(req.Options, &optimizationReq.Options); err != nil {
+			h.handleError(w, models.NewValidationError("invalid JSON in request body"))
+			return
+		}
 	}
 
 	// Run new optimization
-	newOptimization, err := h.service.RunOptimization(optimizationReq, user.ID)
+	newOptimization, err := h.service.RunOptimization(r.Context(), optimizationReq, user.ID)
 	if err != nil {
 		h.handleError(w, err)
 		return
@@ -417,10 +518,76 @@ func (h *OptimizerHandler) AnalyzeOptimization(w http.ResponseWriter, r *http.Re
 	// Return new optimization result
 	h.writeJSONResponse(w, http.StatusCreated, models.OptimizationResponse{
 		Optimization: newOptimization,
-		Message:      "Optimization analysis completed successfully",
+		Message:      "Optimization rerun completed successfully",
 	})
 }
 
+// ConfirmOptimization handles POST /api/optimizations/{id}/confirm
+func (h *OptimizerHandler) ConfirmOptimization(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("Handling confirm optimization request")
+
+	// Get user from context
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	// Consume the remnants the layout cuts from and release its offcuts
+	optimization, err := h.service.ConfirmOptimization(id, user.ID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.OptimizationResponse{
+		Optimization: optimization,
+		Message:      "Optimization confirmed for cutting",
+	})
+}
+
+// ValidateOptimization handles POST /api/optimizations/{id}/validate
+func (h *OptimizerHandler) ValidateOptimization(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("Handling validate optimization request")
+
+	// Get user from context
+	user := services.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	// Parse the optional spacing options the layout must meet; without them
+	// the layout is checked against the options it was run with
+	var req struct {
+		Options *models.OptimizeOptions `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.handleError(w, models.NewValidationError("invalid request body"))
+		return
+	}
+
+	validation, err := h.service.ValidateOptimization(id, user.ID, req.Options)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, validation)
+}
+
 // Helper methods
 
 func (h *OptimizerHandler) parseIDFromURL(r *http.Request) (int, error) {
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/storage"
+)
+
+// RemnantHandler serves the remnant stock stored in remnants
+type RemnantHandler struct {
+	storage storage.Storage
+	logger  *slog.Logger
+}
+
+// NewRemnantHandler creates a new remnant handler
+func NewRemnantHandler(storage storage.Storage, logger *slog.Logger) *RemnantHandler {
+	return &RemnantHandler{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// HandleRemnants handles GET (list) for /api/remnants
+func (h *RemnantHandler) HandleRemnants(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listRemnants(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleRemnantByID handles GET, DELETE for /api/remnants/:id
+func (h *RemnantHandler) HandleRemnantByID(w http.ResponseWriter, r *http.Request) {
+	id, err := h.parseIDFromPath(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getRemnant(w, id)
+	case http.MethodDelete:
+		h.deleteRemnant(w, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Private methods
+
+func (h *RemnantHandler) listRemnants(w http.ResponseWriter, r *http.Request) {
+	limit := h.parseIntQuery(r, "limit", 100)
+	offset := h.parseIntQuery(r, "offset", 0)
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+
+	if status != "" {
+		errors := &models.ValidationErrors{}
+		validStatuses := []string{models.RemnantStatusPending, models.RemnantStatusAvailable, models.RemnantStatusConsumed}
+		models.ValidateEnum(status, validStatuses, "status", errors)
+		if errors.HasErrors() {
+			h.handleError(w, errors)
+			return
+		}
+	}
+
+	remnants, total, err := h.storage.GetRemnants(status, limit, offset)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	if remnants == nil {
+		remnants = []models.Remnant{}
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.RemnantResponse{
+		Remnants: remnants,
+		Total:    total,
+	})
+}
+
+func (h *RemnantHandler) getRemnant(w http.ResponseWriter, id int) {
+	remnant, err := h.storage.GetRemnant(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.RemnantResponse{Remnant: remnant})
+}
+
+func (h *RemnantHandler) deleteRemnant(w http.ResponseWriter, id int) {
+	if err := h.storage.DeleteRemnant(id); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.RemnantResponse{
+		Message: "Remnant deleted successfully",
+	})
+}
+
+// Helper methods
+
+func (h *RemnantHandler) parseIDFromPath(r *http.Request) (int, error) {
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/remnants/"), "/")
+	if idStr == "" {
+		return 0, models.NewValidationError("ID is required")
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, models.NewValidationError("invalid ID format")
+	}
+
+	if id <= 0 {
+		return 0, models.NewValidationError("ID must be positive")
+	}
+
+	return id, nil
+}
+
+func (h *RemnantHandler) parseIntQuery(r *http.Request, param string, defaultValue int) int {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+
+	if parsed < 0 {
+		return defaultValue
+	}
+
+	return parsed
+}
+
+func (h *RemnantHandler) handleError(w http.ResponseWriter, err error) {
+	statusCode := models.GetHTTPStatusCode(err)
+	errorResponse := models.NewErrorResponse(err)
+
+	h.logger.Error("HTTP request failed",
+		"error", err.Error(),
+		"status", statusCode)
+
+	h.writeJSONResponse(w, statusCode, errorResponse)
+}
+
+func (h *RemnantHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", "error", err)
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/storage"
+)
+
+// SheetHandler serves the glass sheet catalogue stored in glass_sheets
+type SheetHandler struct {
+	storage storage.Storage
+	logger  *slog.Logger
+}
+
+// NewSheetHandler creates a new sheet handler
+func NewSheetHandler(storage storage.Storage, logger *slog.Logger) *SheetHandler {
+	return &SheetHandler{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// HandleSheets handles GET (list/search) and POST (create) for /api/sheets
+func (h *SheetHandler) HandleSheets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSheets(w, r)
+	case http.MethodPost:
+		h.createSheet(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSheetByID handles GET, PUT, DELETE for /api/sheets/:id
+func (h *SheetHandler) HandleSheetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := h.parseIDFromPath(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getSheet(w, id)
+	case http.MethodPut:
+		h.updateSheet(w, r, id)
+	case http.MethodDelete:
+		h.deleteSheet(w, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Private methods
+
+func (h *SheetHandler) listSheets(w http.ResponseWriter, r *http.Request) {
+	limit := h.parseIntQuery(r, "limit", 100)
+	offset := h.parseIntQuery(r, "offset", 0)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var sheets []models.GlassSheet
+	var total int
+	var err error
+
+	if query != "" {
+		sheets, total, err = h.storage.SearchGlassSheets(query, limit, offset)
+	} else {
+		sheets, total, err = h.storage.GetGlassSheets(limit, offset)
+	}
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	if sheets == nil {
+		sheets = []models.GlassSheet{}
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"sheets": sheets,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *SheetHandler) getSheet(w http.ResponseWriter, id int) {
+	sheet, err := h.storage.GetGlassSheet(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.GlassSheetResponse{Sheet: sheet})
+}
+
+func (h *SheetHandler) createSheet(w http.ResponseWriter, r *http.Request) {
+	sheet, err := h.decodeSheet(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	if err := h.storage.CreateGlassSheet(sheet); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusCreated, models.GlassSheetResponse{
+		Sheet:   sheet,
+		Message: "Glass sheet created successfully",
+	})
+}
+
+func (h *SheetHandler) updateSheet(w http.ResponseWriter, r *http.Request, id int) {
+	existing, err := h.storage.GetGlassSheet(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	sheet, err := h.decodeSheet(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	sheet.ID = id
+	sheet.CreatedAt = existing.CreatedAt
+
+	if err := h.storage.UpdateGlassSheet(sheet); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.GlassSheetResponse{
+		Sheet:   sheet,
+		Message: "Glass sheet updated successfully",
+	})
+}
+
+func (h *SheetHandler) deleteSheet(w http.ResponseWriter, id int) {
+	if err := h.storage.DeleteGlassSheet(id); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, models.GlassSheetResponse{
+		Message: "Glass sheet deleted successfully",
+	})
+}
+
+// decodeSheet reads a GlassSheetRequest body and validates the resulting sheet
+func (h *SheetHandler) decodeSheet(r *http.Request) (*models.GlassSheet, error) {
+	var req models.GlassSheetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, models.NewValidationError("invalid JSON in request body")
+	}
+
+	sheet := &models.GlassSheet{
+		Name:        strings.TrimSpace(req.Name),
+		Width:       req.Width,
+		Height:      req.Height,
+		Thickness:   req.Thickness,
+		PricePerSqm: req.PricePerSqm,
+		InStock:     req.InStock,
+		Material:    req.Material,
+		Supplier:    req.Supplier,
+		Grade:       req.Grade,
+		Specs:       req.Specs,
+		Defects:     req.Defects,
+	}
+
+	if err := sheet.Validate(); err != nil {
+		return nil, err
+	}
+
+	if sheet.InStock < 0 {
+		return nil, models.NewValidationError("in stock cannot be negative")
+	}
+
+	return sheet, nil
+}
+
+// Helper methods
+
+func (h *SheetHandler) parseIDFromPath(r *http.Request) (int, error) {
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sheets/"), "/")
+	if idStr == "" {
+		return 0, models.NewValidationError("ID is required")
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, models.NewValidationError("invalid ID format")
+	}
+
+	if id <= 0 {
+		return 0, models.NewValidationError("ID must be positive")
+	}
+
+	return id, nil
+}
+
+func (h *SheetHandler) parseIntQuery(r *http.Request, param string, defaultValue int) int {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+
+	if parsed < 0 {
+		return defaultValue
+	}
+
+	return parsed
+}
+
+func (h *SheetHandler) handleError(w http.ResponseWriter, err error) {
+	statusCode := models.GetHTTPStatusCode(err)
+	errorResponse := models.NewErrorResponse(err)
+
+	h.logger.Error("HTTP request failed",
+		"error", err.Error(),
+		"status", statusCode)
+
+	h.writeJSONResponse(w, statusCode, errorResponse)
+}
+
+func (h *SheetHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", "error", err)
+	}
+}
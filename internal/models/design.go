@@ -13,6 +13,7 @@ type Design struct {
 	Width       float64   `json:"width" db:"width"`                     // in millimeters
 	Height      float64   `json:"height" db:"height"`                   // in millimeters
 	Thickness   float64   `json:"thickness" db:"thickness"`             // in millimeters
+	Grain       Direction `json:"grain,omitempty" db:"grain"`           // Way the glass pattern must run in the piece; empty lets it turn
 	DesignData  string    `json:"-" db:"design_data"`                   // JSON blob
 	Elements    Elements  `json:"elements"`                             // Parsed design elements
 	UserID      int64     `json:"user_id" db:"user_id"`                 // Owner of the design
@@ -133,6 +134,17 @@ const (
 	CutCustom   CutType = "custom"
 )
 
+// Direction is the way a glass pattern or grain runs
+type Direction string
+
+const (
+	DirectionVertical   Direction = "vertical"   // Along the height
+	DirectionHorizontal Direction = "horizontal" // Along the width
+)
+
+// Directions lists the valid pattern and grain directions
+var Directions = []string{string(DirectionVertical), string(DirectionHorizontal)}
+
 // NoteType defines the type of annotation
 type NoteType string
 
@@ -147,12 +159,13 @@ const (
 
 // DesignRequest represents a request to create or update a design
 type DesignRequest struct {
-	Name        string   `json:"name" validate:"required,min=1,max=255"`
-	Description string   `json:"description" validate:"max=1000"`
-	Width       float64  `json:"width" validate:"required,gt=0,lte=10000"`
-	Height      float64  `json:"height" validate:"required,gt=0,lte=10000"`
-	Thickness   float64  `json:"thickness" validate:"required,gt=0,lte=50"`
-	Elements    Elements `json:"elements" validate:"required"`
+	Name        string    `json:"name" validate:"required,min=1,max=255"`
+	Description string    `json:"description" validate:"max=1000"`
+	Width       float64   `json:"width" validate:"required,gt=0,lte=10000"`
+	Height      float64   `json:"height" validate:"required,gt=0,lte=10000"`
+	Thickness   float64   `json:"thickness" validate:"required,gt=0,lte=50"`
+	Grain       Direction `json:"grain"`
+	Elements    Elements  `json:"elements" validate:"required"`
 }
 
 // DesignResponse represents the response structure for design API calls
@@ -143,6 +143,24 @@ func NewInternalError(message string, cause error) *AppError {
 	}
 }
 
+// ThicknessMismatchError creates an error for pieces of a different glass than the sheet
+func NewThicknessMismatchError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeValidation,
+		Code:    CodeThicknessMismatch,
+		Message: message,
+	}
+}
+
+// InsufficientStockError creates an error for glass that is not in stock
+func NewInsufficientStockError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeValidation,
+		Code:    CodeInsufficientStock,
+		Message: message,
+	}
+}
+
 // TimeoutError creates a new timeout error
 func NewTimeoutError(operation string) *AppError {
 	return &AppError{
@@ -2,60 +2,75 @@ package models
 
 import (
 	"encoding/json"
+	"math"
 	"time"
 )
 
 // GlassSheet represents a glass sheet available for cutting
 type GlassSheet struct {
-	ID          int        `json:"id" db:"id"`
-	Name        string     `json:"name" db:"name"`
-	Width       float64    `json:"width" db:"width"`         // in millimeters
-	Height      float64    `json:"height" db:"height"`       // in millimeters
-	Thickness   float64    `json:"thickness" db:"thickness"` // in millimeters
-	PricePerSqm float64    `json:"price_per_sqm" db:"price_per_sqm"`
-	InStock     int        `json:"in_stock" db:"in_stock"`
-	Material    string     `json:"material" db:"material"` // e.g., "tempered", "laminated", "standard"
-	Supplier    string     `json:"supplier" db:"supplier"`
-	Grade       string     `json:"grade" db:"grade"`  // quality grade
-	Properties  string     `json:"-" db:"properties"` // JSON blob for additional properties
-	Specs       GlassSpecs `json:"specs"`             // Parsed properties
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ID          int           `json:"id" db:"id"`
+	Name        string        `json:"name" db:"name"`
+	Width       float64       `json:"width" db:"width"`         // in millimeters
+	Height      float64       `json:"height" db:"height"`       // in millimeters
+	Thickness   float64       `json:"thickness" db:"thickness"` // in millimeters
+	PricePerSqm float64       `json:"price_per_sqm" db:"price_per_sqm"`
+	InStock     int           `json:"in_stock" db:"in_stock"`
+	Material    string        `json:"material" db:"material"` // e.g., "tempered", "laminated", "standard"
+	Supplier    string        `json:"supplier" db:"supplier"`
+	Grade       string        `json:"grade" db:"grade"`               // quality grade
+	Properties  string        `json:"-" db:"properties"`              // JSON blob for additional properties
+	Specs       GlassSpecs    `json:"specs"`                          // Parsed properties
+	Defects     []SheetDefect `json:"defects,omitempty" db:"defects"` // Marked flaws pieces must avoid
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+}
+
+// SheetDefect is a marked flaw in a sheet, such as a scratch, bubble or
+// inclusion, that no piece may cover
+type SheetDefect struct {
+	Type     string  `json:"type,omitempty"` // e.g., "scratch", "bubble", "inclusion"
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Instance int     `json:"instance,omitempty"` // Stock sheet of the size it is on, counting from 1; 0 marks every sheet
 }
 
 // GlassSpecs holds additional glass properties and specifications
 type GlassSpecs struct {
-	Tempered        bool     `json:"tempered"`
-	Laminated       bool     `json:"laminated"`
-	LowE            bool     `json:"low_e"` // Low-emissivity coating
-	Tinted          bool     `json:"tinted"`
-	TintColor       string   `json:"tint_color"`
-	UValue          float64  `json:"u_value"`        // Thermal transmittance
-	SHGCoefficient  float64  `json:"shgc"`           // Solar Heat Gain Coefficient
-	VisibleLight    float64  `json:"visible_light"`  // Visible light transmittance %
-	WeightPerSqm    float64  `json:"weight_per_sqm"` // kg per square meter
-	MaxDimension    float64  `json:"max_dimension"`  // Maximum manufacturable dimension
-	MinThickness    float64  `json:"min_thickness"`
-	MaxThickness    float64  `json:"max_thickness"`
-	EdgeWork        []string `json:"edge_work"`         // Available edge treatments
-	Drilling        bool     `json:"drilling"`          // Can be drilled
-	MaxHoleSize     float64  `json:"max_hole_size"`     // Maximum hole diameter
-	MinHoleDistance float64  `json:"min_hole_distance"` // Minimum distance between holes
-	LeadTime        int      `json:"lead_time"`         // Days
-	Notes           string   `json:"notes"`
+	Tempered        bool      `json:"tempered"`
+	Laminated       bool      `json:"laminated"`
+	LowE            bool      `json:"low_e"` // Low-emissivity coating
+	Tinted          bool      `json:"tinted"`
+	TintColor       string    `json:"tint_color"`
+	UValue          float64   `json:"u_value"`        // Thermal transmittance
+	SHGCoefficient  float64   `json:"shgc"`           // Solar Heat Gain Coefficient
+	VisibleLight    float64   `json:"visible_light"`  // Visible light transmittance %
+	WeightPerSqm    float64   `json:"weight_per_sqm"` // kg per square meter
+	MaxDimension    float64   `json:"max_dimension"`  // Maximum manufacturable dimension
+	MinThickness    float64   `json:"min_thickness"`
+	MaxThickness    float64   `json:"max_thickness"`
+	EdgeWork        []string  `json:"edge_work"`         // Available edge treatments
+	Drilling        bool      `json:"drilling"`          // Can be drilled
+	MaxHoleSize     float64   `json:"max_hole_size"`     // Maximum hole diameter
+	MinHoleDistance float64   `json:"min_hole_distance"` // Minimum distance between holes
+	LeadTime        int       `json:"lead_time"`         // Days
+	Notes           string    `json:"notes"`
+	Pattern         Direction `json:"pattern,omitempty"` // Way the pattern or grain of patterned glass runs on the sheet
 }
 
 // GlassSheetRequest represents a request to create or update a glass sheet
 type GlassSheetRequest struct {
-	Name        string     `json:"name" validate:"required,min=1,max=255"`
-	Width       float64    `json:"width" validate:"required,gt=0,lte=10000"`
-	Height      float64    `json:"height" validate:"required,gt=0,lte=10000"`
-	Thickness   float64    `json:"thickness" validate:"required,gt=0,lte=50"`
-	PricePerSqm float64    `json:"price_per_sqm" validate:"required,gte=0"`
-	InStock     int        `json:"in_stock" validate:"gte=0"`
-	Material    string     `json:"material" validate:"required"`
-	Supplier    string     `json:"supplier"`
-	Grade       string     `json:"grade"`
-	Specs       GlassSpecs `json:"specs"`
+	Name        string        `json:"name" validate:"required,min=1,max=255"`
+	Width       float64       `json:"width" validate:"required,gt=0,lte=10000"`
+	Height      float64       `json:"height" validate:"required,gt=0,lte=10000"`
+	Thickness   float64       `json:"thickness" validate:"required,gt=0,lte=50"`
+	PricePerSqm float64       `json:"price_per_sqm" validate:"required,gte=0"`
+	InStock     int           `json:"in_stock" validate:"gte=0"`
+	Material    string        `json:"material" validate:"required"`
+	Supplier    string        `json:"supplier"`
+	Grade       string        `json:"grade"`
+	Specs       GlassSpecs    `json:"specs"`
+	Defects     []SheetDefect `json:"defects"`
 }
 
 // GlassSheetResponse represents the response structure for glass sheet API calls
@@ -69,24 +84,32 @@ type GlassSheetResponse struct {
 
 // Optimization represents an optimization result
 type Optimization struct {
-	ID              int          `json:"id" db:"id"`
-	Name            string       `json:"name" db:"name"`
-	SheetID         int          `json:"sheet_id" db:"sheet_id"`
-	Sheet           *GlassSheet  `json:"sheet,omitempty"`
-	DesignIDs       string       `json:"-" db:"design_ids"`  // JSON array of design IDs with quantities
-	DesignList      []DesignItem `json:"designs"`            // Parsed design list
-	LayoutData      string       `json:"-" db:"layout_data"` // JSON blob
-	Layout          Layout       `json:"layout"`             // Parsed layout
-	WastePercentage float64      `json:"waste_percentage" db:"waste_percentage"`
-	TotalArea       float64      `json:"total_area" db:"total_area"`           // Sheet area in mm²
-	UsedArea        float64      `json:"used_area" db:"used_area"`             // Used area in mm²
-	WastedArea      float64      `json:"wasted_area"`                          // Calculated waste area
-	TotalCost       float64      `json:"total_cost"`                           // Total material cost
-	Algorithm       string       `json:"algorithm" db:"algorithm"`             // Algorithm used
-	ExecutionTime   float64      `json:"execution_time" db:"execution_time"`   // Time taken in seconds
-	UserID          int64        `json:"user_id" db:"user_id"`                 // Owner of the optimization
-	ProjectID       *int         `json:"project_id,omitempty" db:"project_id"` // Link to project
-	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	ID              int              `json:"id" db:"id"`
+	Name            string           `json:"name" db:"name"`
+	SheetID         int              `json:"sheet_id" db:"sheet_id"`
+	SheetIDsData    string           `json:"-" db:"sheet_ids"`                               // JSON array of candidate sheet IDs
+	SheetIDs        []int            `json:"sheet_ids,omitempty"`                            // Candidate sheet sizes the layout chose from
+	MatchingSheets  bool             `json:"matching_sheets,omitempty" db:"matching_sheets"` // Layout chose from every in-stock sheet of the same glass
+	Sheet           *GlassSheet      `json:"sheet,omitempty"`
+	DesignIDs       string           `json:"-" db:"design_ids"`  // JSON array of design IDs with quantities
+	DesignList      []DesignItem     `json:"designs"`            // Parsed design list
+	LayoutData      string           `json:"-" db:"layout_data"` // JSON blob
+	Layout          Layout           `json:"layout"`             // Parsed layout
+	WastePercentage float64          `json:"waste_percentage" db:"waste_percentage"`
+	TotalArea       float64          `json:"total_area" db:"total_area"`               // Sheet area in mm²
+	UsedArea        float64          `json:"used_area" db:"used_area"`                 // Used area in mm²
+	WastedArea      float64          `json:"wasted_area"`                              // Calculated waste area
+	TotalCost       float64          `json:"total_cost"`                               // Total material cost
+	Algorithm       string           `json:"algorithm" db:"algorithm"`                 // Algorithm used
+	Seed            int64            `json:"seed" db:"seed"`                           // Random seed the layout was produced with
+	OptionsData     string           `json:"-" db:"options"`                           // JSON blob
+	Options         *OptimizeOptions `json:"options,omitempty"`                        // Options the layout was produced with, defaults filled in; nil for older layouts
+	ExecutionTime   float64          `json:"execution_time" db:"execution_time"`       // Time taken in seconds
+	UserID          int64            `json:"user_id" db:"user_id"`                     // Owner of the optimization
+	ProjectID       *int             `json:"project_id,omitempty" db:"project_id"`     // Link to project
+	ConfirmedAt     *time.Time       `json:"confirmed_at,omitempty" db:"confirmed_at"` // Set once the layout is released for cutting
+	BatchID         string           `json:"batch_id,omitempty" db:"batch_id"`         // Shared by the optimizations of a request split by glass type
+	CreatedAt       time.Time        `json:"created_at" db:"created_at"`
 }
 
 // DesignItem represents a design with quantity for optimization
@@ -94,35 +117,134 @@ type DesignItem struct {
 	DesignID int     `json:"design_id"`
 	Design   *Design `json:"design,omitempty"`
 	Quantity int     `json:"quantity"`
-	Priority int     `json:"priority"` // Higher priority pieces are placed first
+	Priority int     `json:"priority"`           // Higher priority pieces are placed first
+	DueDate  string  `json:"due_date,omitempty"` // Date the pieces are needed by, as YYYY-MM-DD; earlier dates go first
+	Order    string  `json:"order,omitempty"`    // Customer order the pieces belong to
+	Material string  `json:"material,omitempty"` // Glass the piece is cut from; defaults to the sheet's material
 	// Fields for custom pieces (when DesignID = 0)
-	Width  float64 `json:"width,omitempty"`
-	Height float64 `json:"height,omitempty"`
-	Name   string  `json:"name,omitempty"`
+	Grain     Direction `json:"grain,omitempty"` // Way the glass pattern must run in the piece
+	Width     float64   `json:"width,omitempty"`
+	Height    float64   `json:"height,omitempty"`
+	Thickness float64   `json:"thickness,omitempty"` // Defaults to the sheet's thickness
+	Name      string    `json:"name,omitempty"`
 }
 
 // Layout represents the optimized layout of pieces on a sheet
 type Layout struct {
-	SheetWidth  float64       `json:"sheet_width"`
-	SheetHeight float64       `json:"sheet_height"`
-	Pieces      []PlacedPiece `json:"pieces"`
-	CutPaths    []CutPath     `json:"cut_paths"`
-	Statistics  Statistics    `json:"statistics"`
+	SheetWidth  float64         `json:"sheet_width"`
+	SheetHeight float64         `json:"sheet_height"`
+	Pieces      []PlacedPiece   `json:"pieces"`    // Pieces on the first sheet
+	CutPaths    []CutPath       `json:"cut_paths"` // Cut paths on the first sheet
+	Sheets      []SheetLayout   `json:"sheets"`    // Every sheet opened by the optimizer
+	Statistics  Statistics      `json:"statistics"`
+	Partial     bool            `json:"partial"`              // Run was cancelled or timed out; best layout found so far
+	Scoreboard  []StrategyScore `json:"scoreboard,omitempty"` // Strategies tried by the auto algorithm
+	Violations  []Violation     `json:"violations,omitempty"` // Ways the layout cannot be cut as laid out
+}
+
+// Kinds of layout violations
+const (
+	ViolationOutOfBounds = "out_of_bounds" // Piece extends past the sheet
+	ViolationEdgeMargin  = "edge_margin"   // Piece lies in the edge margin or trim
+	ViolationOverlap     = "overlap"       // Pieces overlap
+	ViolationGap         = "gap"           // Pieces are closer than the minimum gap
+	ViolationDefect      = "defect"        // Piece is closer to a defect than the minimum gap
+	ViolationUncut       = "uncut"         // Part of a piece edge has no cut path
+)
+
+// Violation is a way a layout cannot be cut as laid out
+type Violation struct {
+	Type     string  `json:"type"`               // One of the Violation kinds
+	Sheet    int     `json:"sheet"`              // Sheet number
+	PieceID  string  `json:"piece_id"`           // Piece at fault
+	OtherID  string  `json:"other_id,omitempty"` // Second piece of an overlap or gap
+	Distance float64 `json:"distance,omitempty"` // Clearance found, overshoot past the sheet or margin, or uncut edge length (mm)
+	Required float64 `json:"required,omitempty"` // Clearance needed (mm)
+	Message  string  `json:"message"`
+}
+
+// LayoutValidation is the result of validating a layout
+type LayoutValidation struct {
+	Valid      bool        `json:"valid"`
+	Violations []Violation `json:"violations"`
+}
+
+// StrategyScore is the result of one strategy tried by the auto algorithm
+type StrategyScore struct {
+	Strategy        string  `json:"strategy"` // Algorithm and piece order, such as "maxrects-bssf/area"
+	Algorithm       string  `json:"algorithm"`
+	SortBy          string  `json:"sort_by,omitempty"`
+	SheetsUsed      int     `json:"sheets_used"`
+	PiecesPlaced    int     `json:"pieces_placed"`
+	UtilizationRate float64 `json:"utilization_rate"`  // Percentage of the sheets used
+	Cost            float64 `json:"cost"`              // Material cost of the sheets used
+	ExecutionTime   float64 `json:"execution_time"`    // Seconds
+	Partial         bool    `json:"partial,omitempty"` // The time limit ran out before it finished
+	Skipped         bool    `json:"skipped,omitempty"` // Not started before the time limit ran out
+	Winner          bool    `json:"winner,omitempty"`  // Produced the layout returned
+}
+
+// SheetLayout represents the pieces and cuts placed on a single sheet
+type SheetLayout struct {
+	SheetNumber     int           `json:"sheet_number"`       // 1-based position in the cutting order
+	SheetID         int           `json:"sheet_id,omitempty"` // Catalogue sheet the glass comes from
+	Width           float64       `json:"width"`
+	Height          float64       `json:"height"`
+	Pieces          []PlacedPiece `json:"pieces"`
+	CutPaths        []CutPath     `json:"cut_paths"`
+	UsedArea        float64       `json:"used_area"`                  // Area covered by pieces in mm²
+	UtilizationRate float64       `json:"utilization_rate"`           // Percentage of the sheet used
+	CutTree         *CutNode      `json:"cut_tree,omitempty"`         // Set by guillotine packing
+	RemnantID       int           `json:"remnant_id,omitempty"`       // Set when cut from a remnant instead of a full sheet
+	Instance        int           `json:"instance,omitempty"`         // Stock sheet of its size to cut, counting from 1; set when defects mark single sheets of the size
+	Cost            float64       `json:"cost"`                       // Material cost of the sheet; remnants are free
+	Offcuts         []Offcut      `json:"offcuts,omitempty"`          // Leftovers large enough to keep as remnants
+	Defects         []SheetDefect `json:"defects,omitempty"`          // Flaws of the sheet the pieces were placed around
+	CompletedOrders []string      `json:"completed_orders,omitempty"` // Orders whose last pieces are cut from this sheet
+}
+
+// Offcut is a rectangular leftover of a sheet that is kept as a remnant
+type Offcut struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// CutNode is a panel in a guillotine cut tree. The root is the usable area of
+// the sheet; the children of a node are the panels produced by cutting it edge
+// to edge in Direction at the next stage (1 = X, 2 = Y, 3 = Z, 4 = W).
+type CutNode struct {
+	Stage     int       `json:"stage"`
+	X         float64   `json:"x"`
+	Y         float64   `json:"y"`
+	Width     float64   `json:"width"`
+	Height    float64   `json:"height"`
+	Direction string    `json:"direction,omitempty"` // "vertical" or "horizontal" cuts between children
+	PieceID   string    `json:"piece_id,omitempty"`  // Set on leaves holding a finished piece
+	Children  []CutNode `json:"children,omitempty"`
 }
 
 // PlacedPiece represents a design piece placed on the sheet
 type PlacedPiece struct {
-	ID         string  `json:"id"` // Unique placement ID
-	DesignID   int     `json:"design_id"`
-	DesignName string  `json:"design_name"`
-	X          float64 `json:"x"`         // Position X coordinate
-	Y          float64 `json:"y"`         // Position Y coordinate
-	Width      float64 `json:"width"`     // Actual width (may be rotated)
-	Height     float64 `json:"height"`    // Actual height (may be rotated)
-	Rotation   int     `json:"rotation"`  // Rotation angle: 0, 90, 180, 270
-	Flipped    bool    `json:"flipped"`   // Whether the piece is flipped
-	Nested     bool    `json:"nested"`    // Whether this piece is nested within another
-	ParentID   string  `json:"parent_id"` // ID of parent piece if nested
+	ID           string    `json:"id"` // Unique placement ID
+	DesignID     int       `json:"design_id"`
+	DesignName   string    `json:"design_name"`
+	X            float64   `json:"x"`                       // Position X coordinate
+	Y            float64   `json:"y"`                       // Position Y coordinate
+	Width        float64   `json:"width"`                   // Actual width (may be rotated)
+	Height       float64   `json:"height"`                  // Actual height (may be rotated)
+	Rotation     int       `json:"rotation"`                // Rotation angle in degrees: 0, 90, 180, 270, or any step when nesting true shapes
+	Flipped      bool      `json:"flipped"`                 // Whether the piece is flipped
+	Nested       bool      `json:"nested"`                  // Whether this piece is nested within another
+	ParentID     string    `json:"parent_id"`               // ID of parent piece if nested
+	Sheet        int       `json:"sheet"`                   // Sheet number the piece is placed on
+	Outline      []Point   `json:"outline,omitempty"`       // True outline in sheet coordinates for non-rectangular pieces
+	NetWidth     float64   `json:"net_width,omitempty"`     // Finished width once the grinding allowance is ground off
+	NetHeight    float64   `json:"net_height,omitempty"`    // Finished height once the grinding allowance is ground off
+	EdgeDeletion float64   `json:"edge_deletion,omitempty"` // Width of low-E coating to remove along each edge (mm)
+	Pattern      Direction `json:"pattern,omitempty"`       // Way the sheet's pattern runs in the piece as designed
+	Order        string    `json:"order,omitempty"`         // Customer order the piece belongs to
 }
 
 // CutPath represents the optimal cutting path for the sheet
@@ -133,10 +255,11 @@ type CutPath struct {
 	StartY   float64  `json:"start_y"`
 	EndX     float64  `json:"end_x"`
 	EndY     float64  `json:"end_y"`
-	Order    int      `json:"order"`     // Cutting order
-	ToolType string   `json:"tool_type"` // "straight", "diamond", "water_jet"
-	Speed    float64  `json:"speed"`     // Cutting speed
-	Pieces   []string `json:"pieces"`    // IDs of pieces this cut affects
+	Order    int      `json:"order"`           // Cutting order
+	Stage    int      `json:"stage,omitempty"` // Guillotine stage of the cut
+	ToolType string   `json:"tool_type"`       // "straight", "diamond", "water_jet"
+	Speed    float64  `json:"speed"`           // Cutting speed
+	Pieces   []string `json:"pieces"`          // IDs of pieces this cut affects
 }
 
 // Statistics holds optimization statistics
@@ -144,46 +267,77 @@ type Statistics struct {
 	TotalPieces        int     `json:"total_pieces"`
 	PlacedPieces       int     `json:"placed_pieces"`
 	UnplacedPieces     int     `json:"unplaced_pieces"`
+	SheetsUsed         int     `json:"sheets_used"`
 	UtilizationRate    float64 `json:"utilization_rate"`    // Percentage of sheet used
 	WasteRate          float64 `json:"waste_rate"`          // Percentage wasted
 	MaterialEfficiency float64 `json:"material_efficiency"` // Overall efficiency score
 	CuttingLength      float64 `json:"cutting_length"`      // Total cutting path length
-	CuttingTime        float64 `json:"cutting_time"`        // Estimated cutting time
-	LargestWasteArea   float64 `json:"largest_waste_area"`  // Largest continuous waste area
-	SmallestGap        float64 `json:"smallest_gap"`        // Smallest gap between pieces
+	TravelLength       float64 `json:"travel_length"`       // Distance the cutting head moves between cuts (mm)
+	RapidMoves         int     `json:"rapid_moves"`         // Moves of the cutting head between cuts
+	CuttingTime        float64 `json:"cutting_time"`        // Estimated cutting time, travel included
+	LargestWasteArea   float64 `json:"largest_waste_area"`  // Largest free rectangle left on a sheet, past the cut around the pieces (mm²)
+	SmallestGap        float64 `json:"smallest_gap"`        // Smallest distance between two pieces on a sheet (mm), 0 when no sheet holds two
+	SheetLowerBound    int     `json:"sheet_lower_bound"`   // Fewest sheets the pieces can fit on, 0 when unknown
+	SheetGap           int     `json:"sheet_gap"`           // Sheets used above SheetLowerBound
+	Optimal            bool    `json:"optimal"`             // SheetsUsed is proven to be the fewest possible
 }
 
 // OptimizationRequest represents a request to run optimization
 type OptimizationRequest struct {
-	Name      string          `json:"name" validate:"required,min=1,max=255"`
-	SheetID   int             `json:"sheet_id" validate:"required,gt=0"`
-	Designs   []DesignItem    `json:"designs" validate:"required,min=1"`
-	Algorithm string          `json:"algorithm" validate:"required,oneof=blf genetic greedy custom"`
-	Options   OptimizeOptions `json:"options"`
+	Name           string          `json:"name" validate:"required,min=1,max=255"`
+	SheetID        int             `json:"sheet_id" validate:"required_without=SheetIDs,gte=0"`
+	SheetIDs       []int           `json:"sheet_ids,omitempty"`       // Candidate sheet sizes of the same glass to choose from
+	MatchingSheets bool            `json:"matching_sheets,omitempty"` // Choose from every in-stock sheet of the same glass as SheetID
+	SplitByGlass   bool            `json:"split_by_glass,omitempty"`  // Run one linked optimization per glass type in Designs
+	Designs        []DesignItem    `json:"designs" validate:"required,min=1"`
+	Algorithm      string          `json:"algorithm" validate:"required,oneof=blf genetic greedy guillotine maxrects-bssf maxrects-blsf maxrects-baf maxrects-cp skyline nfp exact auto"`
+	Options        OptimizeOptions `json:"options"`
 }
 
 // OptimizeOptions holds optimization parameters
 type OptimizeOptions struct {
-	AllowRotation     bool    `json:"allow_rotation"`     // Allow 90° rotations
-	AllowFlipping     bool    `json:"allow_flipping"`     // Allow mirroring pieces
-	MinimumGap        float64 `json:"minimum_gap"`        // Minimum gap between pieces (mm)
-	EdgeMargin        float64 `json:"edge_margin"`        // Margin from sheet edges (mm)
-	MaxIterations     int     `json:"max_iterations"`     // For genetic algorithm
-	PopulationSize    int     `json:"population_size"`    // For genetic algorithm
-	MutationRate      float64 `json:"mutation_rate"`      // For genetic algorithm
-	CrossoverRate     float64 `json:"crossover_rate"`     // For genetic algorithm
-	TimeLimit         int     `json:"time_limit"`         // Maximum optimization time (seconds)
-	QualityTarget     float64 `json:"quality_target"`     // Target utilization rate (0-1)
-	PreferredRotation int     `json:"preferred_rotation"` // Preferred rotation angle
-	SortBy            string  `json:"sort_by"`            // "area", "perimeter", "ratio", "priority"
-	SortOrder         string  `json:"sort_order"`         // "asc", "desc"
-	EnableNesting     bool    `json:"enable_nesting"`     // Allow pieces inside holes of others
+	AllowRotation     bool     `json:"allow_rotation"`     // Allow 90° rotations, or RotationStep rotations when nesting
+	AllowFlipping     bool     `json:"allow_flipping"`     // Allow mirroring pieces
+	MinimumGap        float64  `json:"minimum_gap"`        // Minimum gap between pieces (mm)
+	EdgeMargin        float64  `json:"edge_margin"`        // Margin from sheet edges (mm)
+	MaxIterations     int      `json:"max_iterations"`     // For genetic algorithm
+	PopulationSize    int      `json:"population_size"`    // For genetic algorithm
+	MutationRate      float64  `json:"mutation_rate"`      // For genetic algorithm
+	CrossoverRate     float64  `json:"crossover_rate"`     // For genetic algorithm
+	TimeLimit         int      `json:"time_limit"`         // Maximum optimization time (seconds)
+	QualityTarget     float64  `json:"quality_target"`     // Target utilization rate (0-1)
+	PreferredRotation int      `json:"preferred_rotation"` // Preferred rotation angle
+	SortBy            string   `json:"sort_by"`            // "area", "perimeter", "ratio", "max_side", "width", "height", "priority", keys joined by commas, or "all"
+	SortOrder         string   `json:"sort_order"`         // "asc", "desc"
+	EnableNesting     bool     `json:"enable_nesting"`     // Allow pieces inside holes of others
+	MaxCutStages      int      `json:"max_cut_stages"`     // Guillotine stages (X/Y/Z/W), 1-4
+	Seed              int64    `json:"seed"`               // Random seed; 0 picks one, reuse it to reproduce a layout
+	MinRemnantSize    float64  `json:"min_remnant_size"`   // Shortest side of an offcut worth keeping (mm)
+	IgnoreRemnants    bool     `json:"ignore_remnants"`    // Cut from full sheets only
+	RotationStep      int      `json:"rotation_step"`      // Rotation increment for true-shape nesting (degrees, default 90)
+	Kerf              float64  `json:"kerf"`               // Width of the score line, added to the gap between pieces (mm)
+	Trim              EdgeTrim `json:"trim"`               // Factory edge cut off each side of the sheet
+	GrindingAllowance float64  `json:"grinding_allowance"` // Added to every edge of a piece for edge work (mm)
+	EdgeDeletion      float64  `json:"edge_deletion"`      // Low-E coating removed along every edge of a piece (mm)
+	Improve           bool     `json:"improve"`            // Improve the layout by local search once it is built
+	ImproveIterations int      `json:"improve_iterations"` // Moves tried by the improvement pass (default 200)
+	ImproveTimeLimit  int      `json:"improve_time_limit"` // Maximum improvement time (seconds); time_limit still applies
+}
+
+// EdgeTrim is the width of the factory edge cut off each side of a sheet. A
+// side left at 0 keeps the edge margin. Bottom is the side at Y = 0.
+type EdgeTrim struct {
+	Left   float64 `json:"left"`
+	Right  float64 `json:"right"`
+	Top    float64 `json:"top"`
+	Bottom float64 `json:"bottom"`
 }
 
 // OptimizationResponse represents the response structure for optimization API calls
 type OptimizationResponse struct {
 	Optimization  *Optimization  `json:"optimization,omitempty"`
 	Optimizations []Optimization `json:"optimizations,omitempty"`
+	BatchID       string         `json:"batch_id,omitempty"`
 	Total         int            `json:"total,omitempty"`
 	Message       string         `json:"message,omitempty"`
 	Error         string         `json:"error,omitempty"`
@@ -206,6 +360,20 @@ func (gs *GlassSheet) Validate() error {
 	if gs.PricePerSqm < 0 {
 		return NewValidationError("price per square meter cannot be negative")
 	}
+	for _, defect := range gs.Defects {
+		if defect.Width <= 0 || defect.Height <= 0 {
+			return NewValidationError("defect width and height must be greater than 0")
+		}
+		if defect.X < 0 || defect.Y < 0 || defect.X+defect.Width > gs.Width || defect.Y+defect.Height > gs.Height {
+			return NewValidationError("defects must lie within the sheet")
+		}
+		if defect.Instance < 0 {
+			return NewValidationError("defect instance cannot be negative")
+		}
+	}
+	if gs.Specs.Pattern != "" && gs.Specs.Pattern != DirectionVertical && gs.Specs.Pattern != DirectionHorizontal {
+		return NewValidationError("pattern must be vertical or horizontal")
+	}
 	return nil
 }
 
@@ -295,6 +463,54 @@ func (opt *Optimization) UnmarshalLayoutData() error {
 	return json.Unmarshal([]byte(opt.LayoutData), &opt.Layout)
 }
 
+// MarshalOptions serializes the Options to JSON for database storage
+func (opt *Optimization) MarshalOptions() error {
+	if opt.Options == nil {
+		opt.OptionsData = ""
+		return nil
+	}
+	data, err := json.Marshal(opt.Options)
+	if err != nil {
+		return err
+	}
+	opt.OptionsData = string(data)
+	return nil
+}
+
+// UnmarshalOptions deserializes the JSON OptionsData to Options. Layouts
+// saved before options were stored have none.
+func (opt *Optimization) UnmarshalOptions() error {
+	if opt.OptionsData == "" {
+		opt.Options = nil
+		return nil
+	}
+	opt.Options = &OptimizeOptions{}
+	return json.Unmarshal([]byte(opt.OptionsData), opt.Options)
+}
+
+// MarshalSheetIDs serializes the candidate SheetIDs to JSON for database storage
+func (opt *Optimization) MarshalSheetIDs() error {
+	if len(opt.SheetIDs) == 0 {
+		opt.SheetIDsData = ""
+		return nil
+	}
+	data, err := json.Marshal(opt.SheetIDs)
+	if err != nil {
+		return err
+	}
+	opt.SheetIDsData = string(data)
+	return nil
+}
+
+// UnmarshalSheetIDs deserializes the JSON SheetIDsData to SheetIDs
+func (opt *Optimization) UnmarshalSheetIDs() error {
+	if opt.SheetIDsData == "" {
+		opt.SheetIDs = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(opt.SheetIDsData), &opt.SheetIDs)
+}
+
 // CalculateStatistics calculates and updates optimization statistics
 func (opt *Optimization) CalculateStatistics() {
 	if opt.Sheet == nil {
@@ -302,22 +518,115 @@ func (opt *Optimization) CalculateStatistics() {
 	}
 
 	totalArea := opt.Sheet.Area()
+	if !opt.Layout.SingleSheet() {
+		totalArea = opt.Layout.SheetArea()
+	}
 	opt.TotalArea = totalArea
 	opt.WastedArea = totalArea - opt.UsedArea
-	opt.WastePercentage = (opt.WastedArea / totalArea) * 100
+	opt.WastePercentage = 0
+	if totalArea > 0 {
+		opt.WastePercentage = (opt.WastedArea / totalArea) * 100
+	}
 
 	// Calculate layout statistics
 	stats := &opt.Layout.Statistics
-	stats.TotalPieces = len(opt.DesignList)
-	stats.PlacedPieces = len(opt.Layout.Pieces)
-	stats.UnplacedPieces = stats.TotalPieces - stats.PlacedPieces
-	stats.UtilizationRate = (opt.UsedArea / totalArea) * 100
+	stats.TotalPieces = 0
+	for _, item := range opt.DesignList {
+		stats.TotalPieces += item.Quantity
+	}
+	stats.PlacedPieces = len(opt.Layout.AllPieces())
+	stats.UnplacedPieces = max(stats.TotalPieces-stats.PlacedPieces, 0)
+	stats.SheetsUsed = opt.Layout.SheetCount()
+	stats.UtilizationRate = 0
+	if totalArea > 0 {
+		stats.UtilizationRate = (opt.UsedArea / totalArea) * 100
+	}
 	stats.WasteRate = opt.WastePercentage
 	stats.MaterialEfficiency = calculateMaterialEfficiency(opt)
+	opt.TotalCost = opt.Layout.MaterialCost(opt.Sheet)
 
 	// Calculate cutting statistics
-	stats.CuttingLength = calculateCuttingLength(opt.Layout.CutPaths)
-	stats.CuttingTime = estimateCuttingTime(opt.Layout.CutPaths)
+	stats.CuttingLength = calculateCuttingLength(opt.Layout.AllCutPaths())
+	stats.TravelLength, stats.RapidMoves = calculateTravel(&opt.Layout)
+	stats.CuttingTime = estimateCuttingTime(opt.Layout.AllCutPaths()) +
+		stats.TravelLength/rapidSpeed + float64(stats.RapidMoves)*rapidMoveTime
+
+	// The optimizer sets the lower bound when it knows one
+	if stats.SheetLowerBound > 0 {
+		stats.SheetGap = stats.SheetsUsed - stats.SheetLowerBound
+		stats.Optimal = stats.SheetGap == 0
+	}
+}
+
+// SingleSheet reports whether the layout was saved before multi-sheet
+// support, with its one sheet in Pieces and CutPaths. Layouts built since
+// always have Sheets, empty when nothing could be placed.
+func (l *Layout) SingleSheet() bool {
+	return l.Sheets == nil
+}
+
+// SheetCount returns the number of sheets used by the layout
+func (l *Layout) SheetCount() int {
+	if l.SingleSheet() {
+		return 1
+	}
+	return len(l.Sheets)
+}
+
+// SheetArea returns the combined area of the sheets and remnants used by the layout
+func (l *Layout) SheetArea() float64 {
+	area := 0.0
+	for _, sheet := range l.Sheets {
+		area += sheet.Width * sheet.Height
+	}
+	return area
+}
+
+// MaterialCost returns the cost of the glass the layout is cut from. Remnants
+// are free, having been paid for by the job that left them; sheets saved
+// before costs were recorded are priced as the given sheet.
+func (l *Layout) MaterialCost(sheet *GlassSheet) float64 {
+	if l.SingleSheet() {
+		return sheet.TotalCost()
+	}
+
+	cost := 0.0
+	for _, sheetLayout := range l.Sheets {
+		switch {
+		case sheetLayout.RemnantID != 0:
+		case sheetLayout.Cost > 0:
+			cost += sheetLayout.Cost
+		default:
+			cost += sheet.TotalCost()
+		}
+	}
+	return cost
+}
+
+// AllPieces returns the pieces placed on every sheet of the layout
+func (l *Layout) AllPieces() []PlacedPiece {
+	if l.SingleSheet() {
+		return l.Pieces
+	}
+
+	var pieces []PlacedPiece
+	for _, sheet := range l.Sheets {
+		pieces = append(pieces, sheet.Pieces...)
+	}
+	return pieces
+}
+
+// AllCutPaths returns the cut paths of every sheet of the layout
+func (l *Layout) AllCutPaths() []CutPath {
+	if l.SingleSheet() {
+		return l.CutPaths
+	}
+
+	var paths []CutPath
+	for _, sheet := range l.Sheets {
+		paths = append(paths, sheet.CutPaths...)
+	}
+	return paths
 }
 
 // GetTotalPieceArea calculates the total area of all pieces to be placed
@@ -347,11 +656,10 @@ func (opt *Optimization) GetTheoreticalUtilization() float64 {
 // calculateMaterialEfficiency calculates overall material efficiency
 func calculateMaterialEfficiency(opt *Optimization) float64 {
 	theoretical := opt.GetTheoreticalUtilization()
-	actual := (opt.UsedArea / opt.TotalArea) * 100
-
-	if theoretical == 0 {
+	if theoretical == 0 || opt.TotalArea == 0 {
 		return 0
 	}
+	actual := (opt.UsedArea / opt.TotalArea) * 100
 
 	return (actual / theoretical) * 100
 }
@@ -360,21 +668,49 @@ func calculateMaterialEfficiency(opt *Optimization) float64 {
 func calculateCuttingLength(paths []CutPath) float64 {
 	totalLength := 0.0
 	for _, path := range paths {
-		dx := path.EndX - path.StartX
-		dy := path.EndY - path.StartY
-		length := sqrt(dx*dx + dy*dy)
+		length := math.Hypot(path.EndX-path.StartX, path.EndY-path.StartY)
 		totalLength += length
 	}
 	return totalLength
 }
 
+// The cutting head moves between cuts at rapid speed, and lifts and lowers
+// the tool at each end of a move
+const (
+	rapidSpeed    = 10000.0 // mm/min
+	rapidMoveTime = 0.05    // Minutes per move to lift and lower the tool
+)
+
+// calculateTravel returns how far the cutting head moves between cuts,
+// starting from the corner of each sheet, and in how many moves
+func calculateTravel(layout *Layout) (float64, int) {
+	sheets := [][]CutPath{layout.CutPaths}
+	if !layout.SingleSheet() {
+		sheets = sheets[:0]
+		for _, sheet := range layout.Sheets {
+			sheets = append(sheets, sheet.CutPaths)
+		}
+	}
+
+	distance, moves := 0.0, 0
+	for _, paths := range sheets {
+		x, y := 0.0, 0.0
+		for _, path := range paths {
+			if length := math.Hypot(path.StartX-x, path.StartY-y); length > 1e-6 {
+				distance += length
+				moves++
+			}
+			x, y = path.EndX, path.EndY
+		}
+	}
+	return distance, moves
+}
+
 // estimateCuttingTime estimates total cutting time based on paths and speeds
 func estimateCuttingTime(paths []CutPath) float64 {
 	totalTime := 0.0
 	for _, path := range paths {
-		dx := path.EndX - path.StartX
-		dy := path.EndY - path.StartY
-		length := sqrt(dx*dx + dy*dy)
+		length := math.Hypot(path.EndX-path.StartX, path.EndY-path.StartY)
 
 		speed := path.Speed
 		if speed <= 0 {
@@ -387,20 +723,6 @@ func estimateCuttingTime(paths []CutPath) float64 {
 	return totalTime
 }
 
-// sqrt calculates square root
-func sqrt(x float64) float64 {
-	if x == 0 {
-		return 0
-	}
-
-	// Simple approximation for square root
-	z := x
-	for i := 0; i < 10; i++ {
-		z = (z + x/z) / 2
-	}
-	return z
-}
-
 // DefaultOptimizeOptions returns default optimization options
 func DefaultOptimizeOptions() OptimizeOptions {
 	return OptimizeOptions{
@@ -415,7 +737,7 @@ func DefaultOptimizeOptions() OptimizeOptions {
 		TimeLimit:         300,  // 5 minutes
 		QualityTarget:     0.85, // 85% utilization target
 		PreferredRotation: 0,
-		SortBy:            "area",
+		SortBy:            "priority,area",
 		SortOrder:         "desc",
 		EnableNesting:     false,
 	}
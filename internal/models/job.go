@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Optimization job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// OptimizationJob is an optimization request run in the background by the job queue
+type OptimizationJob struct {
+	ID             int                 `json:"id" db:"id"`
+	UserID         int64               `json:"user_id" db:"user_id"` // Owner of the job
+	Status         string              `json:"status" db:"status"`
+	RequestData    string              `json:"-" db:"request_data"`                            // JSON blob
+	Request        OptimizationRequest `json:"request"`                                        // Parsed request
+	ProgressData   string              `json:"-" db:"progress"`                                // JSON blob
+	Progress       JobProgress         `json:"progress"`                                       // Parsed progress
+	OptimizationID *int                `json:"optimization_id,omitempty" db:"optimization_id"` // Result once the run has finished
+	Error          string              `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	StartedAt      *time.Time          `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt     *time.Time          `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// JobProgress is the latest progress reported by a running optimization
+type JobProgress struct {
+	Generation      int     `json:"generation"`       // Genetic algorithm generation, 0 for single-pass algorithms
+	BestUtilization float64 `json:"best_utilization"` // Utilization of the best layout so far (%)
+	SheetsUsed      int     `json:"sheets_used"`      // Sheets used by the best layout so far
+	PiecesPlaced    int     `json:"pieces_placed"`    // Pieces placed by the best layout so far
+}
+
+// OptimizationJobResponse represents the response structure for job API calls
+type OptimizationJobResponse struct {
+	Job     *OptimizationJob  `json:"job,omitempty"`
+	Jobs    []OptimizationJob `json:"jobs,omitempty"`
+	Total   int               `json:"total,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// IsFinished reports whether the job has reached a final status
+func (j *OptimizationJob) IsFinished() bool {
+	switch j.Status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// MarshalRequestData serializes the Request to JSON for database storage
+func (j *OptimizationJob) MarshalRequestData() error {
+	data, err := json.Marshal(j.Request)
+	if err != nil {
+		return err
+	}
+	j.RequestData = string(data)
+	return nil
+}
+
+// UnmarshalRequestData deserializes the JSON RequestData to Request
+func (j *OptimizationJob) UnmarshalRequestData() error {
+	if j.RequestData == "" {
+		j.Request = OptimizationRequest{}
+		return nil
+	}
+	return json.Unmarshal([]byte(j.RequestData), &j.Request)
+}
+
+// MarshalProgressData serializes the Progress to JSON for database storage
+func (j *OptimizationJob) MarshalProgressData() error {
+	data, err := json.Marshal(j.Progress)
+	if err != nil {
+		return err
+	}
+	j.ProgressData = string(data)
+	return nil
+}
+
+// UnmarshalProgressData deserializes the JSON ProgressData to Progress
+func (j *OptimizationJob) UnmarshalProgressData() error {
+	if j.ProgressData == "" {
+		j.Progress = JobProgress{}
+		return nil
+	}
+	return json.Unmarshal([]byte(j.ProgressData), &j.Progress)
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Remnant statuses
+const (
+	RemnantStatusPending   = "pending"   // Left by an optimization that has not been confirmed yet
+	RemnantStatusAvailable = "available" // In stock and offered to new optimizations
+	RemnantStatusConsumed  = "consumed"  // Cut up by a confirmed optimization
+)
+
+// Remnant is a rectangular offcut kept in stock. It is the same glass as the
+// catalogue sheet it was cut from.
+type Remnant struct {
+	ID                       int       `json:"id" db:"id"`
+	SheetID                  int       `json:"sheet_id" db:"sheet_id"` // Catalogue sheet the glass came from
+	Width                    float64   `json:"width" db:"width"`
+	Height                   float64   `json:"height" db:"height"`
+	Thickness                float64   `json:"thickness" db:"thickness"`
+	Material                 string    `json:"material" db:"material"`
+	Status                   string    `json:"status" db:"status"`
+	SourceOptimizationID     *int      `json:"source_optimization_id,omitempty" db:"source_optimization_id"`           // Optimization that left the offcut
+	ConsumedByOptimizationID *int      `json:"consumed_by_optimization_id,omitempty" db:"consumed_by_optimization_id"` // Optimization that used it up
+	CreatedAt                time.Time `json:"created_at" db:"created_at"`
+}
+
+// RemnantResponse represents the response structure for remnant API calls
+type RemnantResponse struct {
+	Remnant  *Remnant  `json:"remnant,omitempty"`
+	Remnants []Remnant `json:"remnants,omitempty"`
+	Total    int       `json:"total,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Area returns the area of the remnant in square millimeters
+func (r *Remnant) Area() float64 {
+	return r.Width * r.Height
+}
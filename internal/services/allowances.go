@@ -0,0 +1,119 @@
+package services
+
+import (
+	"math"
+
+	"glass-optimizer/internal/models"
+)
+
+// usableArea returns the part of a sheet pieces may be placed on: each side
+// loses its trim, or the edge margin where no trim is set
+func usableArea(sheet *models.GlassSheet, options *models.OptimizeOptions) Rectangle {
+	side := func(trim float64) float64 {
+		if trim > 0 {
+			return trim
+		}
+		return options.EdgeMargin
+	}
+
+	left, right := side(options.Trim.Left), side(options.Trim.Right)
+	bottom, top := side(options.Trim.Bottom), side(options.Trim.Top)
+
+	return Rectangle{
+		X:      left,
+		Y:      bottom,
+		Width:  sheet.Width - left - right,
+		Height: sheet.Height - bottom - top,
+	}
+}
+
+// pieceSpacing returns the distance kept between neighbouring pieces: the
+// minimum gap plus the width of the score line between them
+func pieceSpacing(options *models.OptimizeOptions) float64 {
+	return options.MinimumGap + options.Kerf
+}
+
+// placePiece records a piece at the given position in the given orientation,
+// with its finished size when a grinding allowance was added to it
+func placePiece(piece PieceToPlace, x, y float64, orientation Orientation) models.PlacedPiece {
+	placed := models.PlacedPiece{
+		DesignID:     piece.DesignID,
+		DesignName:   piece.Name,
+		X:            x,
+		Y:            y,
+		Width:        orientation.Width,
+		Height:       orientation.Height,
+		Rotation:     orientation.Rotation,
+		EdgeDeletion: piece.EdgeDeletion,
+		Order:        piece.Order,
+	}
+	setNetSize(&placed, piece.Allowance)
+
+	return placed
+}
+
+// setNetSize sets the finished size of a placed piece cut with the given
+// grinding allowance on every edge
+func setNetSize(placed *models.PlacedPiece, allowance float64) {
+	if allowance > 0 {
+		placed.NetWidth = placed.Width - 2*allowance
+		placed.NetHeight = placed.Height - 2*allowance
+	}
+}
+
+// addGrindingAllowance grows a piece by the allowance on every edge. Its
+// outline is offset outwards and, like its holes, moved so that the grown
+// piece starts at the origin; the hole drop-outs shrink by the allowance the
+// holes are ground out by.
+func addGrindingAllowance(piece *PieceToPlace, allowance float64) {
+	if allowance <= 0 {
+		return
+	}
+
+	piece.Allowance = allowance
+	piece.Width += 2 * allowance
+	piece.Height += 2 * allowance
+
+	if piece.Outline != nil {
+		piece.Outline = translateOutline(offsetOutline(piece.Outline, allowance), allowance, allowance)
+	}
+
+	var holes []Rectangle
+	for _, hole := range piece.Holes {
+		if hole.Width > 2*allowance && hole.Height > 2*allowance {
+			holes = append(holes, Rectangle{
+				X:      hole.X + 2*allowance,
+				Y:      hole.Y + 2*allowance,
+				Width:  hole.Width - 2*allowance,
+				Height: hole.Height - 2*allowance,
+			})
+		}
+	}
+	piece.Holes = holes
+}
+
+// offsetOutline moves every edge of a counter-clockwise outline outwards by
+// the given distance, keeping sharp corners
+func offsetOutline(outline []models.Point, distance float64) []models.Point {
+	normal := func(a, b models.Point) models.Point {
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		return models.Point{X: (b.Y - a.Y) / length, Y: (a.X - b.X) / length}
+	}
+
+	n := len(outline)
+	offset := make([]models.Point, n)
+	for i, point := range outline {
+		before := normal(outline[(i+n-1)%n], point)
+		after := normal(point, outline[(i+1)%n])
+
+		// The corner moves along the bisector far enough for both edges to
+		// move by the distance
+		scale := distance / (1 + before.X*after.X + before.Y*after.Y)
+		offset[i] = models.Point{
+			X: point.X + (before.X+after.X)*scale,
+			Y: point.Y + (before.Y+after.Y)*scale,
+		}
+	}
+
+	return offset
+}
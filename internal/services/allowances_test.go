@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestAllowancesApplyToEveryAlgorithm(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	algorithms := []string{"blf", "genetic", "greedy", "guillotine", "maxrects-bssf", "skyline", "nfp"}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+				Name:      "Allowances " + algorithm,
+				SheetID:   1, // Standard 2m x 3m
+				Algorithm: algorithm,
+				Designs:   []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 30}},
+				Options: models.OptimizeOptions{
+					AllowRotation:     true,
+					MinimumGap:        2,
+					Kerf:              3,
+					Trim:              models.EdgeTrim{Left: 20, Bottom: 15},
+					GrindingAllowance: 2,
+					EdgeDeletion:      10,
+					MaxIterations:     5,
+					PopulationSize:    6,
+				},
+			}, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+
+			pieces := optimization.Layout.AllPieces()
+			if len(pieces) != 30 {
+				t.Fatalf("Placed pieces incorrect: got %d, want %d", len(pieces), 30)
+			}
+
+			for _, sheet := range optimization.Layout.Sheets {
+				for i, a := range sheet.Pieces {
+					if a.Width*a.Height != 404*304 {
+						t.Errorf("Gross size of %s incorrect: got %.0fx%.0f, want 404x304", a.ID, a.Width, a.Height)
+					}
+					if a.NetWidth != a.Width-4 || a.NetHeight != a.Height-4 {
+						t.Errorf("Net size of %s incorrect: got %.0fx%.0f for %.0fx%.0f", a.ID, a.NetWidth, a.NetHeight, a.Width, a.Height)
+					}
+					if a.EdgeDeletion != 10 {
+						t.Errorf("Edge deletion of %s incorrect: got %.1f, want %.1f", a.ID, a.EdgeDeletion, 10.0)
+					}
+
+					// Trimmed sides lose the trim, the others the 5mm edge margin
+					if a.X < 20 || a.Y < 15 || a.X+a.Width > sheet.Width-5 || a.Y+a.Height > sheet.Height-5 {
+						t.Errorf("Piece %s outside the usable area: (%.0f,%.0f) %.0fx%.0f", a.ID, a.X, a.Y, a.Width, a.Height)
+					}
+
+					for _, b := range sheet.Pieces[i+1:] {
+						apart := math.Max(math.Max(b.X-a.X-a.Width, a.X-b.X-b.Width), math.Max(b.Y-a.Y-a.Height, a.Y-b.Y-b.Height))
+						if apart < 5-1e-6 {
+							t.Errorf("Pieces %s and %s closer than gap and kerf: %.1fmm", a.ID, b.ID, apart)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCuttingListReportsNetAndGrossSizes(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name:      "Ground panes",
+		SheetID:   1,
+		Algorithm: "blf",
+		Designs:   []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 2}},
+		Options:   models.OptimizeOptions{GrindingAllowance: 1.5, EdgeDeletion: 12},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+
+	export, err := service.ExportOptimization(optimization.ID, userID, "cutting_list")
+	if err != nil {
+		t.Fatalf("ExportOptimization() error = %v", err)
+	}
+
+	list := export.Data.(string)
+	for _, want := range []string{"Edge Deletion: 12.0mm", "Gross Width\tGross Height\tNet Width\tNet Height", "403.0\t303.0\t400.0\t300.0"} {
+		if !strings.Contains(list, want) {
+			t.Errorf("Cutting list does not contain %q:\n%s", want, list)
+		}
+	}
+
+	req := &models.OptimizationRequest{Name: "Bad kerf", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 1}},
+		Options: models.OptimizeOptions{Kerf: -1}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Negative kerf: got %v, want validation error", err)
+	}
+}
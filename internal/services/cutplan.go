@@ -0,0 +1,483 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"glass-optimizer/internal/models"
+)
+
+// cutPanel is a part of a sheet still to be broken up. Each side is either a
+// cut or the edge of the sheet; a cut also frees the edges of the pieces
+// across the gap from it.
+type cutPanel struct {
+	Rectangle
+	cutLeft, cutBottom, cutRight, cutTop bool
+}
+
+// cutPlanner collects the cuts of a sheet in the order they are made
+type cutPlanner struct {
+	spacing float64
+	paths   []models.CutPath
+}
+
+// planCuts returns the cuts that free the pieces of a sheet as a breaking
+// sequence. Pieces nested in hole drop-outs are cut while the sheet is whole,
+// then the edges of the sheet are trimmed off up to the usable area. A
+// guillotine cut tree is followed as packed; other layouts are broken up by
+// straight cuts from edge to edge of the panel being broken, first into
+// strips, then the strips across, until each panel holds one piece that is
+// trimmed to size. Where no straight cut separates the pieces, as with shapes
+// nested into each other, their edges are scored before the panel is broken.
+// Each cut runs along the edge of one piece and frees the piece across the
+// gap from it too, and collinear edges are scored in one pass. The sequence
+// is then ordered for the travel of the cutting head.
+func (s *OptimizerService) planCuts(width, height float64, pieces []models.PlacedPiece, tree *models.CutNode, options *models.OptimizeOptions) []models.CutPath {
+	planner := &cutPlanner{spacing: pieceSpacing(options)}
+
+	hosts := make(map[string]bool, len(pieces))
+	for _, piece := range pieces {
+		hosts[piece.ID] = true
+	}
+	var loose, nested []models.PlacedPiece
+	for _, piece := range cutOrder(pieces) {
+		if piece.Nested && hosts[piece.ParentID] {
+			nested = append(nested, piece)
+		} else {
+			loose = append(loose, piece)
+		}
+	}
+
+	planner.scorePieces(cutPanel{Rectangle: Rectangle{Width: width, Height: height}}, nested)
+	nestedCuts := len(planner.paths)
+	panel := planner.trimSheet(width, height, usableArea(&models.GlassSheet{Width: width, Height: height}, options))
+
+	if tree != nil {
+		planner.followTree(tree, panel.Rectangle, loose)
+	} else {
+		horizontal := len(breakLines(loose, true)) >= len(breakLines(loose, false))
+		planner.breakPanel(panel, loose, 1, horizontal)
+	}
+
+	return orderToolPath(planner.paths, nestedCuts)
+}
+
+// addCut appends a cut to the sequence
+func (p *cutPlanner) addCut(name string, stage int, startX, startY, endX, endY float64, pieceIDs []string) {
+	cutType := "horizontal"
+	if startX == endX {
+		cutType = "vertical"
+	}
+	order := len(p.paths) + 1
+	p.paths = append(p.paths, models.CutPath{
+		ID:       fmt.Sprintf("cut_%s_%d", name, order),
+		Type:     cutType,
+		StartX:   startX,
+		StartY:   startY,
+		EndX:     endX,
+		EndY:     endY,
+		Order:    order,
+		Stage:    stage,
+		ToolType: "straight",
+		Speed:    100.0,
+		Pieces:   pieceIDs,
+	})
+}
+
+// trimSheet cuts the edge margin or trim off each side of the sheet that has
+// one and returns the usable area left
+func (p *cutPlanner) trimSheet(width, height float64, usable Rectangle) cutPanel {
+	panel := cutPanel{Rectangle: Rectangle{Width: width, Height: height}}
+
+	if usable.Y > guillotineEpsilon {
+		p.addCut("edge", 0, panel.X, usable.Y, panel.X+panel.Width, usable.Y, nil)
+		panel.Height -= usable.Y - panel.Y
+		panel.Y, panel.cutBottom = usable.Y, true
+	}
+	if top := usable.Y + usable.Height; top < height-guillotineEpsilon {
+		p.addCut("edge", 0, panel.X, top, panel.X+panel.Width, top, nil)
+		panel.Height, panel.cutTop = top-panel.Y, true
+	}
+	if usable.X > guillotineEpsilon {
+		p.addCut("edge", 0, usable.X, panel.Y, usable.X, panel.Y+panel.Height, nil)
+		panel.Width -= usable.X - panel.X
+		panel.X, panel.cutLeft = usable.X, true
+	}
+	if right := usable.X + usable.Width; right < width-guillotineEpsilon {
+		p.addCut("edge", 0, right, panel.Y, right, panel.Y+panel.Height, nil)
+		panel.Width, panel.cutRight = right-panel.X, true
+	}
+
+	return panel
+}
+
+// breakLines returns where straight cuts across a panel separate its pieces:
+// horizontal cuts, or vertical ones, along the far edge of each group of
+// pieces whose extents overlap, before the next group
+func breakLines(pieces []models.PlacedPiece, horizontal bool) []float64 {
+	if len(pieces) < 2 {
+		return nil
+	}
+
+	extents := make([][2]float64, len(pieces))
+	for i, piece := range pieces {
+		if horizontal {
+			extents[i] = [2]float64{piece.Y, piece.Y + piece.Height}
+		} else {
+			extents[i] = [2]float64{piece.X, piece.X + piece.Width}
+		}
+	}
+	sort.Slice(extents, func(i, j int) bool { return extents[i][0] < extents[j][0] })
+
+	var lines []float64
+	reach := extents[0][1]
+	for _, extent := range extents[1:] {
+		if extent[0] >= reach-guillotineEpsilon {
+			lines = append(lines, reach)
+		}
+		reach = max(reach, extent[1])
+	}
+	return lines
+}
+
+// breakPanel trims the panel to its pieces, cuts it into strips along
+// breakLines, preferring the given direction, and breaks up each strip
+// across. A panel holding one piece is trimmed to it.
+func (p *cutPlanner) breakPanel(panel cutPanel, pieces []models.PlacedPiece, stage int, horizontal bool) {
+	switch len(pieces) {
+	case 0:
+		return
+	case 1:
+		p.trimPiece(panel, pieces[0], stage)
+		return
+	}
+
+	lines := breakLines(pieces, horizontal)
+	if len(lines) == 0 {
+		horizontal = !horizontal
+		lines = breakLines(pieces, horizontal)
+	}
+	if len(lines) == 0 {
+		p.scorePieces(panel, pieces)
+		return
+	}
+
+	// The waste round the pieces comes off first, then the panel is cut into
+	// all of its strips before they are broken up
+	strips := make([]cutPanel, 0, len(lines)+1)
+	rest := p.trimPanel(panel, pieces, stage)
+	for _, line := range lines {
+		strip := rest
+		if horizontal {
+			p.addCut("break", stage, rest.X, line, rest.X+rest.Width, line, p.edgesAlong(pieces, true, line))
+			strip.Height, strip.cutTop = line-rest.Y, true
+			rest.Height -= line - rest.Y
+			rest.Y, rest.cutBottom = line, true
+		} else {
+			p.addCut("break", stage, line, rest.Y, line, rest.Y+rest.Height, p.edgesAlong(pieces, false, line))
+			strip.Width, strip.cutRight = line-rest.X, true
+			rest.Width -= line - rest.X
+			rest.X, rest.cutLeft = line, true
+		}
+		strips = append(strips, strip)
+	}
+	strips = append(strips, rest)
+
+	for _, strip := range strips {
+		var inside []models.PlacedPiece
+		for _, piece := range pieces {
+			if rectangleContains(strip.Rectangle, Rectangle{X: piece.X, Y: piece.Y, Width: piece.Width, Height: piece.Height}) {
+				inside = append(inside, piece)
+			}
+		}
+		p.breakPanel(strip, inside, stage+1, !horizontal)
+	}
+}
+
+// edgesAlong returns the IDs of the pieces a cut along the line frees: those
+// with an edge on it and those across the gap from it
+func (p *cutPlanner) edgesAlong(pieces []models.PlacedPiece, horizontal bool, line float64) []string {
+	var ids []string
+	for _, piece := range pieces {
+		near, far := piece.X, piece.X+piece.Width
+		if horizontal {
+			near, far = piece.Y, piece.Y+piece.Height
+		}
+		if math.Abs(far-line) <= guillotineEpsilon || near >= line-guillotineEpsilon && near <= line+p.spacing+guillotineEpsilon {
+			ids = append(ids, piece.ID)
+		}
+	}
+	return ids
+}
+
+// trimPiece cuts the piece out of the panel holding it and, for a shaped
+// piece, along its outline
+func (p *cutPlanner) trimPiece(panel cutPanel, piece models.PlacedPiece, stage int) {
+	p.trimPanel(panel, []models.PlacedPiece{piece}, stage)
+	if len(piece.Outline) > 0 {
+		p.cutOutline(piece, true)
+	}
+}
+
+// trimPanel cuts the waste around the pieces off the panel: each side of
+// their bounds not yet free is cut from edge to edge of what is left of the
+// panel, right and top first. It returns the panel left.
+func (p *cutPlanner) trimPanel(panel cutPanel, pieces []models.PlacedPiece, stage int) cutPanel {
+	free := func(distance float64, cut bool) bool {
+		return distance <= guillotineEpsilon || cut && distance <= p.spacing+guillotineEpsilon
+	}
+	left, bottom := math.Inf(1), math.Inf(1)
+	right, top := math.Inf(-1), math.Inf(-1)
+	for _, piece := range pieces {
+		left, bottom = min(left, piece.X), min(bottom, piece.Y)
+		right, top = max(right, piece.X+piece.Width), max(top, piece.Y+piece.Height)
+	}
+
+	if !free(panel.X+panel.Width-right, panel.cutRight) {
+		p.addCut("trim", stage, right, panel.Y, right, panel.Y+panel.Height, p.edgesAlong(pieces, false, right))
+		panel.Width, panel.cutRight = right-panel.X, true
+	}
+	if !free(panel.Y+panel.Height-top, panel.cutTop) {
+		p.addCut("trim", stage, panel.X, top, panel.X+panel.Width, top, p.edgesAlong(pieces, true, top))
+		panel.Height, panel.cutTop = top-panel.Y, true
+	}
+	if !free(left-panel.X, panel.cutLeft) {
+		p.addCut("trim", stage, left, panel.Y, left, panel.Y+panel.Height, p.edgesAlong(pieces, false, left))
+		panel.Width -= left - panel.X
+		panel.X, panel.cutLeft = left, true
+	}
+	if !free(bottom-panel.Y, panel.cutBottom) {
+		p.addCut("trim", stage, panel.X, bottom, panel.X+panel.Width, bottom, p.edgesAlong(pieces, true, bottom))
+		panel.Height -= bottom - panel.Y
+		panel.Y, panel.cutBottom = bottom, true
+	}
+	return panel
+}
+
+// cutOutline cuts a shaped piece along its outline, skipping the edges that
+// lie on the sides of its bounding box when those are already cut
+func (p *cutPlanner) cutOutline(piece models.PlacedPiece, trimmed bool) {
+	bounds := Rectangle{X: piece.X, Y: piece.Y, Width: piece.Width, Height: piece.Height}
+	onSide := func(a, b models.Point) bool {
+		near := func(u, v float64) bool { return math.Abs(u-v) <= guillotineEpsilon }
+		return near(a.X, bounds.X) && near(b.X, bounds.X) ||
+			near(a.X, bounds.X+bounds.Width) && near(b.X, bounds.X+bounds.Width) ||
+			near(a.Y, bounds.Y) && near(b.Y, bounds.Y) ||
+			near(a.Y, bounds.Y+bounds.Height) && near(b.Y, bounds.Y+bounds.Height)
+	}
+
+	for _, edge := range outlineEdges(piece.Outline) {
+		if trimmed && onSide(edge[0], edge[1]) {
+			continue
+		}
+		order := len(p.paths) + 1
+		p.paths = append(p.paths, models.CutPath{
+			ID:       fmt.Sprintf("cut_curve_%d", order),
+			Type:     "curve",
+			StartX:   edge[0].X,
+			StartY:   edge[0].Y,
+			EndX:     edge[1].X,
+			EndY:     edge[1].Y,
+			Order:    order,
+			ToolType: "straight",
+			Speed:    100.0,
+			Pieces:   []string{piece.ID},
+		})
+	}
+}
+
+// scoredEdge is a straight score along piece edges: on the line at pos, from
+// lo to hi, horizontal or vertical
+type scoredEdge struct {
+	horizontal bool
+	pos        float64
+	lo, hi     float64
+	pieces     []string
+}
+
+// scorePieces scores the edges of pieces no straight cut separates. The far
+// edge of a piece, its top or right, frees the near edge of a piece across
+// the gap from it, and edges on the same line are scored in one pass across
+// the gaps between them. Edges the sides of the panel free are left out.
+// Shaped pieces are cut along their outline.
+func (p *cutPlanner) scorePieces(panel cutPanel, pieces []models.PlacedPiece) {
+	var rectangles []models.PlacedPiece
+	for _, piece := range pieces {
+		if len(piece.Outline) > 0 {
+			p.cutOutline(piece, false)
+		} else {
+			rectangles = append(rectangles, piece)
+		}
+	}
+
+	for _, horizontal := range []bool{true, false} {
+		for _, edge := range p.sharedEdges(panel, rectangles, horizontal) {
+			if horizontal {
+				p.addCut("score", 0, edge.lo, edge.pos, edge.hi, edge.pos, edge.pieces)
+			} else {
+				p.addCut("score", 0, edge.pos, edge.lo, edge.pos, edge.hi, edge.pieces)
+			}
+		}
+	}
+}
+
+// sharedEdges returns the merged scores along the horizontal, or vertical,
+// edges of the rectangular pieces, ordered by line and along it
+func (p *cutPlanner) sharedEdges(panel cutPanel, pieces []models.PlacedPiece, horizontal bool) []scoredEdge {
+	// extent returns the near and far edge of a piece across the lines, and
+	// its span along them
+	extent := func(piece models.PlacedPiece) (near, far, lo, hi float64) {
+		if horizontal {
+			return piece.Y, piece.Y + piece.Height, piece.X, piece.X + piece.Width
+		}
+		return piece.X, piece.X + piece.Width, piece.Y, piece.Y + piece.Height
+	}
+	panelNear, panelFar := panel.X, panel.X+panel.Width
+	cutNear, cutFar := panel.cutLeft, panel.cutRight
+	if horizontal {
+		panelNear, panelFar = panel.Y, panel.Y+panel.Height
+		cutNear, cutFar = panel.cutBottom, panel.cutTop
+	}
+	free := func(distance float64, cut bool) bool {
+		return distance <= guillotineEpsilon || cut && distance <= p.spacing+guillotineEpsilon
+	}
+
+	var edges []scoredEdge
+	for _, piece := range pieces {
+		_, far, lo, hi := extent(piece)
+		if !free(panelFar-far, cutFar) {
+			edges = append(edges, scoredEdge{horizontal: horizontal, pos: far, lo: lo, hi: hi, pieces: []string{piece.ID}})
+		}
+	}
+	farEdges := len(edges)
+
+	for _, piece := range pieces {
+		near, _, lo, hi := extent(piece)
+		if free(near-panelNear, cutNear) {
+			continue
+		}
+
+		// Parts of the edge no far edge across the gap frees
+		spans := [][2]float64{{lo, hi}}
+		for i := 0; i < farEdges; i++ {
+			edge := &edges[i]
+			if edge.pos < near-p.spacing-guillotineEpsilon || edge.pos > near+guillotineEpsilon ||
+				edge.hi <= lo+guillotineEpsilon || edge.lo >= hi-guillotineEpsilon {
+				continue
+			}
+			edge.pieces = append(edge.pieces, piece.ID)
+			spans = subtractSpan(spans, edge.lo, edge.hi)
+		}
+		for _, span := range spans {
+			if span[1]-span[0] > guillotineEpsilon {
+				edges = append(edges, scoredEdge{horizontal: horizontal, pos: near, lo: span[0], hi: span[1], pieces: []string{piece.ID}})
+			}
+		}
+	}
+
+	sort.SliceStable(edges, func(i, j int) bool {
+		if math.Abs(edges[i].pos-edges[j].pos) > guillotineEpsilon {
+			return edges[i].pos < edges[j].pos
+		}
+		return edges[i].lo < edges[j].lo
+	})
+
+	// Edges on a line closer than the spacing are scored in one pass: no
+	// piece fits between them
+	var merged []scoredEdge
+	for _, edge := range edges {
+		if n := len(merged); n > 0 && math.Abs(merged[n-1].pos-edge.pos) <= guillotineEpsilon &&
+			edge.lo <= merged[n-1].hi+p.spacing+guillotineEpsilon {
+			last := &merged[n-1]
+			last.hi = max(last.hi, edge.hi)
+			for _, id := range edge.pieces {
+				if !slices.Contains(last.pieces, id) {
+					last.pieces = append(last.pieces, id)
+				}
+			}
+			continue
+		}
+		merged = append(merged, edge)
+	}
+	return merged
+}
+
+// subtractSpan removes the span from lo to hi from the spans
+func subtractSpan(spans [][2]float64, lo, hi float64) [][2]float64 {
+	var rest [][2]float64
+	for _, span := range spans {
+		if hi <= span[0] || lo >= span[1] {
+			rest = append(rest, span)
+			continue
+		}
+		if lo > span[0] {
+			rest = append(rest, [2]float64{span[0], lo})
+		}
+		if hi < span[1] {
+			rest = append(rest, [2]float64{hi, span[1]})
+		}
+	}
+	return rest
+}
+
+// followTree turns a guillotine cut tree into through-cuts in cutting order:
+// a panel is cut into its strips before the strips are processed, and pieces
+// left in an oversized panel are trimmed last. Each cut runs from edge to edge
+// of its panel, across the kerf left by the cuts before it.
+func (p *cutPlanner) followTree(tree *models.CutNode, panel Rectangle, pieces []models.PlacedPiece) {
+	piecesByID := make(map[string]models.PlacedPiece, len(pieces))
+	for _, piece := range pieces {
+		piecesByID[piece.ID] = piece
+	}
+
+	var walk func(node *models.CutNode, panel Rectangle)
+	walk = func(node *models.CutNode, panel Rectangle) {
+		strips := make([]Rectangle, 0, len(node.Children))
+		rest := panel
+		for i := range node.Children {
+			child := &node.Children[i]
+			name := guillotineStageNames[child.Stage]
+			strip := rest
+
+			if node.Direction == "vertical" {
+				x := child.X + child.Width
+				if x < node.X+node.Width-guillotineEpsilon {
+					p.addCut(name, child.Stage, x, rest.Y, x, rest.Y+rest.Height, cutTreePieceIDs(child))
+					strip.Width = x - rest.X
+					rest.Width -= x - rest.X
+					rest.X = x
+				}
+			} else {
+				y := child.Y + child.Height
+				if y < node.Y+node.Height-guillotineEpsilon {
+					p.addCut(name, child.Stage, rest.X, y, rest.X+rest.Width, y, cutTreePieceIDs(child))
+					strip.Height = y - rest.Y
+					rest.Height -= y - rest.Y
+					rest.Y = y
+				}
+			}
+			strips = append(strips, strip)
+		}
+
+		for i := range node.Children {
+			walk(&node.Children[i], strips[i])
+		}
+
+		piece, ok := piecesByID[node.PieceID]
+		if !ok {
+			return
+		}
+
+		// Trim the piece out of its panel
+		if right := piece.X + piece.Width; right < node.X+node.Width-guillotineEpsilon {
+			p.addCut("trim", node.Stage+1, right, panel.Y, right, panel.Y+panel.Height, []string{piece.ID})
+			panel.Width = right - panel.X
+		}
+		if top := piece.Y + piece.Height; top < node.Y+node.Height-guillotineEpsilon {
+			p.addCut("trim", node.Stage+1, panel.X, top, panel.X+panel.Width, top, []string{piece.ID})
+		}
+	}
+	walk(tree, panel)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestCutPlanBreaksSheet(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Grid", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 12}},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	assertLegalLayout(t, &optimization.Layout)
+
+	// Past trimming the sheet edges, cutting along the shared edges is shorter
+	// than cutting round each piece
+	perimeters, length := 0.0, 0.0
+	for _, piece := range optimization.Layout.AllPieces() {
+		perimeters += 2 * (piece.Width + piece.Height)
+	}
+	for _, cut := range optimization.Layout.AllCutPaths() {
+		if cut.Stage > 0 {
+			length += math.Hypot(cut.EndX-cut.StartX, cut.EndY-cut.StartY)
+		}
+	}
+	if length <= 0 || length >= perimeters {
+		t.Errorf("Cutting length incorrect: got %.0f, want below %.0f", length, perimeters)
+	}
+
+	for _, sheet := range optimization.Layout.Sheets {
+		assertCutsClear(t, sheet)
+		assertBreakingSequence(t, sheet)
+	}
+
+	exports := map[string]string{"cutting_list": "Cutting Sequence:", "svg": `class="cut"`, "dxf": "CUTS_1"}
+	for format, want := range exports {
+		export, err := service.ExportOptimization(optimization.ID, userID, format)
+		if err != nil {
+			t.Fatalf("ExportOptimization(%s) error = %v", format, err)
+		}
+		if !strings.Contains(export.Data.(string), want) {
+			t.Errorf("%s export misses %q", format, want)
+		}
+	}
+}
+
+func TestCutPlanScoresPinwheel(t *testing.T) {
+	service := &OptimizerService{}
+	options := &models.OptimizeOptions{MinimumGap: 2}
+
+	// Four panes round a square: no straight cut across the sheet separates
+	// them, so their edges are scored
+	sheet := models.SheetLayout{SheetNumber: 1, Width: 1402, Height: 1402}
+	for i, rect := range [][4]float64{
+		{0, 0, 1000, 400}, {1002, 0, 400, 1000}, {402, 1002, 1000, 400}, {0, 402, 400, 1000}, {402, 402, 598, 598},
+	} {
+		sheet.Pieces = append(sheet.Pieces, models.PlacedPiece{ID: placementID(1, i+1), X: rect[0], Y: rect[1], Width: rect[2], Height: rect[3]})
+	}
+	sheet.CutPaths = service.planCuts(sheet.Width, sheet.Height, sheet.Pieces, nil, options)
+
+	if violations := ValidateLayout(&models.Layout{Sheets: []models.SheetLayout{sheet}}, options); len(violations) != 0 {
+		t.Errorf("Violations incorrect: got %+v, want none", violations)
+	}
+	assertCutsClear(t, sheet)
+	assertBreakingSequence(t, sheet)
+	if got := len(sheet.CutPaths); got >= 4*len(sheet.Pieces) {
+		t.Errorf("Cuts incorrect: got %d, want fewer than %d", got, 4*len(sheet.Pieces))
+	}
+
+	// The tops of the square and the pane beside it lie on one line and are
+	// scored together
+	merged := false
+	for _, cut := range sheet.CutPaths {
+		if cut.StartY == 1000 && cut.EndY == 1000 && math.Min(cut.StartX, cut.EndX) == 402 && math.Max(cut.StartX, cut.EndX) == 1402 {
+			merged = len(cut.Pieces) == 3
+		}
+	}
+	if !merged {
+		t.Errorf("Collinear edges not merged: got %+v", sheet.CutPaths)
+	}
+}
+
+// assertCutsClear fails the test for each straight cut running through the
+// outline of a piece of the sheet. Hosts of nested pieces are left out: the
+// pieces in their hole drop-outs are cut inside their outline.
+func assertCutsClear(t *testing.T, sheet models.SheetLayout) {
+	t.Helper()
+	hosts := make(map[string]bool)
+	for _, piece := range sheet.Pieces {
+		if piece.Nested {
+			hosts[piece.ParentID] = true
+		}
+	}
+	for _, cut := range sheet.CutPaths {
+		if cut.Type == "curve" {
+			continue
+		}
+		start, end := models.Point{X: cut.StartX, Y: cut.StartY}, models.Point{X: cut.EndX, Y: cut.EndY}
+		middle := models.Point{X: (start.X + end.X) / 2, Y: (start.Y + end.Y) / 2}
+		for _, piece := range sheet.Pieces {
+			if hosts[piece.ID] {
+				continue
+			}
+			outline := pieceOutline(piece)
+			crosses := pointStrictlyInside(middle, outline)
+			for _, edge := range outlineEdges(outline) {
+				crosses = crosses || segmentsCross(start, end, edge[0], edge[1])
+			}
+			if crosses {
+				t.Errorf("Sheet %d cut %s runs through piece %s", sheet.SheetNumber, cut.ID, piece.ID)
+			}
+		}
+	}
+}
+
+// assertBreakingSequence fails the test unless the cuts of the sheet are
+// numbered in order and each cut other than a score runs from edge to edge
+// of a panel: both its ends lie on the sheet edge or on an earlier cut
+func assertBreakingSequence(t *testing.T, sheet models.SheetLayout) {
+	t.Helper()
+	onEdge := func(point models.Point, earlier []models.CutPath) bool {
+		if point.X <= guillotineEpsilon || point.Y <= guillotineEpsilon ||
+			point.X >= sheet.Width-guillotineEpsilon || point.Y >= sheet.Height-guillotineEpsilon {
+			return true
+		}
+		for _, cut := range earlier {
+			start, end := models.Point{X: cut.StartX, Y: cut.StartY}, models.Point{X: cut.EndX, Y: cut.EndY}
+			if cut.Type != "curve" && pointSegmentDistance(point, start, end) <= guillotineEpsilon {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i, cut := range sheet.CutPaths {
+		if cut.Order != i+1 {
+			t.Errorf("Sheet %d cut %s order incorrect: got %d, want %d", sheet.SheetNumber, cut.ID, cut.Order, i+1)
+		}
+		if cut.Type == "curve" || strings.HasPrefix(cut.ID, "cut_score") {
+			continue
+		}
+		start, end := models.Point{X: cut.StartX, Y: cut.StartY}, models.Point{X: cut.EndX, Y: cut.EndY}
+		if !onEdge(start, sheet.CutPaths[:i]) || !onEdge(end, sheet.CutPaths[:i]) {
+			t.Errorf("Sheet %d cut %s does not run edge to edge", sheet.SheetNumber, cut.ID)
+		}
+	}
+}
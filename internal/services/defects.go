@@ -0,0 +1,61 @@
+package services
+
+import (
+	"glass-optimizer/internal/models"
+)
+
+// sheetInstance returns the nth stock sheet of a size. It carries the
+// defects marked on every sheet of the size and those marked on the nth. The
+// layout names the instance each sheet is cut from, so the cutter takes the
+// sheet the defects were marked on whatever order the sheets end up in.
+func sheetInstance(sheet *models.GlassSheet, n int) *models.GlassSheet {
+	if len(sheet.Defects) == 0 {
+		return sheet
+	}
+
+	instance := *sheet
+	instance.Defects = nil
+	for _, defect := range sheet.Defects {
+		if defect.Instance == 0 || defect.Instance == n {
+			instance.Defects = append(instance.Defects, defect)
+		}
+	}
+	return &instance
+}
+
+// defectAreas returns the areas pieces must keep out of: the defects grown by
+// the given clearance on every side
+func defectAreas(defects []models.SheetDefect, clearance float64) []Rectangle {
+	areas := make([]Rectangle, 0, len(defects))
+	for _, defect := range defects {
+		areas = append(areas, Rectangle{
+			X:      defect.X - clearance,
+			Y:      defect.Y - clearance,
+			Width:  defect.Width + 2*clearance,
+			Height: defect.Height + 2*clearance,
+		})
+	}
+	return areas
+}
+
+// footprintDefectAreas returns the areas the footprints of the MaxRects and
+// Skyline packers must keep out of. A footprint carries the gap on its right
+// and top, so the defects grow by the gap on those sides only.
+func footprintDefectAreas(defects []models.SheetDefect, gap float64) []Rectangle {
+	areas := defectAreas(defects, 0)
+	for i := range areas {
+		areas[i].Width += gap
+		areas[i].Height += gap
+	}
+	return areas
+}
+
+// firstOverlap returns the first of the areas the rectangle overlaps
+func (s *OptimizerService) firstOverlap(rect Rectangle, areas []Rectangle) (Rectangle, bool) {
+	for _, area := range areas {
+		if s.rectanglesIntersect(rect, area) {
+			return area, true
+		}
+	}
+	return Rectangle{}, false
+}
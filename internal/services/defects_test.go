@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestPackersAvoidSheetDefects(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	sheet := &models.GlassSheet{
+		Name: "Flawed 2m x 3m", Width: 2000, Height: 3000, Thickness: 6, PricePerSqm: 45.50, InStock: 5, Material: "clear",
+		Defects: []models.SheetDefect{
+			{Type: "bubble", X: 900, Y: 1400, Width: 200, Height: 150},
+			{Type: "scratch", X: 100, Y: 400, Width: 1500, Height: 10, Instance: 2},
+		},
+	}
+	if err := store.CreateGlassSheet(sheet); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+
+	algorithms := []string{"blf", "genetic", "greedy", "guillotine", "maxrects-bssf", "skyline", "nfp"}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+				Name:      "Defects " + algorithm,
+				SheetID:   sheet.ID,
+				Algorithm: algorithm,
+				Designs:   []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 50}},
+				Options:   models.OptimizeOptions{AllowRotation: true, MaxIterations: 5, PopulationSize: 6},
+			}, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+			if got := len(optimization.Layout.AllPieces()); got != 50 {
+				t.Fatalf("Placed pieces incorrect: got %d, want %d", got, 50)
+			}
+			if len(optimization.Layout.Sheets) < 2 {
+				t.Fatalf("Expected at least 2 sheets, got %d", len(optimization.Layout.Sheets))
+			}
+			assertLegalLayout(t, &optimization.Layout)
+
+			for _, layout := range optimization.Layout.Sheets {
+				if layout.Instance != layout.SheetNumber {
+					t.Errorf("Sheet %d instance incorrect: got %d, want %d", layout.SheetNumber, layout.Instance, layout.SheetNumber)
+				}
+				want := 1
+				if layout.Instance == 2 {
+					want = 2
+				}
+				if len(layout.Defects) != want {
+					t.Errorf("Sheet %d defects incorrect: got %d, want %d", layout.SheetNumber, len(layout.Defects), want)
+				}
+
+				// Pieces keep the 2mm gap from every defect
+				for _, defect := range defectAreas(layout.Defects, 2) {
+					for _, piece := range layout.Pieces {
+						if service.rectanglesIntersect(defect, Rectangle{X: piece.X, Y: piece.Y, Width: piece.Width, Height: piece.Height}) {
+							t.Errorf("Piece %s on sheet %d covers a defect: (%.0f,%.0f) %.0fx%.0f",
+								piece.ID, layout.SheetNumber, piece.X, piece.Y, piece.Width, piece.Height)
+						}
+					}
+				}
+			}
+
+			if algorithm != "blf" {
+				return
+			}
+			svg, err := service.ExportOptimization(optimization.ID, userID, "svg")
+			if err != nil {
+				t.Fatalf("ExportOptimization(svg) error = %v", err)
+			}
+			defects := 0
+			for _, layout := range optimization.Layout.Sheets {
+				defects += len(layout.Defects)
+			}
+			if got := strings.Count(svg.Data.(string), `class="defect"`); got != defects {
+				t.Errorf("SVG defects incorrect: got %d, want %d", got, defects)
+			}
+			list, err := service.ExportOptimization(optimization.ID, userID, "cutting_list")
+			if err != nil {
+				t.Fatalf("ExportOptimization(cutting_list) error = %v", err)
+			}
+			if line := fmt.Sprintf("-- Sheet 2: 2000 x 3000mm, stock sheet %d, instance 2\n", sheet.ID); !strings.Contains(list.Data.(string), line) {
+				t.Errorf("Cutting list misses %q", line)
+			}
+		})
+	}
+
+	// Improving may drop or reorder sheets; each sheet keeps the defects of
+	// the stock sheet it names
+	improved, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Defects improved", SheetID: sheet.ID, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 50}},
+		Options: models.OptimizeOptions{AllowRotation: true, Improve: true, ImproveIterations: 300, Seed: 2},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization(improved) error = %v", err)
+	}
+	assertLegalLayout(t, &improved.Layout)
+	instances := make(map[int]bool)
+	for _, layout := range improved.Layout.Sheets {
+		if layout.Instance < 1 || instances[layout.Instance] {
+			t.Errorf("Sheet %d instance incorrect: got %d", layout.SheetNumber, layout.Instance)
+		}
+		instances[layout.Instance] = true
+		if want := len(sheetInstance(sheet, layout.Instance).Defects); len(layout.Defects) != want {
+			t.Errorf("Sheet %d defects incorrect: got %d, want %d for instance %d", layout.SheetNumber, len(layout.Defects), want, layout.Instance)
+		}
+	}
+
+	outside := &models.GlassSheet{Name: "Bad", Width: 1000, Height: 1000, Thickness: 6, Material: "clear",
+		Defects: []models.SheetDefect{{X: 900, Y: 900, Width: 200, Height: 50}}}
+	if err := store.CreateGlassSheet(outside); !models.IsValidationError(err) {
+		t.Errorf("Defect outside the sheet: got %v, want validation error", err)
+	}
+}
@@ -43,6 +43,7 @@ func (s *DesignerService) CreateDesign(req *models.DesignRequest, userID int64)
 		Width:       req.Width,
 		Height:      req.Height,
 		Thickness:   req.Thickness,
+		Grain:       req.Grain,
 		Elements:    req.Elements,
 		UserID:      userID,
 	}
@@ -149,6 +150,7 @@ func (s *DesignerService) UpdateDesign(id int, req *models.DesignRequest, userID
 	existing.Width = req.Width
 	existing.Height = req.Height
 	existing.Thickness = req.Thickness
+	existing.Grain = req.Grain
 	existing.Elements = req.Elements
 	existing.UserID = userID
 	existing.UpdatedAt = time.Now()
@@ -432,6 +434,10 @@ func (s *DesignerService) validateDesignRequest(req *models.DesignRequest) error
 	models.ValidateRange(req.Height, 1, 10000, "height", errors)
 	models.ValidateRange(req.Thickness, 0.1, 50, "thickness", errors)
 
+	if req.Grain != "" {
+		models.ValidateEnum(string(req.Grain), models.Directions, "grain", errors)
+	}
+
 	if errors.HasErrors() {
 		return errors
 	}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"glass-optimizer/internal/models"
+)
+
+// Exact Algorithm
+//
+// For small jobs the exact algorithm proves how few sheets the pieces fit
+// on. The best MaxRects layout over every piece order is the layout to beat,
+// and the area and L2 bounds give the fewest sheets any layout could use.
+// Branch and bound then tries each sheet count from the lower bound up:
+// pieces are assigned to sheets largest first, and every sheet is checked by
+// placing its pieces at the corner points of the pieces already placed,
+// which finds a packing of the sheet whenever there is one. The first count
+// that fits is the minimum. A count that does not fit raises the lower bound,
+// so a search cut short still narrows the gap.
+//
+// Pieces are placed with the footprints of the MaxRects packer: grown by the
+// spacing on their right and top, on a usable area grown by the same amount.
+
+const (
+	// maxExactPieces is the most pieces the exact algorithm takes
+	maxExactPieces = 20
+
+	// exactNodeLimit is the most placements the search tries, so that a hard
+	// job without a time limit still finishes
+	exactNodeLimit = 5000000
+
+	// exactEpsilon absorbs rounding when footprints are compared with the sheet
+	exactEpsilon = 1e-9
+)
+
+// exactItem is a piece as the exact search sees it
+type exactItem struct {
+	piece        int           // Index of the piece
+	kind         int           // Items of the same kind have the same footprints
+	orientations []Orientation // Orientations the piece may take
+	area         float64       // Footprint area
+}
+
+// exactPlacement is an item placed on a sheet, relative to the corner of the
+// grown usable area
+type exactPlacement struct {
+	item        int // Index of the item in the sheet's item list
+	x, y        float64
+	orientation int
+}
+
+// exactSearch looks for the fewest sheets the items fit on
+type exactSearch struct {
+	ctx           context.Context
+	items         []exactItem // Largest first
+	gap           float64
+	width, height float64 // Grown usable area
+	nodes         int
+	aborted       bool                        // Cut short by ctx or the node limit
+	sheets        map[string][]exactPlacement // Packings of sheets by item kinds; nil when they do not fit
+}
+
+// checkExact rejects a run the exact algorithm cannot take: more than
+// maxExactPieces pieces, or more than one sheet size
+func checkExact(stock *sheetStock, pieces []PieceToPlace) error {
+	if len(pieces) > maxExactPieces {
+		return models.NewValidationError(fmt.Sprintf(
+			"the exact algorithm takes at most %d pieces, got %d", maxExactPieces, len(pieces)))
+	}
+	if len(stock.sheets) != 1 {
+		return models.NewValidationError("the exact algorithm cuts a single sheet size")
+	}
+	return nil
+}
+
+// runExact lays out up to maxExactPieces pieces on the fewest full sheets of
+// a single size and records the fewest sheets proven possible in the layout
+// statistics. Sheets with defects are not searched; their layout is the best
+// MaxRects layout, measured against the lower bound.
+func (s *OptimizerService) runExact(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	if err := checkExact(stock, pieces); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("Running exact algorithm", "pieces", len(pieces))
+
+	// Remnants come in every size, so the search only counts full sheets
+	full := &sheetStock{sheet: stock.sheet, sheets: stock.sheets}
+	sheet := stock.sheets[0].sheet
+
+	pack := func(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace) {
+		return s.placeMaxRects(ctx, sheet, pieces, options, maxRectsBestShortSideFit)
+	}
+	heuristic := *options
+	heuristic.SortBy = sortAll
+	heuristic.Improve = false
+	best := s.packOrdered(ctx, full, append([]PieceToPlace(nil), pieces...), &heuristic, pack)
+
+	lower := s.sheetLowerBound(sheet, pieces, options)
+	upper := len(best.Sheets)
+	if lower == 0 || len(best.AllPieces()) < countPieces(pieces) {
+		// Some piece fits on no sheet, or the sheets in stock ran out
+		return best, nil
+	}
+
+	if len(sheet.Defects) > 0 {
+		s.logger.Debug("Sheet has defects, exact search skipped", "sheets", upper, "lower_bound", lower)
+	} else {
+		search := newExactSearch(ctx, s, sheet, pieces, options)
+		for count := lower; count < upper; count++ {
+			sheets, found := search.pack(count)
+			if search.aborted {
+				break
+			}
+			if found {
+				best = s.exactLayout(ctx, full, sheet, pieces, search.items, sheets, options, pack)
+				reportProgress(ctx, layoutProgress(best))
+				break
+			}
+			lower = count + 1
+		}
+		if !search.aborted {
+			lower = len(best.Sheets)
+		}
+
+		s.logger.Debug("Exact algorithm finished", "sheets", len(best.Sheets), "lower_bound", lower,
+			"nodes", search.nodes, "aborted", search.aborted)
+	}
+
+	best.Statistics.SheetLowerBound = lower
+	return best, nil
+}
+
+// newExactSearch prepares the pieces for the exact search, largest first
+func newExactSearch(ctx context.Context, s *OptimizerService, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) *exactSearch {
+	gap := pieceSpacing(options)
+	area := usableArea(sheet, options)
+	search := &exactSearch{
+		ctx:    ctx,
+		gap:    gap,
+		width:  area.Width + gap,
+		height: area.Height + gap,
+		sheets: make(map[string][]exactPlacement),
+	}
+
+	kinds := make(map[string]int)
+	for i, piece := range pieces {
+		orientations := s.getOrientations(piece, options.AllowRotation)
+		key := ""
+		for _, orientation := range orientations {
+			key += fmt.Sprintf("%gx%g;", orientation.Width, orientation.Height)
+		}
+		kind, ok := kinds[key]
+		if !ok {
+			kind = len(kinds)
+			kinds[key] = kind
+		}
+
+		search.items = append(search.items, exactItem{
+			piece:        i,
+			kind:         kind,
+			orientations: orientations,
+			area:         (piece.Width + gap) * (piece.Height + gap),
+		})
+	}
+	sort.SliceStable(search.items, func(i, j int) bool {
+		if search.items[i].area != search.items[j].area {
+			return search.items[i].area > search.items[j].area
+		}
+		return search.items[i].kind < search.items[j].kind
+	})
+
+	return search
+}
+
+// pack assigns the items to count sheets, largest first, and returns the
+// packing of every sheet. Each item goes on a sheet already opened or, while
+// there are fewer than count, on a new one.
+func (search *exactSearch) pack(count int) ([][]exactPlacement, bool) {
+	sheetArea := search.width * search.height
+	left := make([]float64, len(search.items)+1) // Area of the items from i on
+	for i := len(search.items) - 1; i >= 0; i-- {
+		left[i] = left[i+1] + search.items[i].area
+	}
+
+	var sheets [][]int
+	var used []float64
+	var assign func(i int) bool
+	assign = func(i int) bool {
+		if i == len(search.items) {
+			return true
+		}
+		if search.aborted {
+			return false
+		}
+
+		free := float64(count) * sheetArea
+		for _, area := range used {
+			free -= area
+		}
+		if left[i] > free+exactEpsilon {
+			return false
+		}
+
+		item := search.items[i]
+		tried := make(map[string]bool)
+		for b := range sheets {
+			// Sheets holding the same kinds of items are interchangeable
+			key := search.kindsKey(sheets[b])
+			if tried[key] || used[b]+item.area > sheetArea+exactEpsilon {
+				continue
+			}
+			tried[key] = true
+
+			items := append(sheets[b][:len(sheets[b]):len(sheets[b])], i)
+			if search.packSheet(items) == nil {
+				continue
+			}
+			sheets[b], used[b] = items, used[b]+item.area
+			if assign(i + 1) {
+				return true
+			}
+			sheets[b], used[b] = items[:len(items)-1], used[b]-item.area
+		}
+
+		if len(sheets) < count && search.packSheet([]int{i}) != nil {
+			sheets, used = append(sheets, []int{i}), append(used, item.area)
+			if assign(i + 1) {
+				return true
+			}
+			sheets, used = sheets[:len(sheets)-1], used[:len(used)-1]
+		}
+		return false
+	}
+
+	if !assign(0) {
+		return nil, false
+	}
+
+	packings := make([][]exactPlacement, len(sheets))
+	for b, items := range sheets {
+		sorted := search.byKind(items)
+		for _, placement := range search.packSheet(items) {
+			placement.item = sorted[placement.item]
+			packings[b] = append(packings[b], placement)
+		}
+	}
+	return packings, true
+}
+
+// kindsKey names the kinds of the given items, so that sheets holding the
+// same kinds share their packing
+func (search *exactSearch) kindsKey(items []int) string {
+	var key strings.Builder
+	for _, i := range search.byKind(items) {
+		key.WriteString(strconv.Itoa(search.items[i].kind))
+		key.WriteByte(',')
+	}
+	return key.String()
+}
+
+// byKind returns the items sorted by kind
+func (search *exactSearch) byKind(items []int) []int {
+	sorted := append([]int(nil), items...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return search.items[sorted[a]].kind < search.items[sorted[b]].kind
+	})
+	return sorted
+}
+
+// packSheet returns a packing of the items on one sheet, with the items
+// indexed as sorted by byKind, or nil when they do not fit. Packings are
+// remembered by the kinds of their items.
+func (search *exactSearch) packSheet(items []int) []exactPlacement {
+	key := search.kindsKey(items)
+	if packing, ok := search.sheets[key]; ok {
+		return packing
+	}
+
+	sorted := search.byKind(items)
+	placed := make([]exactPlacement, 0, len(sorted))
+	used := make([]bool, len(sorted))
+	area := 0.0
+	for _, i := range sorted {
+		area += search.items[i].area
+	}
+
+	var place func(area float64) bool
+	place = func(area float64) bool {
+		if len(placed) == len(sorted) {
+			return true
+		}
+
+		corners, envelope := search.corners(sorted, placed)
+		if area > search.width*search.height-envelope+exactEpsilon {
+			return false
+		}
+
+		for k, i := range sorted {
+			// Items of the same kind are interchangeable, so only the first
+			// unplaced one of each kind is tried
+			if used[k] || (k > 0 && !used[k-1] && search.items[sorted[k-1]].kind == search.items[i].kind) {
+				continue
+			}
+
+			item := search.items[i]
+			for o, orientation := range item.orientations {
+				width, height := orientation.Width+search.gap, orientation.Height+search.gap
+				for _, corner := range corners {
+					if corner.X+width > search.width+exactEpsilon || corner.Y+height > search.height+exactEpsilon {
+						continue
+					}
+
+					search.nodes++
+					if search.nodes > exactNodeLimit || search.ctx.Err() != nil {
+						search.aborted = true
+						return false
+					}
+
+					used[k] = true
+					placed = append(placed, exactPlacement{item: k, x: corner.X, y: corner.Y, orientation: o})
+					if place(area - item.area) {
+						return true
+					}
+					used[k] = false
+					placed = placed[:len(placed)-1]
+					if search.aborted {
+						return false
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	var packing []exactPlacement
+	if place(area) {
+		packing = placed
+	}
+	if !search.aborted {
+		search.sheets[key] = packing
+	}
+	return packing
+}
+
+// corners returns the corner points of the envelope of the placed items,
+// where the next item may go, and the area under the envelope. The envelope
+// is the staircase of the top right corners of the placed footprints; an item
+// placed at one of its corners lies clear of all of them.
+func (search *exactSearch) corners(items []int, placed []exactPlacement) ([]Rectangle, float64) {
+	if len(placed) == 0 {
+		return []Rectangle{{}}, 0
+	}
+
+	tops := make([]Rectangle, len(placed))
+	for i, placement := range placed {
+		orientation := search.items[items[placement.item]].orientations[placement.orientation]
+		tops[i] = Rectangle{
+			X: placement.x + orientation.Width + search.gap,
+			Y: placement.y + orientation.Height + search.gap,
+		}
+	}
+
+	// The steps of the staircase are the top right corners no other corner
+	// lies above and to the right of, from left to right
+	sort.Slice(tops, func(i, j int) bool {
+		if tops[i].X != tops[j].X {
+			return tops[i].X > tops[j].X
+		}
+		return tops[i].Y > tops[j].Y
+	})
+	var steps []Rectangle
+	for _, top := range tops {
+		if len(steps) == 0 || top.Y > steps[len(steps)-1].Y+exactEpsilon {
+			steps = append(steps, top)
+		}
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+
+	corners := []Rectangle{{X: 0, Y: steps[0].Y}}
+	envelope, x := 0.0, 0.0
+	for i, step := range steps {
+		envelope += (step.X - x) * step.Y
+		x = step.X
+		next := 0.0
+		if i+1 < len(steps) {
+			next = steps[i+1].Y
+		}
+		corners = append(corners, Rectangle{X: step.X, Y: next})
+	}
+	return corners, envelope
+}
+
+// exactLayout builds the layout of the sheets found by the search. Sheets
+// holding the most urgent pieces are cut first.
+func (s *OptimizerService) exactLayout(ctx context.Context, stock *sheetStock, sheet *models.GlassSheet, pieces []PieceToPlace, items []exactItem, packings [][]exactPlacement, options *models.OptimizeOptions, pack sheetPacker) *models.Layout {
+	mostUrgent := func(packing []exactPlacement) *PieceToPlace {
+		var urgent *PieceToPlace
+		for _, placement := range packing {
+			piece := &pieces[items[placement.item].piece]
+			if urgent == nil || moreUrgent(piece, urgent) {
+				urgent = piece
+			}
+		}
+		return urgent
+	}
+	sort.SliceStable(packings, func(i, j int) bool {
+		return moreUrgent(mostUrgent(packings[i]), mostUrgent(packings[j]))
+	})
+
+	area := usableArea(sheet, options)
+	filled := make([]filledSheet, len(packings))
+	for n, packing := range packings {
+		var sheetPieces []PieceToPlace
+		var placed []models.PlacedPiece
+		for _, placement := range packing {
+			item := items[placement.item]
+			piece := pieces[item.piece]
+			orientation := item.orientations[placement.orientation]
+			placed = append(placed, placePiece(piece, area.X+placement.x, area.Y+placement.y, orientation))
+			sheetPieces = append(sheetPieces, piece)
+		}
+		filled[n] = filledSheet{
+			sheet:  sheet,
+			pieces: placeInnerPieces(n+1, sheetPieces, placed, nil),
+			placed: sheetPieces,
+		}
+	}
+
+	return s.finishLayout(ctx, stock, filled, nil, options, pack.filler())
+}
+
+// sheetLowerBound returns the fewest sheets of the given size the pieces can
+// be laid out on, or 0 when a piece fits on no sheet. It is the larger of the
+// area bound and the L2 bound of Martello and Vigo. For every pair of
+// thresholds p and q, pieces longer than W-p and taller than H-q each take a
+// sheet no piece at least p by q can share; pieces more than half the sheet
+// both ways each take a sheet of their own; and the area of the pieces at
+// least p by q must fit in what those sheets leave free and further sheets.
+// A piece counts as long or tall only when it is in every orientation it may
+// take. Defects are ignored, which can only lower the bound.
+func (s *OptimizerService) sheetLowerBound(sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) int {
+	gap := pieceSpacing(options)
+	usable := usableArea(sheet, options)
+	width, height := usable.Width+gap, usable.Height+gap
+	sheetArea := width * height
+
+	type footprint struct{ width, height, area float64 }
+	footprints := make([]footprint, 0, len(pieces))
+	totalArea := 0.0
+	for _, piece := range pieces {
+		fp := footprint{width: math.MaxFloat64, height: math.MaxFloat64}
+		fits := false
+		for _, orientation := range s.getOrientations(piece, options.AllowRotation) {
+			w, h := orientation.Width+gap, orientation.Height+gap
+			if w > width+exactEpsilon || h > height+exactEpsilon {
+				continue
+			}
+			fits = true
+			fp.width, fp.height, fp.area = math.Min(fp.width, w), math.Min(fp.height, h), w*h
+		}
+		if !fits {
+			return 0
+		}
+		footprints = append(footprints, fp)
+		totalArea += fp.area
+	}
+
+	sheets := func(area float64) int {
+		return max(0, int(math.Ceil(area/sheetArea-exactEpsilon)))
+	}
+
+	thresholdsP, thresholdsQ := []float64{0}, []float64{0}
+	for _, fp := range footprints {
+		if fp.width <= width/2 {
+			thresholdsP = append(thresholdsP, fp.width)
+		}
+		if fp.height <= height/2 {
+			thresholdsQ = append(thresholdsQ, fp.height)
+		}
+	}
+
+	bound := sheets(totalArea)
+	for _, p := range thresholdsP {
+		for _, q := range thresholdsQ {
+			alone, large, area := 0, 0, 0.0
+			for _, fp := range footprints {
+				switch {
+				case fp.width > width-p+exactEpsilon && fp.height > height-q+exactEpsilon:
+					alone++
+				case fp.width > width/2+exactEpsilon && fp.height > height/2+exactEpsilon:
+					large++
+					area += fp.area
+				case fp.width >= p && fp.height >= q:
+					area += fp.area
+				}
+			}
+			bound = max(bound, alone+large+sheets(area-float64(large)*sheetArea))
+		}
+	}
+
+	return bound
+}
+
+// countPieces returns the number of pieces, counting those carried in hole
+// drop-outs
+func countPieces(pieces []PieceToPlace) int {
+	count := len(pieces)
+	for _, piece := range pieces {
+		count += len(piece.Inner)
+	}
+	return count
+}
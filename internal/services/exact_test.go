@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestExactAlgorithmProvesTheFewestSheets(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	// The best MaxRects layout needs seven sheets; six are enough
+	designs := []models.DesignItem{
+		{DesignID: 0, Name: "Panel", Width: 1500, Height: 1700, Quantity: 4},
+		{DesignID: 0, Name: "Door", Width: 1100, Height: 2100, Quantity: 2},
+		{DesignID: 0, Name: "Strip", Width: 500, Height: 1900, Quantity: 5},
+		{DesignID: 0, Name: "Pane", Width: 1200, Height: 1000, Quantity: 1},
+	}
+	run := func(algorithm string) *models.Optimization {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name:      "Exact " + algorithm,
+			SheetID:   1, // Standard 2m x 3m
+			Algorithm: algorithm,
+			Designs:   designs,
+			Options:   models.OptimizeOptions{AllowRotation: true},
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+		assertLegalLayout(t, &optimization.Layout)
+		if got := len(optimization.Layout.AllPieces()); got != 12 {
+			t.Fatalf("Placed pieces incorrect: got %d, want %d", got, 12)
+		}
+		return optimization
+	}
+
+	exact := run("exact")
+	stats := exact.Layout.Statistics
+	if stats.SheetsUsed != 6 || stats.SheetLowerBound != 6 || stats.SheetGap != 0 || !stats.Optimal {
+		t.Errorf("Exact statistics incorrect: %d sheets, bound %d, gap %d, optimal %v; want 6 sheets, proven optimal",
+			stats.SheetsUsed, stats.SheetLowerBound, stats.SheetGap, stats.Optimal)
+	}
+
+	// Other algorithms report the gap to the lower bound
+	heuristic := run("maxrects-bssf").Layout.Statistics
+	if heuristic.SheetLowerBound != 6 || heuristic.SheetGap != heuristic.SheetsUsed-6 || heuristic.SheetGap < 1 || heuristic.Optimal {
+		t.Errorf("MaxRects statistics incorrect: %d sheets, bound %d, gap %d, optimal %v",
+			heuristic.SheetsUsed, heuristic.SheetLowerBound, heuristic.SheetGap, heuristic.Optimal)
+	}
+
+	export, err := service.ExportOptimization(exact.ID, userID, "cutting_list")
+	if err != nil {
+		t.Fatalf("ExportOptimization() error = %v", err)
+	}
+	if list := export.Data.(string); !strings.Contains(list, "Fewest Sheets Possible: yes\n") {
+		t.Errorf("Cutting list does not report the optimal sheet count:\n%s", list)
+	}
+
+	req := &models.OptimizationRequest{Name: "Too many", SheetID: 1, Algorithm: "exact",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: maxExactPieces + 1}}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Too many pieces: got %v, want validation error", err)
+	}
+}
+
+func TestSheetLowerBound(t *testing.T) {
+	service, _, _ := newTestOptimizer(t)
+	sheet := &models.GlassSheet{Width: 2000, Height: 3000}
+
+	pieces := func(width, height float64, quantity int) []PieceToPlace {
+		list := make([]PieceToPlace, quantity)
+		for i := range list {
+			list[i] = PieceToPlace{Width: width, Height: height, serial: i}
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		pieces   []PieceToPlace
+		rotation bool
+		want     int
+	}{
+		{"area", pieces(600, 600, 40), true, 3},
+		{"more than half both ways", pieces(1100, 1600, 3), false, 3},
+		{"turned to share a sheet", pieces(1100, 1600, 3), true, 1},
+		{"no room beside a large piece", append(pieces(1800, 2800, 1), pieces(300, 300, 4)...), true, 2},
+		{"too large", pieces(2100, 3100, 1), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &models.OptimizeOptions{AllowRotation: tt.rotation, MinimumGap: 2, EdgeMargin: 5}
+			if got := service.sheetLowerBound(sheet, tt.pieces, options); got != tt.want {
+				t.Errorf("sheetLowerBound() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
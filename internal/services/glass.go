@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"glass-optimizer/internal/models"
+)
+
+// glassType identifies the glass a piece is cut from
+type glassType struct {
+	thickness float64
+	material  string
+}
+
+func (g glassType) String() string {
+	return fmt.Sprintf("%gmm %s", g.thickness, g.material)
+}
+
+// sheetGlass returns the glass a sheet is made of
+func sheetGlass(sheet *models.GlassSheet) glassType {
+	return glassType{thickness: sheet.Thickness, material: sheet.Material}
+}
+
+// itemGlass returns the glass a request item is cut from. Items that do not
+// say take the thickness or material of the sheet.
+func itemGlass(item models.DesignItem, design *models.Design, sheet *models.GlassSheet) glassType {
+	glass := sheetGlass(sheet)
+	if design != nil && design.Thickness > 0 {
+		glass.thickness = design.Thickness
+	}
+	if item.Material != "" {
+		glass.material = item.Material
+	}
+	return glass
+}
+
+// checkSheetGlass rejects resolved items that are not the same glass as the sheet
+func checkSheetGlass(sheet *models.GlassSheet, items []models.DesignItem) error {
+	want := sheetGlass(sheet)
+	for _, item := range items {
+		if glass := itemGlass(item, item.Design, sheet); glass != want {
+			return models.NewThicknessMismatchError(fmt.Sprintf(
+				"%s is %s glass but sheet %d is %s; set split_by_glass to optimize each glass type separately",
+				item.Design.Name, glass, sheet.ID, want))
+		}
+	}
+	return nil
+}
+
+// RunOptimizationSet groups the pieces of a request by thickness and material
+// and runs one optimization per glass type, linked by a shared batch ID. Pieces
+// of the request sheet's glass are cut from the sheets the request names;
+// other glass types choose among the in-stock sheets of that glass.
+func (s *OptimizerService) RunOptimizationSet(ctx context.Context, req *models.OptimizationRequest, userID int64) ([]*models.Optimization, error) {
+	if userID == 0 {
+		return nil, models.NewValidationError("user ID is required")
+	}
+
+	if err := s.validateOptimizationRequest(req); err != nil {
+		return nil, err
+	}
+
+	sheet, err := s.storage.GetGlassSheet(requestSheetID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	designs, err := s.loadDesignsForOptimization(req.Designs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the items by glass in request order
+	var glasses []glassType
+	groups := make(map[glassType][]models.DesignItem)
+	for _, item := range req.Designs {
+		glass := itemGlass(item, s.resolveDesign(item, designs, sheet, userID), sheet)
+		if _, ok := groups[glass]; !ok {
+			glasses = append(glasses, glass)
+		}
+
+		item.Material = glass.material
+		item.Thickness = glass.thickness
+		groups[glass] = append(groups[glass], item)
+	}
+
+	// Find sheets for every glass type before running any of them
+	requests := make([]*models.OptimizationRequest, len(glasses))
+	for i, glass := range glasses {
+		sub := *req
+		sub.Designs = groups[glass]
+		sub.SplitByGlass = false
+		if len(glasses) > 1 {
+			sub.Name = fmt.Sprintf("%s (%s)", req.Name, glass)
+		}
+
+		if glass != sheetGlass(sheet) {
+			sheets, err := s.storage.GetInStockGlassSheets(glass.material, glass.thickness)
+			if err != nil {
+				return nil, err
+			}
+			if len(sheets) == 0 {
+				return nil, models.NewInsufficientStockError(fmt.Sprintf("no %s sheets in stock", glass))
+			}
+
+			sub.SheetID = sheets[0].ID
+			sub.SheetIDs = nil
+			sub.MatchingSheets = true
+		}
+
+		requests[i] = &sub
+	}
+
+	batchID, err := newBatchID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Starting optimization set", "name", req.Name, "batch_id", batchID, "glass_types", len(glasses))
+
+	// Check every glass type before running any of them, so that a request
+	// one of them cannot run leaves nothing stored
+	runs := make([]*preparedOptimization, len(requests))
+	for i, sub := range requests {
+		if runs[i], err = s.prepareOptimization(sub, userID, batchID); err != nil {
+			return nil, err
+		}
+	}
+
+	optimizations := make([]*models.Optimization, 0, len(runs))
+	for i, run := range runs {
+		// Once the run is cancelled or out of time no glass type is started,
+		// which would only save an empty layout
+		if i > 0 && ctx.Err() != nil {
+			s.logger.Warn("Optimization set stopped early", "batch_id", batchID, "ran", i, "glass_types", len(runs))
+			break
+		}
+
+		optimization, err := s.executeOptimization(ctx, run)
+		if err != nil {
+			s.discardOptimizations(optimizations, userID)
+			return nil, err
+		}
+		optimizations = append(optimizations, optimization)
+	}
+
+	return optimizations, nil
+}
+
+// discardOptimizations deletes optimizations the caller never learns of, such
+// as those of a set that failed part way, with their pending remnants
+func (s *OptimizerService) discardOptimizations(optimizations []*models.Optimization, userID int64) {
+	for _, optimization := range optimizations {
+		if err := s.storage.DeleteOptimization(optimization.ID, userID); err != nil {
+			s.logger.Error("Failed to discard optimization", "error", err, "id", optimization.ID)
+		}
+	}
+}
+
+// GetOptimizationBatch retrieves the optimizations of a request split by glass type
+func (s *OptimizerService) GetOptimizationBatch(batchID string, userID int64) ([]models.Optimization, error) {
+	if userID == 0 {
+		return nil, models.NewValidationError("user ID is required")
+	}
+
+	optimizations, err := s.storage.GetOptimizationBatch(batchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(optimizations) == 0 {
+		return nil, models.NewNotFoundError("optimization batch")
+	}
+
+	for i := range optimizations {
+		if err := s.attachSheet(&optimizations[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return optimizations, nil
+}
+
+// newBatchID returns a random identifier for a set of linked optimizations
+func newBatchID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", models.NewInternalError("failed to generate batch ID", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/storage"
+)
+
+func TestOptimizationSplitByGlass(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	thick := &models.GlassSheet{Name: "Clear 10mm", Width: 2000, Height: 3000, Thickness: 10, PricePerSqm: 70, InStock: 4, Material: "clear"}
+	if err := store.CreateGlassSheet(thick); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+
+	req := &models.OptimizationRequest{
+		Name:      "Shopfront",
+		SheetID:   1, // 6mm clear
+		Algorithm: "blf",
+		Designs: []models.DesignItem{
+			{DesignID: 0, Name: "Window", Width: 800, Height: 1200, Quantity: 2},
+			{DesignID: 0, Name: "Shelf", Width: 1000, Height: 400, Thickness: 10, Quantity: 3},
+		},
+	}
+
+	_, err := service.RunOptimization(context.Background(), req, userID)
+	if appErr, ok := err.(*models.AppError); !ok || appErr.Code != models.CodeThicknessMismatch {
+		t.Fatalf("Mixed glass on one sheet: got %v, want %s", err, models.CodeThicknessMismatch)
+	}
+
+	optimizations, err := service.RunOptimizationSet(context.Background(), req, userID)
+	if err != nil {
+		t.Fatalf("RunOptimizationSet() error = %v", err)
+	}
+	if len(optimizations) != 2 {
+		t.Fatalf("Optimizations: got %d, want one per glass type", len(optimizations))
+	}
+
+	batchID := optimizations[0].BatchID
+	if batchID == "" || optimizations[1].BatchID != batchID {
+		t.Errorf("Optimizations are not linked: %q, %q", batchID, optimizations[1].BatchID)
+	}
+	for i, want := range []struct {
+		sheetID int
+		pieces  int
+	}{{1, 2}, {thick.ID, 3}} {
+		if optimizations[i].SheetID != want.sheetID {
+			t.Errorf("Optimization %d sheet: got %d, want %d", i, optimizations[i].SheetID, want.sheetID)
+		}
+		if placed := len(optimizations[i].Layout.AllPieces()); placed != want.pieces {
+			t.Errorf("Optimization %d pieces: got %d, want %d", i, placed, want.pieces)
+		}
+	}
+
+	batch, err := service.GetOptimizationBatch(batchID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimizationBatch() error = %v", err)
+	}
+	if len(batch) != 2 || batch[0].ID != optimizations[0].ID {
+		t.Errorf("Batch incorrect: got %d optimizations", len(batch))
+	}
+
+	// Nothing is run when one of the glass types is out of stock
+	req.Designs = append(req.Designs, models.DesignItem{DesignID: 0, Name: "Door", Width: 900, Height: 2100, Material: "laminated", Quantity: 1})
+	_, err = service.RunOptimizationSet(context.Background(), req, userID)
+	if appErr, ok := err.(*models.AppError); !ok || appErr.Code != models.CodeInsufficientStock {
+		t.Errorf("Glass out of stock: got %v, want %s", err, models.CodeInsufficientStock)
+	}
+	if all, _, _ := store.GetOptimizations(userID, 100, 0); len(all) != 2 {
+		t.Errorf("Optimizations saved: got %d, want 2", len(all))
+	}
+}
+
+// failingStorage fails to save the optimization of the given call, counting from 1
+type failingStorage struct {
+	storage.Storage
+	saves, failAt int
+}
+
+func (f *failingStorage) CreateOptimization(opt *models.Optimization) error {
+	f.saves++
+	if f.saves == f.failAt {
+		return models.NewDatabaseError("failed to create optimization", errors.New("disk full"))
+	}
+	return f.Storage.CreateOptimization(opt)
+}
+
+func TestOptimizationSetLeavesNothingWhenAGroupFails(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	thick := &models.GlassSheet{Name: "Clear 10mm", Width: 2000, Height: 3000, Thickness: 10, PricePerSqm: 70, InStock: 4, Material: "clear"}
+	if err := store.CreateGlassSheet(thick); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+	request := func(algorithm string, shelves int) *models.OptimizationRequest {
+		return &models.OptimizationRequest{
+			Name: "Shopfront", SheetID: 1, Algorithm: algorithm,
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Window", Width: 800, Height: 1200, Quantity: 2},
+				{DesignID: 0, Name: "Shelf", Width: 1000, Height: 400, Thickness: 10, Quantity: shelves},
+			},
+			Options: models.OptimizeOptions{MinRemnantSize: 300},
+		}
+	}
+	assertNothingStored := func(name string) {
+		t.Helper()
+		if all, _, _ := store.GetOptimizations(userID, 100, 0); len(all) != 0 {
+			t.Errorf("%s: optimizations saved: got %d, want 0", name, len(all))
+		}
+		if pending, _, _ := store.GetRemnants(models.RemnantStatusPending, 100, 0); len(pending) != 0 {
+			t.Errorf("%s: pending remnants saved: got %d, want 0", name, len(pending))
+		}
+	}
+
+	// The second glass type has too many pieces for the exact algorithm
+	if _, err := service.RunOptimizationSet(context.Background(), request("exact", 25), userID); !models.IsValidationError(err) {
+		t.Errorf("Too many pieces for exact: got %v, want validation error", err)
+	}
+	assertNothingStored("Too many pieces")
+
+	// Saving the second glass type fails after the first is stored
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	failing := NewOptimizerService(&failingStorage{Storage: store, failAt: 2}, logger)
+	if _, err := failing.RunOptimizationSet(context.Background(), request("blf", 3), userID); err == nil {
+		t.Error("RunOptimizationSet() with a failing save succeeded")
+	}
+	assertNothingStored("Failed save")
+
+	// A cancelled set keeps the first glass type and starts no other
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	optimizations, err := service.RunOptimizationSet(ctx, request("blf", 3), userID)
+	if err != nil {
+		t.Fatalf("RunOptimizationSet() error = %v", err)
+	}
+	if len(optimizations) != 1 || !optimizations[0].Layout.Partial {
+		t.Errorf("Cancelled set incorrect: got %d optimizations, want 1 partial", len(optimizations))
+	}
+}
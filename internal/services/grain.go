@@ -0,0 +1,65 @@
+package services
+
+import (
+	"glass-optimizer/internal/models"
+)
+
+// grainLock limits the rotations of a piece so that the pattern of the sheet
+// runs through it the way its design asks
+type grainLock int
+
+const (
+	grainFree    grainLock = iota // Turns as the options allow
+	grainUpright                  // Keeps its orientation, or turns half a turn
+	grainTurned                   // Turns a quarter or three quarters of a turn
+)
+
+// pieceGrain returns the lock that makes a sheet pattern run the way the
+// design asks. Pieces without a grain, and all pieces cut from plain glass,
+// are free.
+func pieceGrain(grain, pattern models.Direction) grainLock {
+	switch {
+	case grain == "" || pattern == "":
+		return grainFree
+	case grain == pattern:
+		return grainUpright
+	default:
+		return grainTurned
+	}
+}
+
+// innerGrain returns the lock of a piece cut from a hole drop-out relative to
+// the piece around it, which turns it further. A locked piece cannot be cut
+// from a piece that may turn freely.
+func innerGrain(inner, host grainLock, allowRotation bool) (grainLock, bool) {
+	if host == grainFree && !allowRotation {
+		host = grainUpright
+	}
+
+	switch {
+	case inner == grainFree:
+		return grainFree, true
+	case host == grainFree:
+		return grainFree, false
+	case inner == host:
+		return grainUpright, true
+	default:
+		return grainTurned, true
+	}
+}
+
+// patternIn returns the way the pattern of a sheet runs through a piece
+// placed on it with the given rotation, in the piece's own orientation. It is
+// empty for plain glass and for pieces at an angle to the pattern.
+func patternIn(pattern models.Direction, rotation int) models.Direction {
+	if pattern == "" || rotation%90 != 0 {
+		return ""
+	}
+	if rotation%180 == 0 {
+		return pattern
+	}
+	if pattern == models.DirectionVertical {
+		return models.DirectionHorizontal
+	}
+	return models.DirectionVertical
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestPackersKeepPieceGrain(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	sheet := &models.GlassSheet{
+		Name: "Reeded 2m x 3m", Width: 2000, Height: 3000, Thickness: 6, PricePerSqm: 60, InStock: 5, Material: "clear",
+		Specs: models.GlassSpecs{Pattern: models.DirectionVertical},
+	}
+	if err := store.CreateGlassSheet(sheet); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+
+	algorithms := []string{"blf", "genetic", "greedy", "guillotine", "maxrects-bssf", "skyline", "nfp"}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+				Name:      "Grain " + algorithm,
+				SheetID:   sheet.ID,
+				Algorithm: algorithm,
+				Designs: []models.DesignItem{
+					{DesignID: 0, Name: "Upright", Width: 300, Height: 700, Quantity: 8, Grain: models.DirectionVertical},
+					{DesignID: 0, Name: "Across", Width: 300, Height: 700, Quantity: 8, Grain: models.DirectionHorizontal},
+					{DesignID: 0, Name: "Free", Width: 250, Height: 400, Quantity: 6},
+				},
+				Options: models.OptimizeOptions{AllowRotation: true, MaxIterations: 5, PopulationSize: 6},
+			}, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+			if got := len(optimization.Layout.AllPieces()); got != 22 {
+				t.Fatalf("Placed pieces incorrect: got %d, want %d", got, 22)
+			}
+			assertLegalLayout(t, &optimization.Layout)
+
+			for _, piece := range optimization.Layout.AllPieces() {
+				turned := piece.Rotation%180 != 0
+				switch piece.DesignName {
+				case "Upright":
+					if turned || piece.Pattern != models.DirectionVertical {
+						t.Errorf("Piece %s incorrect: rotation %d, pattern %q", piece.ID, piece.Rotation, piece.Pattern)
+					}
+				case "Across":
+					if !turned || piece.Pattern != models.DirectionHorizontal {
+						t.Errorf("Piece %s incorrect: rotation %d, pattern %q", piece.ID, piece.Rotation, piece.Pattern)
+					}
+				}
+			}
+
+			if algorithm != "blf" {
+				return
+			}
+			export, err := service.ExportOptimization(optimization.ID, userID, "cutting_list")
+			if err != nil {
+				t.Fatalf("ExportOptimization() error = %v", err)
+			}
+			list := export.Data.(string)
+			for _, want := range []string{"Pattern: vertical", "\tRotation\tPattern\n", "\tvertical\n", "\thorizontal\n"} {
+				if !strings.Contains(list, want) {
+					t.Errorf("Cutting list does not contain %q:\n%s", want, list)
+				}
+			}
+		})
+	}
+
+	req := &models.OptimizationRequest{Name: "Bad grain", SheetID: sheet.ID, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 1, Grain: "diagonal"}}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Invalid grain: got %v, want validation error", err)
+	}
+}
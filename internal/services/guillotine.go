@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"math"
+
+	"glass-optimizer/internal/models"
+)
+
+// Guillotine Algorithm (score-and-break cutting)
+//
+// Every cut runs edge to edge through the current panel. The usable area of
+// the sheet is cut into strips at stage X, each strip into sections at stage
+// Y, and so on up to the configured number of stages, alternating the cut
+// direction at each stage. A piece that does not fill its final panel is
+// trimmed to size.
+
+const maxGuillotineStages = 4
+
+// guillotineStageNames labels cut stages in cut path IDs
+var guillotineStageNames = []string{"sheet", "x", "y", "z", "w"}
+
+// guillotineEpsilon absorbs floating point noise when comparing panel edges
+const guillotineEpsilon = 1e-6
+
+func (s *OptimizerService) runGuillotine(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	stages := options.MaxCutStages
+	if stages == 0 {
+		stages = maxGuillotineStages
+	}
+
+	s.logger.Debug("Running Guillotine algorithm", "stages", stages)
+
+	fill := func(ctx context.Context, sheet *models.GlassSheet, sheetNumber int, pieces []PieceToPlace, options *models.OptimizeOptions) (filledSheet, []PieceToPlace) {
+		tree, placed, unplaced := s.placeGuillotine(ctx, sheet, pieces, options, stages, sheetNumber)
+		return filledSheet{
+			sheet:   sheet,
+			pieces:  placeInnerPieces(sheetNumber, pieces, placed, unplaced),
+			placed:  placedPieces(pieces, unplaced),
+			cutTree: tree,
+		}, unplaced
+	}
+
+	// Pieces early in the order open the strips; large ones by default
+	return s.orderedLayout(ctx, pieces, options, func(ctx context.Context, pieces []PieceToPlace) *models.Layout {
+		filled, unplaced := s.fillSheets(ctx, stock, pieces, options, fill)
+		return s.finishLayout(ctx, stock, filled, unplaced, options, fill)
+	}), nil
+}
+
+// placeGuillotine fills one sheet, trying both directions for the first stage
+// and keeping the one that covers more area
+func (s *OptimizerService) placeGuillotine(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions, stages, sheetNumber int) (*models.CutNode, []models.PlacedPiece, []PieceToPlace) {
+	var best *guillotinePacker
+	bestArea := -1.0
+
+	area := usableArea(sheet, options)
+	for _, vertical := range []bool{true, false} {
+		packer := &guillotinePacker{
+			ctx:         ctx,
+			service:     s,
+			options:     options,
+			stages:      stages,
+			sheetNumber: sheetNumber,
+			pieces:      pieces,
+			used:        make([]bool, len(pieces)),
+			defects:     defectAreas(sheet.Defects, pieceSpacing(options)),
+			root:        models.CutNode{X: area.X, Y: area.Y, Width: area.Width, Height: area.Height},
+		}
+		packer.fill(&packer.root, 1, vertical)
+
+		if area := s.calculateUsedArea(packer.placed); area > bestArea {
+			best = packer
+			bestArea = area
+		}
+	}
+
+	var unplaced []PieceToPlace
+	for i, piece := range pieces {
+		if !best.used[i] {
+			unplaced = append(unplaced, piece)
+		}
+	}
+
+	return &best.root, best.placed, unplaced
+}
+
+// guillotinePacker holds the state of filling one sheet
+type guillotinePacker struct {
+	ctx         context.Context
+	service     *OptimizerService
+	options     *models.OptimizeOptions
+	stages      int
+	sheetNumber int
+	pieces      []PieceToPlace
+	used        []bool
+	placed      []models.PlacedPiece
+	root        models.CutNode
+	defects     []Rectangle // Sheet defects grown by the piece spacing
+}
+
+// fill cuts the panel into strips at the given stage and fills each strip.
+// The first unused piece that fits opens a strip and sets its size; the strip
+// is then filled at the next stage with cuts in the other direction. A strip
+// that a defect keeps empty is cut off as waste up to the far side of the
+// defect.
+func (p *guillotinePacker) fill(panel *models.CutNode, stage int, vertical bool) {
+	offset := 0.0
+
+	for p.ctx.Err() == nil {
+		var index int
+		var orientation Orientation
+		var strip models.CutNode
+		var size float64
+
+		if vertical {
+			index, orientation = p.nextPiece(panel.Width-offset, panel.Height)
+			if index < 0 {
+				break
+			}
+			strip = models.CutNode{Stage: stage, X: panel.X + offset, Y: panel.Y, Width: orientation.Width, Height: panel.Height}
+			size = orientation.Width
+		} else {
+			index, orientation = p.nextPiece(panel.Width, panel.Height-offset)
+			if index < 0 {
+				break
+			}
+			strip = models.CutNode{Stage: stage, X: panel.X, Y: panel.Y + offset, Width: panel.Width, Height: orientation.Height}
+			size = orientation.Height
+		}
+
+		if stage == p.stages {
+			// Final stage: the strip holds a single piece, trimmed to size
+			piece := Rectangle{X: strip.X, Y: strip.Y, Width: orientation.Width, Height: orientation.Height}
+			if defect, blocked := p.service.firstOverlap(piece, p.defects); blocked {
+				offset = p.cutWaste(panel, stage, vertical, offset, defect)
+				continue
+			}
+			p.place(&strip, index, orientation)
+		} else {
+			placedBefore := len(p.placed)
+			p.fill(&strip, stage+1, !vertical)
+			if len(p.placed) == placedBefore {
+				area := Rectangle{X: strip.X, Y: strip.Y, Width: strip.Width, Height: strip.Height}
+				defect, blocked := p.service.firstOverlap(area, p.defects)
+				if !blocked {
+					break
+				}
+				offset = p.cutWaste(panel, stage, vertical, offset, defect)
+				continue
+			}
+		}
+
+		panel.Children = append(panel.Children, strip)
+		offset += size + pieceSpacing(p.options)
+	}
+
+	if len(panel.Children) > 0 {
+		if vertical {
+			panel.Direction = "vertical"
+		} else {
+			panel.Direction = "horizontal"
+		}
+	}
+}
+
+// cutWaste cuts the panel from the offset to the far side of a defect, or to
+// the end of the panel, as an empty strip and returns the offset past it
+func (p *guillotinePacker) cutWaste(panel *models.CutNode, stage int, vertical bool, offset float64, defect Rectangle) float64 {
+	waste := models.CutNode{Stage: stage, X: panel.X, Y: panel.Y, Width: panel.Width, Height: panel.Height}
+	if vertical {
+		end := math.Min(defect.X+defect.Width, panel.X+panel.Width)
+		waste.X = panel.X + offset
+		waste.Width = end - waste.X
+		offset = end - panel.X
+	} else {
+		end := math.Min(defect.Y+defect.Height, panel.Y+panel.Height)
+		waste.Y = panel.Y + offset
+		waste.Height = end - waste.Y
+		offset = end - panel.Y
+	}
+
+	panel.Children = append(panel.Children, waste)
+	return offset
+}
+
+// nextPiece returns the first unused piece and orientation that fits in the
+// given space, or -1 when none does
+func (p *guillotinePacker) nextPiece(width, height float64) (int, Orientation) {
+	for i, piece := range p.pieces {
+		if p.used[i] {
+			continue
+		}
+		for _, orientation := range p.service.getOrientations(piece, p.options.AllowRotation) {
+			if orientation.Width <= width+guillotineEpsilon && orientation.Height <= height+guillotineEpsilon {
+				return i, orientation
+			}
+		}
+	}
+	return -1, Orientation{}
+}
+
+func (p *guillotinePacker) place(leaf *models.CutNode, index int, orientation Orientation) {
+	placed := placePiece(p.pieces[index], leaf.X, leaf.Y, orientation)
+	placed.ID = placementID(p.sheetNumber, len(p.placed)+1)
+
+	leaf.PieceID = placed.ID
+	p.used[index] = true
+	p.placed = append(p.placed, placed)
+}
+
+// cutTreePieceIDs collects the IDs of all pieces inside a panel
+func cutTreePieceIDs(node *models.CutNode) []string {
+	var ids []string
+	if node.PieceID != "" {
+		ids = append(ids, node.PieceID)
+	}
+	for i := range node.Children {
+		ids = append(ids, cutTreePieceIDs(&node.Children[i])...)
+	}
+	return ids
+}
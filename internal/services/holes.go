@@ -0,0 +1,287 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"glass-optimizer/internal/models"
+)
+
+// innerPiece is a piece cut from the drop-out of a hole in another piece,
+// positioned in the design coordinates of the piece around it
+type innerPiece struct {
+	piece               PieceToPlace
+	x, y, width, height float64
+	rotation            int
+}
+
+// designHoleAreas returns the rectangles other pieces may be cut from inside
+// the visible circular, rectangular and square holes of a design, less the
+// holes' tolerance. A circular hole offers its inscribed square.
+func designHoleAreas(design *models.Design) []Rectangle {
+	if design == nil {
+		return nil
+	}
+
+	var areas []Rectangle
+	for _, hole := range design.Elements.Holes {
+		if !hole.Visible {
+			continue
+		}
+
+		width, height := hole.Width, hole.Height
+		switch hole.Type {
+		case models.HoleCircular:
+			width = hole.Radius * math.Sqrt2
+			height = width
+		case models.HoleSquare:
+			if height == 0 {
+				height = width
+			}
+		case models.HoleRectangular:
+		default:
+			continue
+		}
+
+		width -= 2 * hole.Tolerance
+		height -= 2 * hole.Tolerance
+		if width <= 0 || height <= 0 {
+			continue
+		}
+
+		areas = append(areas, Rectangle{
+			X:      hole.Center.X - width/2,
+			Y:      hole.Center.Y - height/2,
+			Width:  width,
+			Height: height,
+		})
+	}
+
+	return areas
+}
+
+// nestInHoles cuts smaller pieces from the hole drop-outs of larger ones.
+// Holes are filled largest first, each with the largest pieces that fit
+// bottom-left inside it a gap away from its edge. The pieces nested are
+// removed from the list and carried by the piece around them, which becomes
+// due as early as the earliest of them and takes their highest priority; a
+// piece that carries others is not nested itself.
+func (s *OptimizerService) nestInHoles(pieces []PieceToPlace, options *models.OptimizeOptions) []PieceToPlace {
+	type hole struct {
+		host int
+		area Rectangle
+	}
+
+	spacing := pieceSpacing(options)
+
+	var holes []hole
+	for i, piece := range pieces {
+		for _, area := range piece.Holes {
+			area = Rectangle{
+				X:      area.X + spacing,
+				Y:      area.Y + spacing,
+				Width:  area.Width - 2*spacing,
+				Height: area.Height - 2*spacing,
+			}
+			if area.Width > 0 && area.Height > 0 {
+				holes = append(holes, hole{host: i, area: area})
+			}
+		}
+	}
+	if len(holes) == 0 {
+		return pieces
+	}
+
+	sort.SliceStable(holes, func(i, j int) bool {
+		return holes[i].area.Width*holes[i].area.Height > holes[j].area.Width*holes[j].area.Height
+	})
+
+	order := make([]int, len(pieces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return pieces[order[i]].Width*pieces[order[i]].Height > pieces[order[j]].Width*pieces[order[j]].Height
+	})
+
+	nested := make([]bool, len(pieces))
+	count := 0
+	for _, hole := range holes {
+		if nested[hole.host] {
+			continue
+		}
+
+		spaces := []Rectangle{hole.area}
+		for _, i := range order {
+			if i == hole.host || nested[i] || len(pieces[i].Inner) > 0 {
+				continue
+			}
+
+			// The piece turns with the piece around it, so a grain lock holds
+			// relative to that piece
+			grain, ok := innerGrain(pieces[i].Grain, pieces[hole.host].Grain, options.AllowRotation)
+			if !ok {
+				continue
+			}
+			candidate := pieces[i]
+			candidate.Grain = grain
+
+			for _, orientation := range s.getOrientations(candidate, options.AllowRotation) {
+				position := s.findBottomLeftPosition(spaces, orientation.Width, orientation.Height, spacing)
+				if position == nil {
+					continue
+				}
+
+				pieces[hole.host].Inner = append(pieces[hole.host].Inner, innerPiece{
+					piece:    pieces[i],
+					x:        position.X,
+					y:        position.Y,
+					width:    orientation.Width,
+					height:   orientation.Height,
+					rotation: orientation.Rotation,
+				})
+				nested[i] = true
+				count++
+
+				// The piece is cut when the piece around it is
+				if moreUrgent(&pieces[i], &pieces[hole.host]) {
+					pieces[hole.host].DueDate = pieces[i].DueDate
+				}
+				pieces[hole.host].Priority = max(pieces[hole.host].Priority, pieces[i].Priority)
+
+				spaces = s.updateAvailableSpaces(spaces, Rectangle{
+					X:      position.X - spacing,
+					Y:      position.Y - spacing,
+					Width:  orientation.Width + 2*spacing,
+					Height: orientation.Height + 2*spacing,
+				})
+				break
+			}
+		}
+	}
+
+	if count == 0 {
+		return pieces
+	}
+	s.logger.Debug("Nested pieces in hole drop-outs", "pieces", count)
+
+	remaining := make([]PieceToPlace, 0, len(pieces)-count)
+	for i, piece := range pieces {
+		if !nested[i] {
+			remaining = append(remaining, piece)
+		}
+	}
+	return remaining
+}
+
+// placeInnerPieces adds the pieces carried in hole drop-outs to the pieces a
+// packer placed on a sheet, after them, and names every piece so that inner
+// pieces can point at the piece around them. Copies of a design have the same
+// holes, so placed pieces are matched to the carriers by design.
+func placeInnerPieces(sheetNumber int, pieces []PieceToPlace, placed []models.PlacedPiece, unplaced []PieceToPlace) []models.PlacedPiece {
+	left := make(map[int]bool, len(unplaced))
+	for _, piece := range unplaced {
+		left[piece.serial] = true
+	}
+
+	var carriers []PieceToPlace
+	for _, piece := range pieces {
+		if len(piece.Inner) > 0 && !left[piece.serial] {
+			carriers = append(carriers, piece)
+		}
+	}
+	if len(carriers) == 0 {
+		return placed
+	}
+
+	for i := range placed {
+		if placed[i].ID == "" {
+			placed[i].ID = placementID(sheetNumber, i+1)
+		}
+	}
+
+	hosts := len(placed)
+	for i := 0; i < hosts; i++ {
+		for j, carrier := range carriers {
+			if carrier.DesignID != placed[i].DesignID {
+				continue
+			}
+
+			for _, inner := range carrier.Inner {
+				piece := innerPlacement(placed[i], carrier, inner)
+				piece.ID = placementID(sheetNumber, len(placed)+1)
+				placed = append(placed, piece)
+			}
+			carriers = append(carriers[:j], carriers[j+1:]...)
+			break
+		}
+	}
+
+	return placed
+}
+
+// innerPlacement positions an inner piece on the sheet by turning it with the
+// piece around it
+func innerPlacement(host models.PlacedPiece, carrier PieceToPlace, inner innerPiece) models.PlacedPiece {
+	// Packers other than true-shape nesting place the bounding box
+	frame := rectangleOutline(Rectangle{Width: carrier.Width, Height: carrier.Height})
+	if carrier.Outline != nil && len(host.Outline) > 0 {
+		frame = carrier.Outline
+	}
+
+	corners := rotateInFrame(rectangleOutline(Rectangle{X: inner.x, Y: inner.y, Width: inner.width, Height: inner.height}), frame, host.Rotation)
+	minX, minY := outlineOrigin(corners)
+	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+	for _, point := range corners {
+		maxX = math.Max(maxX, point.X)
+		maxY = math.Max(maxY, point.Y)
+	}
+
+	piece := models.PlacedPiece{
+		DesignID:     inner.piece.DesignID,
+		DesignName:   inner.piece.Name,
+		X:            host.X + minX,
+		Y:            host.Y + minY,
+		Width:        maxX - minX,
+		Height:       maxY - minY,
+		Rotation:     (host.Rotation + inner.rotation) % 360,
+		Nested:       true,
+		ParentID:     host.ID,
+		EdgeDeletion: inner.piece.EdgeDeletion,
+		Order:        inner.piece.Order,
+	}
+	setNetSize(&piece, inner.piece.Allowance)
+	if host.Rotation%90 != 0 {
+		piece.Outline = translateOutline(corners, host.X, host.Y)
+	}
+
+	return piece
+}
+
+// cutOrder returns the pieces in cutting order: pieces nested in a hole
+// drop-out are cut right before the piece around them
+func cutOrder(pieces []models.PlacedPiece) []models.PlacedPiece {
+	inner := make(map[string][]models.PlacedPiece)
+	hosts := make(map[string]bool)
+	for _, piece := range pieces {
+		hosts[piece.ID] = true
+	}
+	for _, piece := range pieces {
+		if piece.Nested && hosts[piece.ParentID] {
+			inner[piece.ParentID] = append(inner[piece.ParentID], piece)
+		}
+	}
+	if len(inner) == 0 {
+		return pieces
+	}
+
+	ordered := make([]models.PlacedPiece, 0, len(pieces))
+	for _, piece := range pieces {
+		if piece.Nested && hosts[piece.ParentID] {
+			continue
+		}
+		ordered = append(ordered, inner[piece.ID]...)
+		ordered = append(ordered, piece)
+	}
+	return ordered
+}
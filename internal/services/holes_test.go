@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestPiecesNestInHoleDropOuts(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	// A frame whose 800mm square opening drops out enough glass for four tiles
+	frame := &models.Design{
+		Name:      "Frame",
+		Width:     1000,
+		Height:    1200,
+		Thickness: 6,
+		UserID:    userID,
+		Elements: models.Elements{Holes: []models.Hole{{
+			Type:    models.HoleRectangular,
+			Center:  models.Point{X: 500, Y: 600},
+			Width:   800,
+			Height:  800,
+			Visible: true,
+		}}},
+	}
+	if err := store.CreateDesign(frame); err != nil {
+		t.Fatalf("CreateDesign() error = %v", err)
+	}
+
+	for _, algorithm := range []string{"blf", "guillotine", "nfp"} {
+		t.Run(algorithm, func(t *testing.T) {
+			optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+				Name:      "Frames " + algorithm,
+				SheetID:   1,
+				Algorithm: algorithm,
+				Designs: []models.DesignItem{
+					{DesignID: frame.ID, Quantity: 1},
+					{DesignID: 0, Name: "Tile", Width: 300, Height: 350, Quantity: 5},
+				},
+				Options: models.OptimizeOptions{AllowRotation: true, EnableNesting: true},
+			}, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+
+			sheet := optimization.Layout.Sheets[0]
+			if len(sheet.Pieces) != 6 {
+				t.Fatalf("Placed pieces incorrect: got %d, want %d", len(sheet.Pieces), 6)
+			}
+
+			var host models.PlacedPiece
+			for _, piece := range sheet.Pieces {
+				if piece.DesignName == "Frame" {
+					host = piece
+				}
+			}
+
+			// The opening less the 2mm gap all round
+			var opening Rectangle
+			if host.Width == 1000 {
+				opening = Rectangle{X: host.X + 102, Y: host.Y + 202, Width: 796, Height: 796}
+			} else {
+				opening = Rectangle{X: host.X + 202, Y: host.Y + 102, Width: 796, Height: 796}
+			}
+
+			nested := make(map[string]bool)
+			for _, piece := range sheet.Pieces {
+				if !piece.Nested {
+					continue
+				}
+				nested[piece.ID] = true
+				if piece.ParentID != host.ID {
+					t.Errorf("Nested piece %s parent incorrect: got %q, want %q", piece.ID, piece.ParentID, host.ID)
+				}
+				if !rectangleContains(opening, Rectangle{X: piece.X, Y: piece.Y, Width: piece.Width, Height: piece.Height}) {
+					t.Errorf("Nested piece %s is outside the opening: (%.0f,%.0f) %.0fx%.0f", piece.ID, piece.X, piece.Y, piece.Width, piece.Height)
+				}
+			}
+			if len(nested) != 4 {
+				t.Fatalf("Nested pieces incorrect: got %d, want %d", len(nested), 4)
+			}
+
+			// Inner pieces are cut before the frame around them
+			hostCut := -1
+			for _, path := range sheet.CutPaths {
+				for _, id := range path.Pieces {
+					if id == host.ID && hostCut < 0 {
+						hostCut = path.Order
+					}
+					if nested[id] && hostCut >= 0 {
+						t.Errorf("Nested piece %s is cut at %d, after the frame at %d", id, path.Order, hostCut)
+					}
+				}
+			}
+		})
+	}
+
+	// Without nesting the tiles are placed beside the frame
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name:      "Frames apart",
+		SheetID:   1,
+		Algorithm: "blf",
+		Designs: []models.DesignItem{
+			{DesignID: frame.ID, Quantity: 1},
+			{DesignID: 0, Name: "Tile", Width: 300, Height: 350, Quantity: 5},
+		},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	for _, piece := range optimization.Layout.AllPieces() {
+		if piece.Nested {
+			t.Errorf("Piece %s nested without enable_nesting", piece.ID)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"glass-optimizer/internal/models"
+)
+
+// Improvement pass
+//
+// Once an algorithm has filled its sheets, simulated annealing looks for a
+// better assignment of pieces to sheets. Each move changes the pieces of one
+// or two sheets, which are then packed again with the algorithm's own
+// filler; a move that leaves a piece over is dropped. Moves that save glass
+// are always kept. Moves that keep the cost spread the used area over the
+// sheets; the pass prefers layouts that fill some sheets completely and
+// leave the waste together on few sheets, where it makes large offcuts, but
+// accepts worse spreads with a probability that falls as it cools.
+
+const (
+	// defaultImproveIterations is the number of moves tried when the request
+	// does not set improve_iterations
+	defaultImproveIterations = 200
+
+	// improveTemperature is the starting temperature of the annealing, in
+	// units of the sum of squared sheet utilizations
+	improveTemperature = 0.01
+)
+
+// improvedSheet is a sheet of a layout under improvement
+type improvedSheet struct {
+	filledSheet
+	number int     // Sheet number its placement IDs were made for
+	used   float64 // Area covered by its pieces
+}
+
+// utilization returns the share of the sheet covered by its pieces (0-1)
+func (sheet *improvedSheet) utilization() float64 {
+	return sheet.used / sheet.sheet.Area()
+}
+
+// improvement is an assignment of pieces to sheets and its score
+type improvement struct {
+	sheets []improvedSheet
+	cost   float64
+	spread float64 // Negated sum of squared sheet utilizations; lower is better
+}
+
+func newImprovement(sheets []improvedSheet) *improvement {
+	state := &improvement{sheets: sheets}
+	for _, sheet := range sheets {
+		if sheet.remnantID == 0 {
+			state.cost += sheet.sheet.TotalCost()
+		}
+		state.spread -= sheet.utilization() * sheet.utilization()
+	}
+	return state
+}
+
+// saves reports whether the state uses less glass than other: it costs less,
+// or as much on fewer sheets
+func (state *improvement) saves(other *improvement) bool {
+	if math.Abs(state.cost-other.cost) > 1e-9 {
+		return state.cost < other.cost
+	}
+	return len(state.sheets) < len(other.sheets)
+}
+
+// better reports whether the state beats other outright
+func (state *improvement) better(other *improvement) bool {
+	if state.saves(other) || other.saves(state) {
+		return state.saves(other)
+	}
+	return state.spread < other.spread-1e-12
+}
+
+// copySheets returns a copy of the sheets of the state for a move to change
+func (state *improvement) copySheets() []improvedSheet {
+	return append([]improvedSheet(nil), state.sheets...)
+}
+
+// improveSheets improves the filled sheets by simulated annealing within
+// options.improve_iterations moves and options.improve_time_limit. Pieces
+// only move to later sheets past pieces at least as urgent, so the sheets
+// keep finishing the urgent orders first. The moves are random but follow
+// options.seed.
+func (s *OptimizerService) improveSheets(ctx context.Context, stock *sheetStock, filled []filledSheet, options *models.OptimizeOptions, fill sheetFiller) []filledSheet {
+	iterations := options.ImproveIterations
+	if iterations <= 0 {
+		iterations = defaultImproveIterations
+	}
+	if options.ImproveTimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(options.ImproveTimeLimit)*time.Second)
+		defer cancel()
+	}
+	ctx = WithProgress(ctx, nil)
+
+	sheets := make([]improvedSheet, len(filled))
+	for i, sheet := range filled {
+		sheets[i] = improvedSheet{filledSheet: sheet, number: i + 1, used: s.calculateUsedArea(sheet.pieces)}
+	}
+	improver := &sheetImprover{
+		service: s,
+		ctx:     ctx,
+		stock:   stock,
+		options: options,
+		fill:    fill,
+		rng:     rand.New(rand.NewSource(options.Seed)),
+	}
+
+	current := newImprovement(sheets)
+	best := current
+	moves := 0
+	for i := 0; i < iterations && ctx.Err() == nil; i++ {
+		candidate := improver.move(current)
+		if candidate == nil {
+			continue
+		}
+
+		temperature := improveTemperature * (1 - float64(i)/float64(iterations))
+		switch {
+		case current.saves(candidate):
+			continue
+		case !candidate.saves(current) && candidate.spread > current.spread &&
+			improver.rng.Float64() >= math.Exp((current.spread-candidate.spread)/temperature):
+			continue
+		}
+
+		current = candidate
+		moves++
+		if current.better(best) {
+			best = current
+		}
+	}
+
+	s.logger.Debug("Improvement pass finished", "moves", moves,
+		"sheets_before", len(filled), "sheets_after", len(best.sheets))
+
+	improved := make([]filledSheet, len(best.sheets))
+	for i, sheet := range best.sheets {
+		improved[i] = sheet.filledSheet
+		if sheet.number != i+1 {
+			improved[i] = renumberSheet(sheet.filledSheet, sheet.number, i+1)
+		}
+	}
+	return improved
+}
+
+// sheetImprover makes the moves of the improvement pass
+type sheetImprover struct {
+	service *OptimizerService
+	ctx     context.Context
+	stock   *sheetStock
+	options *models.OptimizeOptions
+	fill    sheetFiller
+	rng     *rand.Rand
+}
+
+// move returns a neighbour of the state, or nil when the move chosen cannot
+// be made. It empties the emptiest sheet into the others, swaps two pieces
+// between sheets, turns a piece, or moves the emptiest sheet to a cheaper
+// size.
+func (im *sheetImprover) move(state *improvement) *improvement {
+	switch im.rng.Intn(4) {
+	case 0:
+		return im.emptySheet(state)
+	case 1:
+		return im.swapPieces(state)
+	case 2:
+		return im.turnPiece(state)
+	default:
+		return im.downsizeSheet(state)
+	}
+}
+
+// emptySheet moves the pieces of the emptiest sheet, largest first, to the
+// first other sheet in a random order that still takes them. The sheet is
+// dropped once it is empty.
+func (im *sheetImprover) emptySheet(state *improvement) *improvement {
+	from := emptiestSheet(state.sheets, false)
+	if from < 0 || len(state.sheets) < 2 {
+		return nil
+	}
+
+	sheets := state.copySheets()
+	left := append([]PieceToPlace(nil), sheets[from].placed...)
+	im.service.sortPieces(left, "area", "desc")
+
+	moved := false
+	for i := 0; i < len(left); {
+		piece := left[i]
+		placed := false
+		for _, to := range im.rng.Perm(len(sheets)) {
+			if to == from || !mayMove(sheets, &piece, from, to) {
+				continue
+			}
+			if sheet, ok := im.repack(sheets[to], to, append(sheets[to].placed[:len(sheets[to].placed):len(sheets[to].placed)], piece)); ok {
+				sheets[to] = sheet
+				placed = true
+				break
+			}
+		}
+
+		if placed {
+			left = append(left[:i], left[i+1:]...)
+			moved = true
+		} else {
+			i++
+		}
+	}
+	if !moved {
+		return nil
+	}
+
+	if len(left) == 0 {
+		return newImprovement(append(sheets[:from], sheets[from+1:]...))
+	}
+
+	sheet, ok := im.repack(sheets[from], from, left)
+	if !ok {
+		return nil
+	}
+	sheets[from] = sheet
+	return newImprovement(sheets)
+}
+
+// swapPieces exchanges a random piece of one sheet with a different random
+// piece of another
+func (im *sheetImprover) swapPieces(state *improvement) *improvement {
+	if len(state.sheets) < 2 {
+		return nil
+	}
+
+	a := im.rng.Intn(len(state.sheets))
+	b := im.rng.Intn(len(state.sheets) - 1)
+	if b >= a {
+		b++
+	}
+	if a > b {
+		a, b = b, a
+	}
+
+	first, second := state.sheets[a].placed, state.sheets[b].placed
+	if len(first) == 0 || len(second) == 0 {
+		return nil
+	}
+	i, j := im.rng.Intn(len(first)), im.rng.Intn(len(second))
+	if samePiece(&first[i], &second[j]) || !mayMove(state.sheets, &first[i], a, b) {
+		return nil
+	}
+
+	firstPieces := append(append([]PieceToPlace(nil), first[:i]...), first[i+1:]...)
+	secondPieces := append(append([]PieceToPlace(nil), second[:j]...), second[j+1:]...)
+
+	sheetA, ok := im.repack(state.sheets[a], a, append(firstPieces, second[j]))
+	if !ok {
+		return nil
+	}
+	sheetB, ok := im.repack(state.sheets[b], b, append(secondPieces, first[i]))
+	if !ok {
+		return nil
+	}
+
+	sheets := state.copySheets()
+	sheets[a], sheets[b] = sheetA, sheetB
+	return newImprovement(sheets)
+}
+
+// turnPiece makes a random piece that is free to turn prefer its other
+// orientation and packs its sheet again
+func (im *sheetImprover) turnPiece(state *improvement) *improvement {
+	if !im.options.AllowRotation {
+		return nil
+	}
+
+	i := im.rng.Intn(len(state.sheets))
+	pieces := append([]PieceToPlace(nil), state.sheets[i].placed...)
+	if len(pieces) == 0 {
+		return nil
+	}
+	j := im.rng.Intn(len(pieces))
+	if pieces[j].Grain != grainFree {
+		return nil
+	}
+	pieces[j].PreferRotated = !pieces[j].PreferRotated
+
+	sheet, ok := im.repack(state.sheets[i], i, pieces)
+	if !ok {
+		return nil
+	}
+	sheets := state.copySheets()
+	sheets[i] = sheet
+	return newImprovement(sheets)
+}
+
+// downsizeSheet packs the pieces of the emptiest full sheet on the cheapest
+// size in stock that takes them all and costs less
+func (im *sheetImprover) downsizeSheet(state *improvement) *improvement {
+	i := emptiestSheet(state.sheets, true)
+	if i < 0 {
+		return nil
+	}
+	current := state.sheets[i]
+
+	var best *improvedSheet
+	for _, size := range im.stock.sheets {
+		if size.sheet.TotalCost() >= current.sheet.TotalCost() || hasInstanceDefects(size.sheet) {
+			continue
+		}
+		if best != nil && size.sheet.TotalCost() >= best.sheet.TotalCost() {
+			continue
+		}
+
+		opened := 0
+		for _, sheet := range state.sheets {
+			if sheet.remnantID == 0 && sheet.sheet.ID == size.sheet.ID {
+				opened++
+			}
+		}
+		if size.limit > 0 && opened >= size.limit {
+			continue
+		}
+
+		candidate := current
+		candidate.sheet, candidate.instance = size.sheet, 0
+		if sheet, ok := im.repack(candidate, i, current.placed); ok {
+			best = &sheet
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	sheets := state.copySheets()
+	sheets[i] = *best
+	return newImprovement(sheets)
+}
+
+// repack fills a sheet again with the given pieces as sheet i of the layout.
+// It fails when a piece is left over.
+func (im *sheetImprover) repack(sheet improvedSheet, i int, pieces []PieceToPlace) (improvedSheet, bool) {
+	sortBy := im.options.SortBy
+	if sortBy == sortAll {
+		sortBy = ""
+	}
+	ordered := append([]PieceToPlace(nil), pieces...)
+	im.service.sortPieces(ordered, sortBy, im.options.SortOrder)
+
+	filled, unplaced := im.fill(im.ctx, sheet.sheet, i+1, ordered, im.options)
+	if len(unplaced) > 0 || im.ctx.Err() != nil {
+		return improvedSheet{}, false
+	}
+
+	filled.remnantID, filled.instance = sheet.remnantID, sheet.instance
+	return improvedSheet{filledSheet: filled, number: i + 1, used: im.service.calculateUsedArea(filled.pieces)}, true
+}
+
+// mayMove reports whether a piece may move between sheets. A piece moves to
+// an earlier sheet freely, but to a later one only past pieces at least as
+// urgent as itself.
+func mayMove(sheets []improvedSheet, piece *PieceToPlace, from, to int) bool {
+	for k := from + 1; k <= to; k++ {
+		for i := range sheets[k].placed {
+			if moreUrgent(piece, &sheets[k].placed[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// emptiestSheet returns the sheet with the lowest utilization, the last one
+// on a tie, or -1 when there is none. Remnants are skipped when fullOnly is
+// set.
+func emptiestSheet(sheets []improvedSheet, fullOnly bool) int {
+	emptiest, lowest := -1, math.MaxFloat64
+	for i := range sheets {
+		if fullOnly && sheets[i].remnantID != 0 {
+			continue
+		}
+		if utilization := sheets[i].utilization(); utilization <= lowest {
+			emptiest, lowest = i, utilization
+		}
+	}
+	return emptiest
+}
+
+// samePiece reports whether two pieces are copies of the same piece, which
+// swapping would not change
+func samePiece(a, b *PieceToPlace) bool {
+	return a.DesignID == b.DesignID && a.Name == b.Name && a.Width == b.Width && a.Height == b.Height &&
+		len(a.Inner) == 0 && len(b.Inner) == 0
+}
+
+// hasInstanceDefects reports whether a sheet size has defects on some of its
+// sheets only
+func hasInstanceDefects(sheet *models.GlassSheet) bool {
+	for _, defect := range sheet.Defects {
+		if defect.Instance > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// placedPieces returns the pieces a filler placed: those it did not return
+// as unplaced, in the order they were given
+func placedPieces(pieces, unplaced []PieceToPlace) []PieceToPlace {
+	left := make(map[int]bool, len(unplaced))
+	for _, piece := range unplaced {
+		left[piece.serial] = true
+	}
+
+	placed := make([]PieceToPlace, 0, len(pieces)-len(unplaced))
+	for _, piece := range pieces {
+		if !left[piece.serial] {
+			placed = append(placed, piece)
+		}
+	}
+	return placed
+}
+
+// renumberSheet returns the filled sheet with its placement IDs moved from
+// one sheet number to another
+func renumberSheet(sheet filledSheet, from, to int) filledSheet {
+	oldPrefix, newPrefix := fmt.Sprintf("s%d-", from), fmt.Sprintf("s%d-", to)
+	rename := func(id string) string {
+		if strings.HasPrefix(id, oldPrefix) {
+			return newPrefix + id[len(oldPrefix):]
+		}
+		return id
+	}
+
+	pieces := make([]models.PlacedPiece, len(sheet.pieces))
+	for i, piece := range sheet.pieces {
+		piece.ID = rename(piece.ID)
+		piece.ParentID = rename(piece.ParentID)
+		pieces[i] = piece
+	}
+	sheet.pieces = pieces
+
+	if sheet.cutTree != nil {
+		tree := renameCutTree(*sheet.cutTree, rename)
+		sheet.cutTree = &tree
+	}
+	return sheet
+}
+
+// renameCutTree returns a copy of the cut tree with its piece IDs renamed
+func renameCutTree(node models.CutNode, rename func(string) string) models.CutNode {
+	node.PieceID = rename(node.PieceID)
+	if node.Children != nil {
+		children := make([]models.CutNode, len(node.Children))
+		for i, child := range node.Children {
+			children[i] = renameCutTree(child, rename)
+		}
+		node.Children = children
+	}
+	return node
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestImprovePassSavesSheets(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	// Taken largest first, the panes leave no room for the big light on the
+	// first sheet, though all of them fit on one
+	designs := []models.DesignItem{
+		{DesignID: 0, Name: "Pane", Width: 1200, Height: 700, Quantity: 4},
+		{DesignID: 0, Name: "Light", Width: 1300, Height: 1200, Quantity: 1},
+		{DesignID: 0, Name: "Square", Width: 500, Height: 500, Quantity: 1},
+	}
+	run := func(algorithm string, options models.OptimizeOptions) *models.Layout {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name:      "Improve " + algorithm,
+			SheetID:   1, // Standard 2m x 3m
+			Algorithm: algorithm,
+			Designs:   designs,
+			Options:   options,
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+		assertLegalLayout(t, &optimization.Layout)
+		return &optimization.Layout
+	}
+
+	for _, algorithm := range []string{"blf", "guillotine"} {
+		t.Run(algorithm, func(t *testing.T) {
+			plain := run(algorithm, models.OptimizeOptions{AllowRotation: true})
+			improved := run(algorithm, models.OptimizeOptions{AllowRotation: true, Improve: true, Seed: 1})
+			if len(plain.Sheets) != 2 {
+				t.Fatalf("Sheets without improvement incorrect: got %d, want %d", len(plain.Sheets), 2)
+			}
+			if len(improved.Sheets) != 1 {
+				t.Errorf("Sheets incorrect: got %d, want %d", len(improved.Sheets), 1)
+			}
+			if got := len(improved.AllPieces()); got != 6 {
+				t.Errorf("Placed pieces incorrect: got %d, want %d", got, 6)
+			}
+			if betterLayout(plain, improved) {
+				t.Error("Improved layout is worse than the layout it started from")
+			}
+		})
+	}
+
+	// Improving never undoes the order urgent pieces are cut in
+	urgent := []models.DesignItem{
+		{DesignID: 0, Name: "Stock", Width: 900, Height: 900, Quantity: 10, Order: "B"},
+		{DesignID: 0, Name: "Rush", Width: 900, Height: 900, Quantity: 4, DueDate: "2026-10-27", Order: "C"},
+		{DesignID: 0, Name: "Due", Width: 900, Height: 900, Quantity: 4, DueDate: "2026-10-20", Order: "A"},
+	}
+	want := [][]string{{"A"}, {"C"}, {"B"}}
+	for _, algorithm := range []string{"blf", "maxrects-bssf", "skyline", "genetic", "auto"} {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name: "Improve orders " + algorithm, SheetID: 1, Algorithm: algorithm, Designs: urgent,
+			Options: models.OptimizeOptions{AllowRotation: true, Improve: true, ImproveIterations: 100,
+				MaxIterations: 5, PopulationSize: 6, Seed: 1},
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+		assertLegalLayout(t, &optimization.Layout)
+		var got [][]string
+		for _, sheet := range optimization.Layout.Sheets {
+			got = append(got, sheet.CompletedOrders)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s completed orders incorrect: got %v, want %v", algorithm, got, want)
+		}
+	}
+
+	req := &models.OptimizationRequest{Name: "Bad budget", SheetID: 1, Algorithm: "blf", Designs: designs,
+		Options: models.OptimizeOptions{Improve: true, ImproveIterations: -1}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Negative improve_iterations: got %v, want validation error", err)
+	}
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/storage"
+)
+
+// progressSaveInterval limits how often the progress of a running job is
+// written to the database; watchers are notified of every update
+const progressSaveInterval = time.Second
+
+// watcherBuffer is the number of updates a slow watcher may fall behind
+// before intermediate updates are dropped
+const watcherBuffer = 16
+
+// JobService runs optimization requests in the background on a pool of
+// workers. Jobs are recorded in optimization_jobs so that queued jobs, and
+// jobs interrupted by a shutdown, are run again when the service starts.
+type JobService struct {
+	storage   storage.Storage
+	optimizer *OptimizerService
+	logger    *slog.Logger
+	workers   int
+	stopped   sync.WaitGroup // workers still running
+
+	mu        sync.Mutex
+	queue     []*models.OptimizationJob // queued jobs in submission order
+	wake      chan struct{}
+	running   map[int]context.CancelFunc
+	cancelled map[int]bool // running jobs cancelled by their owner
+	watchers  map[int][]chan models.OptimizationJob
+}
+
+// NewJobService creates a new job service running up to workers jobs at once
+func NewJobService(storage storage.Storage, optimizer *OptimizerService, logger *slog.Logger, workers int) *JobService {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &JobService{
+		storage:   storage,
+		optimizer: optimizer,
+		logger:    logger,
+		workers:   workers,
+		wake:      make(chan struct{}, 1),
+		running:   make(map[int]context.CancelFunc),
+		cancelled: make(map[int]bool),
+		watchers:  make(map[int][]chan models.OptimizationJob),
+	}
+}
+
+// Start queues the jobs left unfinished by a previous run and starts the
+// workers. Workers stop when ctx is done; jobs they were running are queued
+// again for the next start, and what those jobs saved is deleted. Wait
+// returns once the workers have stopped.
+func (s *JobService) Start(ctx context.Context) error {
+	jobs, err := s.storage.GetUnfinishedOptimizationJobs()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	queued := make(map[int]bool, len(s.queue))
+	for _, job := range s.queue {
+		queued[job.ID] = true
+	}
+	s.mu.Unlock()
+
+	for i := range jobs {
+		job := &jobs[i]
+		if queued[job.ID] {
+			// Submitted to this service before it started
+			continue
+		}
+		if job.Status == models.JobStatusRunning {
+			s.requeue(job)
+		}
+
+		s.mu.Lock()
+		s.queue = append(s.queue, job)
+		s.mu.Unlock()
+	}
+
+	if len(jobs) > 0 {
+		s.logger.Info("Resuming optimization jobs", "count", len(jobs))
+	}
+
+	s.stopped.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer s.stopped.Done()
+			s.worker(ctx)
+		}()
+	}
+	s.signal()
+
+	return nil
+}
+
+// Wait blocks until the workers started by Start have stopped, so that the
+// jobs they were running are queued again before the program exits
+func (s *JobService) Wait() {
+	s.stopped.Wait()
+}
+
+// Submit validates the request and queues it as a new job
+func (s *JobService) Submit(req *models.OptimizationRequest, userID int64) (*models.OptimizationJob, error) {
+	if userID == 0 {
+		return nil, models.NewValidationError("user ID is required")
+	}
+
+	if err := s.optimizer.validateOptimizationRequest(req); err != nil {
+		return nil, err
+	}
+
+	job := &models.OptimizationJob{
+		UserID:  userID,
+		Status:  models.JobStatusQueued,
+		Request: *req,
+	}
+	if err := s.storage.CreateOptimizationJob(job); err != nil {
+		return nil, err
+	}
+
+	queued := *job
+	s.mu.Lock()
+	s.queue = append(s.queue, &queued)
+	s.mu.Unlock()
+	s.signal()
+
+	s.logger.Info("Optimization job queued", "id", job.ID, "user_id", userID)
+	return job, nil
+}
+
+// GetJob retrieves a job owned by the user
+func (s *JobService) GetJob(id int, userID int64) (*models.OptimizationJob, error) {
+	if userID == 0 {
+		return nil, models.NewValidationError("user ID is required")
+	}
+
+	return s.storage.GetOptimizationJob(id, userID)
+}
+
+// GetJobs retrieves the user's jobs with pagination, newest first
+func (s *JobService) GetJobs(userID int64, limit, offset int) ([]models.OptimizationJob, int, error) {
+	if userID == 0 {
+		return nil, 0, models.NewValidationError("user ID is required")
+	}
+
+	return s.storage.GetOptimizationJobs(userID, limit, offset)
+}
+
+// CancelJob cancels a queued or running job owned by the user. A queued job
+// is cancelled immediately; a running job stops at the next check of its
+// context and keeps the best layout found so far.
+func (s *JobService) CancelJob(id int, userID int64) (*models.OptimizationJob, error) {
+	job, err := s.GetJob(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if cancel, ok := s.running[id]; ok {
+		s.cancelled[id] = true
+		s.mu.Unlock()
+		cancel()
+
+		s.logger.Info("Cancelling running optimization job", "id", id)
+		return job, nil
+	}
+
+	queued := false
+	for i, candidate := range s.queue {
+		if candidate.ID == id {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			queued = true
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if !queued {
+		return nil, models.NewConflictError("optimization job has already finished")
+	}
+
+	now := time.Now()
+	job.Status = models.JobStatusCancelled
+	job.FinishedAt = &now
+	if err := s.finish(job); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Cancelled queued optimization job", "id", id)
+	return job, nil
+}
+
+// WatchJob returns the current state of a job owned by the user and a
+// channel that receives its updates. The channel is closed once the job has
+// finished; the final state can then be read with GetJob. stop must be called
+// when the caller is no longer interested.
+func (s *JobService) WatchJob(id int, userID int64) (*models.OptimizationJob, <-chan models.OptimizationJob, func(), error) {
+	if _, err := s.GetJob(id, userID); err != nil {
+		return nil, nil, nil, err
+	}
+
+	updates := make(chan models.OptimizationJob, watcherBuffer)
+	s.mu.Lock()
+	s.watchers[id] = append(s.watchers[id], updates)
+	s.mu.Unlock()
+
+	stop := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		watchers := s.watchers[id]
+		for i, ch := range watchers {
+			if ch == updates {
+				s.watchers[id] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		if len(s.watchers[id]) == 0 {
+			delete(s.watchers, id)
+		}
+	}
+
+	// Read the state after registering so that a job finishing in between
+	// is either reported here or closes the channel
+	job, err := s.GetJob(id, userID)
+	if err != nil {
+		stop()
+		return nil, nil, nil, err
+	}
+	if job.IsFinished() {
+		stop()
+		closed := make(chan models.OptimizationJob)
+		close(closed)
+		return job, closed, func() {}, nil
+	}
+
+	return job, updates, stop, nil
+}
+
+// Private methods
+
+func (s *JobService) worker(ctx context.Context) {
+	for {
+		job, jobCtx, cancel := s.next(ctx)
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			}
+			continue
+		}
+
+		s.run(ctx, jobCtx, job)
+		cancel()
+	}
+}
+
+// next takes the oldest queued job and registers it as running, so that it
+// can be cancelled from the moment it leaves the queue
+func (s *JobService) next(ctx context.Context) (*models.OptimizationJob, context.Context, context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 || ctx.Err() != nil {
+		return nil, nil, nil
+	}
+
+	job := s.queue[0]
+	s.queue = s.queue[1:]
+	if len(s.queue) > 0 {
+		s.signal()
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	s.running[job.ID] = cancel
+
+	return job, jobCtx, cancel
+}
+
+// run executes a job and records its outcome
+func (s *JobService) run(ctx, jobCtx context.Context, job *models.OptimizationJob) {
+	s.logger.Info("Running optimization job", "id", job.ID, "algorithm", job.Request.Algorithm)
+
+	startedAt := time.Now()
+	job.Status = models.JobStatusRunning
+	job.StartedAt = &startedAt
+	job.Progress = models.JobProgress{}
+	if err := s.storage.UpdateOptimizationJob(job); err != nil {
+		s.logger.Error("Failed to mark optimization job running", "error", err, "id", job.ID)
+	}
+	s.notify(job)
+
+	lastSave := startedAt
+	jobCtx = WithProgress(jobCtx, func(progress models.JobProgress) {
+		job.Progress = progress
+		if time.Since(lastSave) >= progressSaveInterval {
+			lastSave = time.Now()
+			if err := s.storage.UpdateOptimizationJob(job); err != nil {
+				s.logger.Warn("Failed to save optimization job progress", "error", err, "id", job.ID)
+			}
+		}
+		s.notify(job)
+	})
+
+	optimizations, err := s.runRequest(jobCtx, job)
+
+	s.mu.Lock()
+	cancelled := s.cancelled[job.ID]
+	delete(s.running, job.ID)
+	delete(s.cancelled, job.ID)
+	s.mu.Unlock()
+
+	if ctx.Err() != nil && !cancelled {
+		// Shutting down: run the job again on the next start. The partial
+		// layout it saved, and the remnants that layout would create, go.
+		s.logger.Warn("Optimization job interrupted by shutdown", "id", job.ID)
+		s.optimizer.discardOptimizations(optimizations, job.UserID)
+		s.requeue(job)
+		return
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+
+	switch {
+	case err != nil:
+		job.Status = models.JobStatusFailed
+		job.Error = err.Error()
+	case cancelled:
+		job.Status = models.JobStatusCancelled
+	default:
+		job.Status = models.JobStatusCompleted
+	}
+
+	if len(optimizations) > 0 {
+		// A request split by glass type points at the first of its optimizations
+		job.OptimizationID = &optimizations[0].ID
+
+		usedArea, totalArea := 0.0, 0.0
+		job.Progress.SheetsUsed, job.Progress.PiecesPlaced = 0, 0
+		for _, optimization := range optimizations {
+			usedArea += optimization.UsedArea
+			totalArea += optimization.TotalArea
+			job.Progress.SheetsUsed += optimization.Layout.SheetCount()
+			job.Progress.PiecesPlaced += len(optimization.Layout.AllPieces())
+		}
+		if totalArea > 0 {
+			job.Progress.BestUtilization = usedArea / totalArea * 100
+		}
+	}
+
+	if err := s.finish(job); err != nil {
+		s.logger.Error("Failed to record optimization job outcome", "error", err, "id", job.ID)
+	}
+
+	s.logger.Info("Optimization job finished", "id", job.ID, "status", job.Status)
+}
+
+// runRequest runs the job's request as one optimization, or as one per glass
+// type when the request is split by glass
+func (s *JobService) runRequest(ctx context.Context, job *models.OptimizationJob) ([]*models.Optimization, error) {
+	if job.Request.SplitByGlass {
+		return s.optimizer.RunOptimizationSet(ctx, &job.Request, job.UserID)
+	}
+
+	optimization, err := s.optimizer.RunOptimization(ctx, &job.Request, job.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return []*models.Optimization{optimization}, nil
+}
+
+// requeue resets a job that did not finish to the queued state
+func (s *JobService) requeue(job *models.OptimizationJob) {
+	job.Status = models.JobStatusQueued
+	job.StartedAt = nil
+	job.Progress = models.JobProgress{}
+	if err := s.storage.UpdateOptimizationJob(job); err != nil {
+		s.logger.Error("Failed to requeue optimization job", "error", err, "id", job.ID)
+	}
+}
+
+// finish saves a job in its final state and closes its watchers
+func (s *JobService) finish(job *models.OptimizationJob) error {
+	err := s.storage.UpdateOptimizationJob(job)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.watchers[job.ID] {
+		select {
+		case ch <- *job:
+		default:
+		}
+		close(ch)
+	}
+	delete(s.watchers, job.ID)
+
+	return err
+}
+
+// notify sends the job's current state to its watchers without blocking
+func (s *JobService) notify(job *models.OptimizationJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.watchers[job.ID] {
+		select {
+		case ch <- *job:
+		default:
+		}
+	}
+}
+
+// signal wakes an idle worker
+func (s *JobService) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"glass-optimizer/internal/models"
+)
+
+func newTestJobRequest(algorithm string) *models.OptimizationRequest {
+	return &models.OptimizationRequest{
+		Name:      "Job " + algorithm,
+		SheetID:   1,
+		Algorithm: algorithm,
+		Designs: []models.DesignItem{
+			{DesignID: 0, Name: "Panel", Width: 700, Height: 1300, Quantity: 5},
+			{DesignID: 0, Name: "Tile", Width: 450, Height: 450, Quantity: 8},
+		},
+		Options: models.OptimizeOptions{AllowRotation: true, PopulationSize: 10, MaxIterations: 10, Seed: 3},
+	}
+}
+
+// waitForJob watches a job, calls start if given, and collects the updates of
+// the job until it finishes; it returns them with the final state
+func waitForJob(t *testing.T, jobs *JobService, id int, userID int64, start func()) ([]models.OptimizationJob, *models.OptimizationJob) {
+	t.Helper()
+
+	_, updates, stop, err := jobs.WatchJob(id, userID)
+	if err != nil {
+		t.Fatalf("WatchJob() error = %v", err)
+	}
+	defer stop()
+
+	if start != nil {
+		start()
+	}
+
+	var seen []models.OptimizationJob
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				final, err := jobs.GetJob(id, userID)
+				if err != nil {
+					t.Fatalf("GetJob() error = %v", err)
+				}
+				return seen, final
+			}
+			seen = append(seen, update)
+		case <-timeout:
+			t.Fatalf("Job %d did not finish", id)
+		}
+	}
+}
+
+func TestJobReportsProgressAndCompletes(t *testing.T) {
+	optimizer, store, userID := newTestOptimizer(t)
+	jobs := NewJobService(store, optimizer, optimizer.logger, 1)
+
+	job, err := jobs.Submit(newTestJobRequest("genetic"), userID)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if job.Status != models.JobStatusQueued {
+		t.Errorf("Status incorrect: got %s, want %s", job.Status, models.JobStatusQueued)
+	}
+
+	// Watch before starting so that no update is missed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates, final := waitForJob(t, jobs, job.ID, userID, func() {
+		if err := jobs.Start(ctx); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+	})
+
+	if final.Status != models.JobStatusCompleted {
+		t.Fatalf("Final status incorrect: got %s, want %s (%s)", final.Status, models.JobStatusCompleted, final.Error)
+	}
+	if final.OptimizationID == nil {
+		t.Fatalf("Completed job has no optimization")
+	}
+	if _, err := optimizer.GetOptimization(*final.OptimizationID, userID); err != nil {
+		t.Errorf("GetOptimization() error = %v", err)
+	}
+
+	maxGeneration := 0
+	for _, update := range updates {
+		if update.Progress.Generation > maxGeneration {
+			maxGeneration = update.Progress.Generation
+		}
+	}
+	if maxGeneration == 0 {
+		t.Errorf("Expected generation progress, got %d updates", len(updates))
+	}
+	if final.Progress.SheetsUsed == 0 || final.Progress.BestUtilization <= 0 || final.Progress.PiecesPlaced != 13 {
+		t.Errorf("Final progress incorrect: %+v", final.Progress)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	optimizer, store, userID := newTestOptimizer(t)
+	jobs := NewJobService(store, optimizer, optimizer.logger, 1)
+
+	job, err := jobs.Submit(newTestJobRequest("blf"), userID)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	other := &models.User{Email: "other@example.com", PasswordHash: "x", FirstName: "Other", LastName: "User"}
+	if err := store.CreateUser(other); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := jobs.CancelJob(job.ID, other.ID); !models.IsNotFoundError(err) {
+		t.Errorf("Cancelling another user's job: got %v, want not found", err)
+	}
+
+	cancelled, err := jobs.CancelJob(job.ID, userID)
+	if err != nil {
+		t.Fatalf("CancelJob() error = %v", err)
+	}
+	if cancelled.Status != models.JobStatusCancelled {
+		t.Errorf("Status incorrect: got %s, want %s", cancelled.Status, models.JobStatusCancelled)
+	}
+
+	if _, err := jobs.CancelJob(job.ID, userID); !models.IsConflictError(err) {
+		t.Errorf("Cancelling a finished job: got %v, want conflict", err)
+	}
+}
+
+func TestUnfinishedJobsResumeOnStart(t *testing.T) {
+	optimizer, store, userID := newTestOptimizer(t)
+
+	// Jobs left behind by a previous process: one queued, one interrupted mid-run
+	previous := NewJobService(store, optimizer, optimizer.logger, 1)
+	queued, err := previous.Submit(newTestJobRequest("greedy"), userID)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	interrupted, err := previous.Submit(newTestJobRequest("blf"), userID)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	startedAt := time.Now()
+	interrupted.Status = models.JobStatusRunning
+	interrupted.StartedAt = &startedAt
+	if err := store.UpdateOptimizationJob(interrupted); err != nil {
+		t.Fatalf("UpdateOptimizationJob() error = %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	restarted := NewJobService(store, optimizer, logger, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := restarted.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	for _, job := range []*models.OptimizationJob{queued, interrupted} {
+		_, final := waitForJob(t, restarted, job.ID, userID, nil)
+		if final.Status != models.JobStatusCompleted {
+			t.Errorf("Job %d status incorrect: got %s, want %s", job.ID, final.Status, models.JobStatusCompleted)
+		}
+	}
+}
+
+func TestShutdownRequeuesRunningJob(t *testing.T) {
+	optimizer, store, userID := newTestOptimizer(t)
+	jobs := NewJobService(store, optimizer, optimizer.logger, 1)
+
+	req := newTestJobRequest("genetic")
+	req.Options.MaxIterations = 1000000
+	job, err := jobs.Submit(req, userID)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	_, updates, stopWatching, err := jobs.WatchJob(job.ID, userID)
+	if err != nil {
+		t.Fatalf("WatchJob() error = %v", err)
+	}
+	defer stopWatching()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := jobs.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for update := range updates {
+		if update.Progress.Generation > 0 {
+			break
+		}
+	}
+
+	// Shutting down stops the job part way and queues it again without
+	// keeping its partial layout
+	cancel()
+	jobs.Wait()
+
+	requeued, err := jobs.GetJob(job.ID, userID)
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	if requeued.Status != models.JobStatusQueued || requeued.StartedAt != nil || requeued.OptimizationID != nil {
+		t.Errorf("Interrupted job incorrect: got status %s, started %v, optimization %v", requeued.Status, requeued.StartedAt, requeued.OptimizationID)
+	}
+	if _, total, err := store.GetOptimizations(userID, 10, 0); err != nil || total != 0 {
+		t.Errorf("Optimizations after shutdown incorrect: got %d (%v), want %d", total, err, 0)
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"math"
+
+	"glass-optimizer/internal/models"
+)
+
+// MaxRects Algorithm
+//
+// The free area of the sheet is kept as a list of maximal free rectangles
+// that may overlap. At every step all remaining pieces are scored against all
+// free rectangles with the selected rule and the best pair is placed, after
+// which the free rectangles are split around it and pruned.
+//
+// Pieces are placed with a footprint grown by the minimum gap on their right
+// and top sides; the free area is grown by the same amount so that pieces can
+// still reach the far edges of the usable area.
+
+// MaxRects placement rules
+const (
+	maxRectsBestShortSideFit = "bssf"
+	maxRectsBestLongSideFit  = "blsf"
+	maxRectsBestAreaFit      = "baf"
+	maxRectsContactPoint     = "cp"
+)
+
+// maxRectsAlgorithms maps Algorithm values to MaxRects placement rules
+var maxRectsAlgorithms = map[string]string{
+	"maxrects-bssf": maxRectsBestShortSideFit,
+	"maxrects-blsf": maxRectsBestLongSideFit,
+	"maxrects-baf":  maxRectsBestAreaFit,
+	"maxrects-cp":   maxRectsContactPoint,
+}
+
+func (s *OptimizerService) runMaxRects(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions, rule string) (*models.Layout, error) {
+	s.logger.Debug("Running MaxRects algorithm", "rule", rule)
+
+	pack := func(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace) {
+		return s.placeMaxRects(ctx, sheet, pieces, options, rule)
+	}
+
+	return s.packOrdered(ctx, stock, pieces, options, pack), nil
+}
+
+// placeMaxRects fills a single sheet with the given MaxRects rule
+func (s *OptimizerService) placeMaxRects(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions, rule string) ([]models.PlacedPiece, []PieceToPlace) {
+	gap := pieceSpacing(options)
+	bin := usableArea(sheet, options)
+	bin.Width += gap
+	bin.Height += gap
+
+	freeRects := []Rectangle{bin}
+	for _, defect := range footprintDefectAreas(sheet.Defects, gap) {
+		freeRects = s.updateAvailableSpaces(freeRects, defect)
+	}
+	var used []Rectangle
+	var placedPieces []models.PlacedPiece
+
+	remaining := make([]PieceToPlace, len(pieces))
+	copy(remaining, pieces)
+
+	for len(remaining) > 0 && ctx.Err() == nil {
+		bestIndex := -1
+		var bestOrientation Orientation
+		var bestPosition Rectangle
+		bestScore1, bestScore2 := math.MaxFloat64, math.MaxFloat64
+
+		for i, piece := range remaining {
+			// Only the most urgent pieces that fit compete for the best position
+			if bestIndex >= 0 && moreUrgent(&remaining[bestIndex], &remaining[i]) {
+				break
+			}
+
+			for _, orientation := range s.getOrientations(piece, options.AllowRotation) {
+				footprintWidth, footprintHeight := orientation.Width+gap, orientation.Height+gap
+
+				for _, free := range freeRects {
+					if footprintWidth > free.Width || footprintHeight > free.Height {
+						continue
+					}
+
+					position := Rectangle{X: free.X, Y: free.Y, Width: footprintWidth, Height: footprintHeight}
+					score1, score2 := maxRectsScore(rule, free, position, bin, used)
+					if score1 < bestScore1 || (score1 == bestScore1 && score2 < bestScore2) {
+						bestIndex = i
+						bestOrientation = orientation
+						bestPosition = position
+						bestScore1, bestScore2 = score1, score2
+					}
+				}
+			}
+		}
+
+		if bestIndex < 0 {
+			break
+		}
+
+		placedPieces = append(placedPieces, placePiece(remaining[bestIndex], bestPosition.X, bestPosition.Y, bestOrientation))
+
+		used = append(used, bestPosition)
+		freeRects = s.updateAvailableSpaces(freeRects, bestPosition)
+		remaining = append(remaining[:bestIndex], remaining[bestIndex+1:]...)
+	}
+
+	return placedPieces, remaining
+}
+
+// maxRectsScore rates placing a footprint in the bottom-left corner of a free
+// rectangle; lower is better and the second value breaks ties
+func maxRectsScore(rule string, free, position, bin Rectangle, used []Rectangle) (float64, float64) {
+	leftoverHorizontal := free.Width - position.Width
+	leftoverVertical := free.Height - position.Height
+	shortSide := math.Min(leftoverHorizontal, leftoverVertical)
+	longSide := math.Max(leftoverHorizontal, leftoverVertical)
+
+	switch rule {
+	case maxRectsBestLongSideFit:
+		return longSide, shortSide
+	case maxRectsBestAreaFit:
+		return free.Width*free.Height - position.Width*position.Height, shortSide
+	case maxRectsContactPoint:
+		// Maximise the perimeter touching the sheet edges and placed pieces
+		return -contactPerimeter(position, bin, used), position.Y
+	default:
+		return shortSide, longSide
+	}
+}
+
+// contactPerimeter returns how much of the rectangle's perimeter touches the
+// bin edges or already placed rectangles
+func contactPerimeter(rect, bin Rectangle, used []Rectangle) float64 {
+	contact := 0.0
+
+	if rect.X == bin.X || rect.X+rect.Width == bin.X+bin.Width {
+		contact += rect.Height
+	}
+	if rect.Y == bin.Y || rect.Y+rect.Height == bin.Y+bin.Height {
+		contact += rect.Width
+	}
+
+	for _, other := range used {
+		if other.X == rect.X+rect.Width || other.X+other.Width == rect.X {
+			contact += overlapLength(rect.Y, rect.Y+rect.Height, other.Y, other.Y+other.Height)
+		}
+		if other.Y == rect.Y+rect.Height || other.Y+other.Height == rect.Y {
+			contact += overlapLength(rect.X, rect.X+rect.Width, other.X, other.X+other.Width)
+		}
+	}
+
+	return contact
+}
+
+// overlapLength returns the length shared by the intervals [a1, a2] and [b1, b2]
+func overlapLength(a1, a2, b1, b2 float64) float64 {
+	return math.Max(0, math.Min(a2, b2)-math.Max(a1, b1))
+}
+
+// Skyline Algorithm
+//
+// The packed area is described by its upper contour, a list of horizontal
+// segments from left to right. Each piece goes where its top edge ends up
+// lowest, preferring the leftmost such position.
+
+// skylineSegment is one horizontal step of the skyline
+type skylineSegment struct {
+	X, Y, Width float64
+}
+
+func (s *OptimizerService) runSkyline(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	s.logger.Debug("Running Skyline algorithm")
+
+	return s.packOrdered(ctx, stock, pieces, options, s.placeSkyline), nil
+}
+
+// placeSkyline fills a single sheet using the skyline bottom-left rule
+func (s *OptimizerService) placeSkyline(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace) {
+	gap := pieceSpacing(options)
+	area := usableArea(sheet, options)
+	binX, binY := area.X, area.Y
+	binWidth := area.Width + gap
+	binHeight := area.Height + gap
+
+	defects := footprintDefectAreas(sheet.Defects, gap)
+	skyline := []skylineSegment{{X: binX, Y: binY, Width: binWidth}}
+	var placedPieces []models.PlacedPiece
+	var unplaced []PieceToPlace
+
+	for i, piece := range pieces {
+		if ctx.Err() != nil {
+			unplaced = append(unplaced, pieces[i:]...)
+			break
+		}
+
+		bestIndex := -1
+		var bestOrientation Orientation
+		bestTop, bestWidth, bestY := math.MaxFloat64, math.MaxFloat64, 0.0
+
+		for _, orientation := range s.getOrientations(piece, options.AllowRotation) {
+			footprintWidth, footprintHeight := orientation.Width+gap, orientation.Height+gap
+
+			for i := range skyline {
+				y, ok := skylineFit(skyline, i, footprintWidth, binX+binWidth)
+				if !ok {
+					continue
+				}
+
+				// Lift the footprint above any defect in the way
+				for {
+					defect, blocked := s.firstOverlap(Rectangle{X: skyline[i].X, Y: y, Width: footprintWidth, Height: footprintHeight}, defects)
+					if !blocked {
+						break
+					}
+					y = defect.Y + defect.Height
+				}
+				if y+footprintHeight > binY+binHeight {
+					continue
+				}
+
+				top := y + footprintHeight
+				if top < bestTop || (top == bestTop && skyline[i].Width < bestWidth) {
+					bestIndex = i
+					bestOrientation = orientation
+					bestTop, bestWidth, bestY = top, skyline[i].Width, y
+				}
+			}
+		}
+
+		if bestIndex < 0 {
+			unplaced = append(unplaced, piece)
+			continue
+		}
+
+		x := skyline[bestIndex].X
+		placedPieces = append(placedPieces, placePiece(piece, x, bestY, bestOrientation))
+
+		skyline = addSkylineLevel(skyline, bestIndex, skylineSegment{
+			X: x, Y: bestTop, Width: bestOrientation.Width + gap,
+		})
+	}
+
+	return placedPieces, unplaced
+}
+
+// skylineFit returns the height at which a footprint of the given width rests
+// when its left edge is aligned with segment i
+func skylineFit(skyline []skylineSegment, i int, width, right float64) (float64, bool) {
+	x := skyline[i].X
+	if x+width > right {
+		return 0, false
+	}
+
+	y := 0.0
+	remaining := width
+	for j := i; remaining > 0; j++ {
+		if j == len(skyline) {
+			return 0, false
+		}
+		y = math.Max(y, skyline[j].Y)
+		remaining -= skyline[j].Width
+	}
+
+	return y, true
+}
+
+// addSkylineLevel raises the skyline under a newly placed footprint starting
+// at segment i and merges neighbouring segments of equal height
+func addSkylineLevel(skyline []skylineSegment, i int, level skylineSegment) []skylineSegment {
+	updated := make([]skylineSegment, 0, len(skyline)+1)
+	updated = append(updated, skyline[:i]...)
+	updated = append(updated, level)
+
+	end := level.X + level.Width
+	for _, segment := range skyline[i:] {
+		segmentEnd := segment.X + segment.Width
+		if segmentEnd <= end {
+			continue
+		}
+		if segment.X < end {
+			segment.Width = segmentEnd - end
+			segment.X = end
+		}
+		updated = append(updated, segment)
+	}
+
+	merged := updated[:1]
+	for _, segment := range updated[1:] {
+		last := &merged[len(merged)-1]
+		if last.Y == segment.Y {
+			last.Width += segment.Width
+			continue
+		}
+		merged = append(merged, segment)
+	}
+
+	return merged
+}
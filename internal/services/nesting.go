@@ -0,0 +1,670 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"glass-optimizer/internal/models"
+)
+
+// True-Shape Nesting
+//
+// The nfp algorithm packs the real outline of each design instead of its
+// bounding box. Outlines are split into convex parts, and the no-fit polygon
+// of a placed part and a moving part is their Minkowski difference grown by
+// the clearance the two pieces must keep. A piece may go at any point inside
+// the sheet's inner-fit rectangle and outside the no-fit polygons of the
+// pieces already placed; the candidates are the vertices and edge crossings
+// of those polygons, tried bottom-left first.
+
+const (
+	// defaultRotationStep is the rotation increment, in degrees, tried for
+	// nested pieces when the request allows rotation but sets no step
+	defaultRotationStep = 90
+
+	// curveTolerance is the furthest, in millimeters, a polygonized circle or
+	// ellipse may stray from the true curve
+	curveTolerance = 0.5
+
+	minCurveSegments = 16
+	maxCurveSegments = 64
+
+	nestEpsilon = 1e-6
+)
+
+// nestShape is a piece outline in one orientation with the corner of its
+// bounding box at the origin
+type nestShape struct {
+	rotation int
+	outline  []models.Point
+	parts    [][]models.Point // Convex parts covering the outline, counter-clockwise
+	width    float64
+	height   float64
+}
+
+// nestedPiece is a shape placed on the sheet being nested
+type nestedPiece struct {
+	x, y      float64
+	shape     *nestShape
+	tolerance float64
+}
+
+// noFitPolygon is a convex no-fit polygon with its bounding box
+type noFitPolygon struct {
+	points                 []models.Point
+	minX, minY, maxX, maxY float64
+}
+
+func (s *OptimizerService) runNesting(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	s.logger.Debug("Running true-shape nesting")
+
+	return s.packOrdered(ctx, stock, pieces, options, s.placeNesting), nil
+}
+
+// placeNesting fills a single sheet with the true outlines of the pieces.
+// Each piece goes where its lowest orientation ends lowest on the sheet.
+func (s *OptimizerService) placeNesting(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace) {
+	var placedPieces []models.PlacedPiece
+	var unplaced []PieceToPlace
+	var nested []nestedPiece
+
+	// Defects are kept clear of like pieces already nested
+	for _, defect := range defectAreas(sheet.Defects, 0) {
+		outline := rectangleOutline(Rectangle{Width: defect.Width, Height: defect.Height})
+		nested = append(nested, nestedPiece{x: defect.X, y: defect.Y, shape: &nestShape{
+			outline: outline,
+			parts:   [][]models.Point{outline},
+			width:   defect.Width,
+			height:  defect.Height,
+		}})
+	}
+
+	for i, piece := range pieces {
+		if ctx.Err() != nil {
+			unplaced = append(unplaced, pieces[i:]...)
+			break
+		}
+
+		var best *nestedPiece
+		for _, shape := range nestShapes(piece, options) {
+			x, y, ok := nestPosition(sheet, nested, shape, piece.Tolerance, options)
+			if !ok {
+				continue
+			}
+			if best == nil || y+shape.height < best.y+best.shape.height-nestEpsilon ||
+				(math.Abs(y+shape.height-best.y-best.shape.height) <= nestEpsilon && x < best.x) {
+				best = &nestedPiece{x: x, y: y, shape: shape, tolerance: piece.Tolerance}
+			}
+		}
+
+		if best == nil {
+			unplaced = append(unplaced, piece)
+			continue
+		}
+
+		nested = append(nested, *best)
+		placedPiece := placePiece(piece, best.x, best.y, Orientation{
+			Width:    best.shape.width,
+			Height:   best.shape.height,
+			Rotation: best.shape.rotation,
+		})
+		if piece.Outline != nil || best.shape.rotation%90 != 0 {
+			placedPiece.Outline = translateOutline(best.shape.outline, best.x, best.y)
+		}
+		placedPieces = append(placedPieces, placedPiece)
+	}
+
+	return placedPieces, unplaced
+}
+
+// nestShapes returns the orientations a piece may be nested in. Rotations
+// that leave the outline unchanged, such as those of a circle, are skipped.
+func nestShapes(piece PieceToPlace, options *models.OptimizeOptions) []*nestShape {
+	outline := piece.Outline
+	if outline == nil {
+		outline = rectangleOutline(Rectangle{Width: piece.Width, Height: piece.Height})
+	}
+
+	rotations := []int{0}
+	switch {
+	case piece.Grain == grainUpright && options.AllowRotation:
+		rotations = []int{0, 180}
+	case piece.Grain == grainTurned && options.AllowRotation:
+		rotations = []int{90, 270}
+	case piece.Grain == grainTurned:
+		rotations = []int{90}
+	case piece.Grain == grainFree && options.AllowRotation:
+		step := options.RotationStep
+		if step == 0 {
+			step = defaultRotationStep
+		}
+		for rotation := step; rotation < 360; rotation += step {
+			rotations = append(rotations, rotation)
+		}
+	}
+
+	var shapes []*nestShape
+	for _, rotation := range rotations {
+		rotated := rotateOutline(outline, rotation)
+
+		duplicate := false
+		for _, shape := range shapes {
+			if sameOutline(shape.outline, rotated) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		width, height := outlineSize(rotated)
+		shapes = append(shapes, &nestShape{
+			rotation: rotation,
+			outline:  rotated,
+			parts:    convexParts(rotated),
+			width:    width,
+			height:   height,
+		})
+	}
+
+	return shapes
+}
+
+// nestPosition finds the bottom-left point at which the shape fits on the
+// sheet clear of the pieces already nested
+func nestPosition(sheet *models.GlassSheet, nested []nestedPiece, shape *nestShape, tolerance float64, options *models.OptimizeOptions) (float64, float64, bool) {
+	fit := usableArea(sheet, options)
+	fit.Width -= shape.width
+	fit.Height -= shape.height
+	if fit.Width < -nestEpsilon || fit.Height < -nestEpsilon {
+		return 0, 0, false
+	}
+	fit.Width = math.Max(fit.Width, 0)
+	fit.Height = math.Max(fit.Height, 0)
+
+	var nfps []noFitPolygon
+	for _, other := range nested {
+		clearance := pieceSpacing(options) + tolerance + other.tolerance
+		for _, fixed := range other.shape.parts {
+			for _, moving := range shape.parts {
+				nfp := newNoFitPolygon(translateOutline(minkowskiDifference(fixed, moving, clearance), other.x, other.y))
+				if nfp.maxX >= fit.X && nfp.minX <= fit.X+fit.Width && nfp.maxY >= fit.Y && nfp.minY <= fit.Y+fit.Height {
+					nfps = append(nfps, nfp)
+				}
+			}
+		}
+	}
+
+	candidates := []models.Point{
+		{X: fit.X, Y: fit.Y},
+		{X: fit.X + fit.Width, Y: fit.Y},
+		{X: fit.X, Y: fit.Y + fit.Height},
+		{X: fit.X + fit.Width, Y: fit.Y + fit.Height},
+	}
+	fitEdges := outlineEdges([]models.Point{candidates[0], candidates[1], candidates[3], candidates[2]})
+
+	for i, nfp := range nfps {
+		candidates = append(candidates, nfp.points...)
+
+		for _, edge := range outlineEdges(nfp.points) {
+			for _, fitEdge := range fitEdges {
+				if point, ok := segmentIntersection(edge[0], edge[1], fitEdge[0], fitEdge[1]); ok {
+					candidates = append(candidates, point)
+				}
+			}
+		}
+
+		for _, other := range nfps[i+1:] {
+			if !nfp.overlaps(other) {
+				continue
+			}
+			for _, edge := range outlineEdges(nfp.points) {
+				for _, otherEdge := range outlineEdges(other.points) {
+					if point, ok := segmentIntersection(edge[0], edge[1], otherEdge[0], otherEdge[1]); ok {
+						candidates = append(candidates, point)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Y != candidates[j].Y {
+			return candidates[i].Y < candidates[j].Y
+		}
+		return candidates[i].X < candidates[j].X
+	})
+
+	for _, point := range candidates {
+		if point.X < fit.X-nestEpsilon || point.X > fit.X+fit.Width+nestEpsilon ||
+			point.Y < fit.Y-nestEpsilon || point.Y > fit.Y+fit.Height+nestEpsilon {
+			continue
+		}
+
+		free := true
+		for _, nfp := range nfps {
+			if nfp.containsStrictly(point) {
+				free = false
+				break
+			}
+		}
+		if free {
+			x := math.Min(math.Max(point.X, fit.X), fit.X+fit.Width)
+			y := math.Min(math.Max(point.Y, fit.Y), fit.Y+fit.Height)
+			return x, y, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// designOutline returns the outline of the first visible shape of a design in
+// design coordinates, together with how far the outline may lie inside the
+// true curve. Rectangles, and designs without a usable shape,
+// return nil and are nested as their bounding box.
+func designOutline(design *models.Design) ([]models.Point, float64) {
+	if design == nil {
+		return nil, 0
+	}
+
+	for _, shape := range design.Elements.Shapes {
+		if !shape.Visible {
+			continue
+		}
+
+		var outline []models.Point
+		tolerance := 0.0
+
+		switch shape.Type {
+		case models.ShapeCircle, models.ShapeEllipse:
+			// Points holds the center, optionally followed by a point on the
+			// curve; otherwise the curve fills the design's bounding box
+			if len(shape.Points) == 0 {
+				return nil, 0
+			}
+			center := shape.Points[0]
+			rx, ry := design.Width/2, design.Height/2
+			if shape.Type == models.ShapeCircle {
+				rx = math.Min(rx, ry)
+				ry = rx
+			}
+			if len(shape.Points) > 1 {
+				edge := shape.Points[1]
+				if shape.Type == models.ShapeCircle {
+					rx = math.Hypot(edge.X-center.X, edge.Y-center.Y)
+					ry = rx
+				} else {
+					rx, ry = math.Abs(edge.X-center.X), math.Abs(edge.Y-center.Y)
+				}
+			}
+			outline, tolerance = ellipseOutline(center, rx, ry)
+		case models.ShapePolygon, models.ShapeCustom:
+			outline = append([]models.Point(nil), shape.Points...)
+		default:
+			return nil, 0
+		}
+
+		outline = simplifyOutline(outline)
+		if len(outline) < 3 {
+			return nil, 0
+		}
+		if polygonArea(outline) < 0 {
+			for i, j := 0, len(outline)-1; i < j; i, j = i+1, j-1 {
+				outline[i], outline[j] = outline[j], outline[i]
+			}
+		}
+
+		return outline, tolerance
+	}
+
+	return nil, 0
+}
+
+// ellipseOutline returns a polygon with its vertices on the ellipse, with
+// enough of them to keep its edges within curveTolerance of the curve, and
+// the distance by which its edges actually fall inside the curve
+func ellipseOutline(center models.Point, rx, ry float64) ([]models.Point, float64) {
+	radius := math.Max(rx, ry)
+
+	segments := maxCurveSegments
+	if radius > curveTolerance {
+		segments = int(math.Ceil(math.Pi / math.Acos(1-curveTolerance/radius)))
+	}
+	// A multiple of 8 keeps the outline unchanged under 45 degree rotations
+	segments = (segments + 7) / 8 * 8
+	segments = max(minCurveSegments, min(segments, maxCurveSegments))
+
+	outline := make([]models.Point, segments)
+	for i := range outline {
+		angle := 2 * math.Pi * float64(i) / float64(segments)
+		outline[i] = models.Point{X: center.X + rx*math.Cos(angle), Y: center.Y + ry*math.Sin(angle)}
+	}
+
+	return outline, radius * (1 - math.Cos(math.Pi/float64(segments)))
+}
+
+// rotateOutline rotates an outline by the given angle in degrees and moves
+// its bounding box back to the origin
+func rotateOutline(outline []models.Point, degrees int) []models.Point {
+	return rotateInFrame(outline, outline, degrees)
+}
+
+// rotateInFrame rotates points lying within a frame outline by the given
+// angle in degrees, and moves them along with the frame so that the bounding
+// box of the rotated frame is at the origin
+func rotateInFrame(points, frame []models.Point, degrees int) []models.Point {
+	angle := float64(degrees) * math.Pi / 180
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	if degrees%90 == 0 {
+		sin, cos = math.Round(sin), math.Round(cos)
+	}
+	rotate := func(points []models.Point) []models.Point {
+		rotated := make([]models.Point, len(points))
+		for i, point := range points {
+			rotated[i] = models.Point{X: point.X*cos - point.Y*sin, Y: point.X*sin + point.Y*cos}
+		}
+		return rotated
+	}
+
+	minX, minY := outlineOrigin(rotate(frame))
+	return translateOutline(rotate(points), -minX, -minY)
+}
+
+func translateOutline(outline []models.Point, dx, dy float64) []models.Point {
+	translated := make([]models.Point, len(outline))
+	for i, point := range outline {
+		translated[i] = models.Point{X: point.X + dx, Y: point.Y + dy}
+	}
+	return translated
+}
+
+func outlineOrigin(outline []models.Point) (float64, float64) {
+	minX, minY := math.MaxFloat64, math.MaxFloat64
+	for _, point := range outline {
+		minX = math.Min(minX, point.X)
+		minY = math.Min(minY, point.Y)
+	}
+	return minX, minY
+}
+
+// outlineSize returns the size of the bounding box of an outline at the origin
+func outlineSize(outline []models.Point) (float64, float64) {
+	width, height := 0.0, 0.0
+	for _, point := range outline {
+		width = math.Max(width, point.X)
+		height = math.Max(height, point.Y)
+	}
+	return width, height
+}
+
+// sameOutline reports whether two outlines have the same vertices in the
+// same cyclic order
+func sameOutline(a, b []models.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	const tolerance = 1e-3
+	for shift := range b {
+		same := true
+		for i := range a {
+			other := b[(i+shift)%len(b)]
+			if math.Abs(a[i].X-other.X) > tolerance || math.Abs(a[i].Y-other.Y) > tolerance {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// simplifyOutline drops repeated and collinear vertices, including a closing
+// vertex that repeats the first
+func simplifyOutline(outline []models.Point) []models.Point {
+	simplified := make([]models.Point, 0, len(outline))
+	for _, point := range outline {
+		if n := len(simplified); n > 0 && samePoint(simplified[n-1], point) {
+			continue
+		}
+		simplified = append(simplified, point)
+	}
+	if n := len(simplified); n > 1 && samePoint(simplified[0], simplified[n-1]) {
+		simplified = simplified[:n-1]
+	}
+
+	for changed := true; changed && len(simplified) > 3; {
+		changed = false
+		for i := range simplified {
+			n := len(simplified)
+			prev, next := simplified[(i+n-1)%n], simplified[(i+1)%n]
+			if math.Abs(cross(prev, simplified[i], next)) <= nestEpsilon {
+				simplified = append(simplified[:i], simplified[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
+	return simplified
+}
+
+func samePoint(a, b models.Point) bool {
+	return math.Abs(a.X-b.X) <= nestEpsilon && math.Abs(a.Y-b.Y) <= nestEpsilon
+}
+
+// polygonArea returns the signed area of a polygon, positive when its
+// vertices run counter-clockwise
+func polygonArea(outline []models.Point) float64 {
+	area := 0.0
+	for i, point := range outline {
+		next := outline[(i+1)%len(outline)]
+		area += point.X*next.Y - next.X*point.Y
+	}
+	return area / 2
+}
+
+// cross returns the cross product of (a - o) and (b - o), positive when o, a,
+// b turn counter-clockwise
+func cross(o, a, b models.Point) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+// convexParts splits a counter-clockwise outline into convex parts: the
+// outline itself when it is convex, or the triangles of an ear-clipping
+// triangulation. Outlines that cannot be triangulated, such as
+// self-intersecting ones, are covered by their convex hull.
+func convexParts(outline []models.Point) [][]models.Point {
+	convex := true
+	for i := range outline {
+		n := len(outline)
+		if cross(outline[(i+n-1)%n], outline[i], outline[(i+1)%n]) < 0 {
+			convex = false
+			break
+		}
+	}
+	if convex {
+		return [][]models.Point{outline}
+	}
+
+	remaining := append([]models.Point(nil), outline...)
+	var triangles [][]models.Point
+	for len(remaining) > 3 {
+		n := len(remaining)
+		ear := -1
+		for i := range remaining {
+			prev, point, next := remaining[(i+n-1)%n], remaining[i], remaining[(i+1)%n]
+			if cross(prev, point, next) <= nestEpsilon {
+				continue
+			}
+
+			blocked := false
+			for j, other := range remaining {
+				if j == i || j == (i+n-1)%n || j == (i+1)%n {
+					continue
+				}
+				if cross(prev, point, other) >= 0 && cross(point, next, other) >= 0 && cross(next, prev, other) >= 0 {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ear = i
+				break
+			}
+		}
+
+		if ear < 0 {
+			return [][]models.Point{convexHull(outline)}
+		}
+
+		triangles = append(triangles, []models.Point{remaining[(ear+n-1)%n], remaining[ear], remaining[(ear+1)%n]})
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+	}
+
+	return append(triangles, remaining)
+}
+
+// convexHull returns the convex hull of the points counter-clockwise, without
+// collinear vertices
+func convexHull(points []models.Point) []models.Point {
+	sorted := append([]models.Point(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].X != sorted[j].X {
+			return sorted[i].X < sorted[j].X
+		}
+		return sorted[i].Y < sorted[j].Y
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	hull := make([]models.Point, 0, 2*len(sorted))
+	for _, point := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], point) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, point)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		point := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], point) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, point)
+	}
+
+	return hull[:len(hull)-1]
+}
+
+// minkowskiDifference returns the no-fit polygon of two convex parts: the
+// positions of the moving part's origin, relative to the fixed part's, at
+// which the parts come closer than the clearance. The clearance disk is
+// approximated by a circumscribed octagon, which keeps pieces at least the
+// clearance apart.
+func minkowskiDifference(fixed, moving []models.Point, clearance float64) []models.Point {
+	sums := make([]models.Point, 0, len(fixed)*len(moving))
+	for _, a := range fixed {
+		for _, b := range moving {
+			sums = append(sums, models.Point{X: a.X - b.X, Y: a.Y - b.Y})
+		}
+	}
+	nfp := convexHull(sums)
+	if clearance <= 0 {
+		return nfp
+	}
+
+	radius := clearance / math.Cos(math.Pi/8)
+	grown := make([]models.Point, 0, len(nfp)*8)
+	for _, point := range nfp {
+		for i := 0; i < 8; i++ {
+			angle := float64(i) * math.Pi / 4
+			grown = append(grown, models.Point{X: point.X + radius*math.Cos(angle), Y: point.Y + radius*math.Sin(angle)})
+		}
+	}
+	return convexHull(grown)
+}
+
+func newNoFitPolygon(points []models.Point) noFitPolygon {
+	nfp := noFitPolygon{
+		points: points,
+		minX:   math.MaxFloat64, minY: math.MaxFloat64,
+		maxX: -math.MaxFloat64, maxY: -math.MaxFloat64,
+	}
+	for _, point := range points {
+		nfp.minX = math.Min(nfp.minX, point.X)
+		nfp.minY = math.Min(nfp.minY, point.Y)
+		nfp.maxX = math.Max(nfp.maxX, point.X)
+		nfp.maxY = math.Max(nfp.maxY, point.Y)
+	}
+	return nfp
+}
+
+func (nfp noFitPolygon) overlaps(other noFitPolygon) bool {
+	return nfp.minX <= other.maxX && other.minX <= nfp.maxX && nfp.minY <= other.maxY && other.minY <= nfp.maxY
+}
+
+// containsStrictly reports whether the point lies inside the polygon and not
+// on its boundary; a piece placed on the boundary just keeps the clearance
+func (nfp noFitPolygon) containsStrictly(point models.Point) bool {
+	if point.X <= nfp.minX || point.X >= nfp.maxX || point.Y <= nfp.minY || point.Y >= nfp.maxY {
+		return false
+	}
+
+	for i, a := range nfp.points {
+		b := nfp.points[(i+1)%len(nfp.points)]
+		if cross(a, b, point) <= nestEpsilon*math.Hypot(b.X-a.X, b.Y-a.Y) {
+			return false
+		}
+	}
+	return len(nfp.points) >= 3
+}
+
+// outlineEdges returns the edges of a closed outline as pairs of points
+func outlineEdges(outline []models.Point) [][2]models.Point {
+	edges := make([][2]models.Point, len(outline))
+	for i, point := range outline {
+		edges[i] = [2]models.Point{point, outline[(i+1)%len(outline)]}
+	}
+	return edges
+}
+
+// segmentIntersection returns the point where segments ab and cd cross
+func segmentIntersection(a, b, c, d models.Point) (models.Point, bool) {
+	denominator := (b.X-a.X)*(d.Y-c.Y) - (b.Y-a.Y)*(d.X-c.X)
+	if math.Abs(denominator) < nestEpsilon {
+		return models.Point{}, false
+	}
+
+	t := ((c.X-a.X)*(d.Y-c.Y) - (c.Y-a.Y)*(d.X-c.X)) / denominator
+	u := ((c.X-a.X)*(b.Y-a.Y) - (c.Y-a.Y)*(b.X-a.X)) / denominator
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return models.Point{}, false
+	}
+
+	return models.Point{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)}, true
+}
+
+// pieceOutline returns the outline of a placed piece in sheet coordinates
+func pieceOutline(piece models.PlacedPiece) []models.Point {
+	if len(piece.Outline) > 0 {
+		return piece.Outline
+	}
+
+	return rectangleOutline(Rectangle{X: piece.X, Y: piece.Y, Width: piece.Width, Height: piece.Height})
+}
+
+// rectangleOutline returns the corners of a rectangle counter-clockwise
+func rectangleOutline(rect Rectangle) []models.Point {
+	return []models.Point{
+		{X: rect.X, Y: rect.Y},
+		{X: rect.X + rect.Width, Y: rect.Y},
+		{X: rect.X + rect.Width, Y: rect.Y + rect.Height},
+		{X: rect.X, Y: rect.Y + rect.Height},
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestNestingPacksTrueShapes(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	disc := &models.Design{
+		Name:      "Table Top",
+		Width:     500,
+		Height:    500,
+		Thickness: 6,
+		UserID:    userID,
+		Elements: models.Elements{Shapes: []models.Shape{{
+			Type:    models.ShapeCircle,
+			Points:  []models.Point{{X: 250, Y: 250}},
+			Visible: true,
+		}}},
+	}
+	if err := store.CreateDesign(disc); err != nil {
+		t.Fatalf("CreateDesign() error = %v", err)
+	}
+
+	run := func(algorithm string) *models.Optimization {
+		t.Helper()
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name:      "Table tops " + algorithm,
+			SheetID:   1, // Standard 2m x 3m
+			Algorithm: algorithm,
+			Designs:   []models.DesignItem{{DesignID: disc.ID, Quantity: 18}},
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+		if got := len(optimization.Layout.AllPieces()); got != 18 {
+			t.Fatalf("Placed pieces incorrect for %s: got %d, want %d", algorithm, got, 18)
+		}
+		return optimization
+	}
+
+	// Bounding boxes fit 15 per sheet; staggered rows of discs fit all 18
+	if sheets := run("blf").Layout.SheetCount(); sheets != 2 {
+		t.Errorf("Bounding box sheets incorrect: got %d, want %d", sheets, 2)
+	}
+	nested := run("nfp")
+	if sheets := nested.Layout.SheetCount(); sheets != 1 {
+		t.Fatalf("Nested sheets incorrect: got %d, want %d", sheets, 1)
+	}
+
+	pieces := nested.Layout.AllPieces()
+	for i, a := range pieces {
+		if len(a.Outline) == 0 {
+			t.Fatalf("Piece %s has no outline", a.ID)
+		}
+		if a.X < 5 || a.Y < 5 || a.X+a.Width > 1995 || a.Y+a.Height > 2995 {
+			t.Errorf("Piece %s leaves the sheet margin: (%.1f,%.1f) %.1fx%.1f", a.ID, a.X, a.Y, a.Width, a.Height)
+		}
+		for _, b := range pieces[i+1:] {
+			distance := math.Hypot(a.X+a.Width/2-b.X-b.Width/2, a.Y+a.Height/2-b.Y-b.Height/2)
+			if distance < 500+2-1e-3 {
+				t.Errorf("Discs %s and %s closer than the gap: centers %.2f apart", a.ID, b.ID, distance)
+			}
+		}
+	}
+
+	want := 18 * math.Pi * 250 * 250
+	if got := nested.UsedArea; math.Abs(got-want)/want > 0.01 {
+		t.Errorf("Used area incorrect: got %.0f, want about %.0f", got, want)
+	}
+
+	svg, err := service.ExportOptimization(nested.ID, userID, "svg")
+	if err != nil {
+		t.Fatalf("ExportOptimization(svg) error = %v", err)
+	}
+	if got := strings.Count(svg.Data.(string), "<polygon"); got != 18 {
+		t.Errorf("SVG outlines incorrect: got %d, want %d", got, 18)
+	}
+
+	dxf, err := service.ExportOptimization(nested.ID, userID, "dxf")
+	if err != nil {
+		t.Fatalf("ExportOptimization(dxf) error = %v", err)
+	}
+	if got := strings.Count(dxf.Data.(string), "LWPOLYLINE"); got != 18 {
+		t.Errorf("DXF outlines incorrect: got %d, want %d", got, 18)
+	}
+}
+
+func TestNestingRotatesInSteps(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	// A right triangle: two of them rotated half a turn make a rectangle
+	wedge := &models.Design{
+		Name:      "Wedge",
+		Width:     1900,
+		Height:    1400,
+		Thickness: 6,
+		UserID:    userID,
+		Elements: models.Elements{Shapes: []models.Shape{{
+			Type:    models.ShapePolygon,
+			Points:  []models.Point{{X: 0, Y: 0}, {X: 1900, Y: 0}, {X: 0, Y: 1400}},
+			Visible: true,
+		}}},
+	}
+	if err := store.CreateDesign(wedge); err != nil {
+		t.Fatalf("CreateDesign() error = %v", err)
+	}
+
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name:      "Wedges",
+		SheetID:   1,
+		Algorithm: "nfp",
+		Designs:   []models.DesignItem{{DesignID: wedge.ID, Quantity: 4}},
+		Options:   models.OptimizeOptions{AllowRotation: true, RotationStep: 45},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+
+	pieces := optimization.Layout.AllPieces()
+	if len(pieces) != 4 || optimization.Layout.SheetCount() != 1 {
+		t.Fatalf("Expected 4 wedges on one sheet, got %d on %d sheets", len(pieces), optimization.Layout.SheetCount())
+	}
+
+	rotated := false
+	for _, piece := range pieces {
+		if piece.Rotation%45 != 0 {
+			t.Errorf("Rotation %d is not a multiple of the step", piece.Rotation)
+		}
+		if piece.Rotation != 0 {
+			rotated = true
+		}
+	}
+	if !rotated {
+		t.Errorf("Expected wedges to be rotated against each other")
+	}
+
+	req := &models.OptimizationRequest{Name: "Bad step", SheetID: 1, Algorithm: "nfp",
+		Designs: []models.DesignItem{{DesignID: wedge.ID, Quantity: 1}},
+		Options: models.OptimizeOptions{RotationStep: 400}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Rotation step of 400: got %v, want validation error", err)
+	}
+}
@@ -1,11 +1,13 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"math"
 	"math/rand"
 	"sort"
+	"strings"
 	"time"
 
 	"glass-optimizer/internal/models"
@@ -26,8 +28,37 @@ func NewOptimizerService(storage storage.Storage, logger *slog.Logger) *Optimize
 	}
 }
 
-// RunOptimization executes the optimization algorithm and returns results
-func (s *OptimizerService) RunOptimization(req *models.OptimizationRequest, userID int64) (*models.Optimization, error) {
+// RunOptimization executes the optimization algorithm and returns results.
+// When ctx is cancelled or the time limit runs out, the best layout found so
+// far is saved and returned with Layout.Partial set. Every piece must be the
+// same glass as the sheet; RunOptimizationSet handles mixed requests.
+func (s *OptimizerService) RunOptimization(ctx context.Context, req *models.OptimizationRequest, userID int64) (*models.Optimization, error) {
+	return s.runOptimization(ctx, req, userID, "")
+}
+
+func (s *OptimizerService) runOptimization(ctx context.Context, req *models.OptimizationRequest, userID int64, batchID string) (*models.Optimization, error) {
+	run, err := s.prepareOptimization(req, userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	return s.executeOptimization(ctx, run)
+}
+
+// preparedOptimization is a checked request ready to run: the optimization to
+// fill in, the options it runs with, the glass it may cut from and the pieces
+// to place
+type preparedOptimization struct {
+	optimization *models.Optimization
+	algorithm    string
+	options      models.OptimizeOptions
+	stock        *sheetStock
+	pieces       []PieceToPlace
+	startTime    time.Time
+}
+
+// prepareOptimization checks a request and loads everything it needs to run,
+// so that a request that cannot run is rejected before anything is stored
+func (s *OptimizerService) prepareOptimization(req *models.OptimizationRequest, userID int64, batchID string) (*preparedOptimization, error) {
 	if userID == 0 {
 		return nil, models.NewValidationError("user ID is required")
 	}
@@ -40,7 +71,7 @@ func (s *OptimizerService) RunOptimization(req *models.OptimizationRequest, user
 	}
 
 	// Get glass sheet information
-	sheet, err := s.storage.GetGlassSheet(req.SheetID)
+	sheet, err := s.storage.GetGlassSheet(requestSheetID(req))
 	if err != nil {
 		return nil, err
 	}
@@ -54,47 +85,111 @@ func (s *OptimizerService) RunOptimization(req *models.OptimizationRequest, user
 
 	// Create optimization instance
 	optimization := &models.Optimization{
-		Name:      req.Name,
-		SheetID:   req.SheetID,
-		Sheet:     sheet,
-		Algorithm: req.Algorithm,
-		UserID:    userID,
+		Name:           req.Name,
+		SheetID:        sheet.ID,
+		SheetIDs:       req.SheetIDs,
+		MatchingSheets: req.MatchingSheets,
+		Sheet:          sheet,
+		Algorithm:      req.Algorithm,
+		UserID:         userID,
+		BatchID:        batchID,
 	}
 
 	// Set design list
 	optimization.DesignList = make([]models.DesignItem, len(req.Designs))
 	for i, designReq := range req.Designs {
+		design := s.resolveDesign(designReq, designs, sheet, userID)
 		optimization.DesignList[i] = models.DesignItem{
-			DesignID: designReq.DesignID,
-			Design:   designs[designReq.DesignID],
-			Quantity: designReq.Quantity,
-			Priority: designReq.Priority,
+			DesignID:  designReq.DesignID,
+			Design:    design,
+			Quantity:  designReq.Quantity,
+			Priority:  designReq.Priority,
+			DueDate:   designReq.DueDate,
+			Order:     designReq.Order,
+			Material:  itemGlass(designReq, design, sheet).material,
+			Width:     designReq.Width,
+			Height:    designReq.Height,
+			Thickness: design.Thickness,
+			Name:      designReq.Name,
 		}
 	}
 
+	if err := checkSheetGlass(sheet, optimization.DesignList); err != nil {
+		return nil, err
+	}
+
 	// Apply default options if not provided
 	options := req.Options
-	if options.MinimumGap == 0 {
-		options.MinimumGap = 2.0 // 2mm default gap
+	if options.Seed == 0 {
+		options.Seed = time.Now().UnixNano() // Stored below so the run can be reproduced
 	}
-	if options.EdgeMargin == 0 {
-		options.EdgeMargin = 5.0 // 5mm default margin
+	optimization.Seed = options.Seed
+	applyDefaultOptions(&options)
+
+	stock, err := s.loadSheetStock(sheet, req, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := s.createPieceList(optimization.DesignList, stock.sheet, &options)
+	if options.EnableNesting {
+		pieces = s.nestInHoles(pieces, &options)
+	}
+	if req.Algorithm == "exact" {
+		if err := checkExact(stock, pieces); err != nil {
+			return nil, err
+		}
+	}
+
+	return &preparedOptimization{
+		optimization: optimization,
+		algorithm:    req.Algorithm,
+		options:      options,
+		stock:        stock,
+		pieces:       pieces,
+		startTime:    startTime,
+	}, nil
+}
+
+// executeOptimization runs a prepared optimization and saves it with its
+// pending remnants
+func (s *OptimizerService) executeOptimization(ctx context.Context, run *preparedOptimization) (*models.Optimization, error) {
+	optimization, options := run.optimization, run.options
+
+	if options.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(options.TimeLimit)*time.Second)
+		defer cancel()
 	}
 
 	// Run optimization algorithm
-	layout, err := s.runOptimizationAlgorithm(req.Algorithm, sheet, designs, req.Designs, &options)
+	layout, err := s.runOptimizationAlgorithm(ctx, run.algorithm, run.stock, run.pieces, &options)
 	if err != nil {
 		return nil, err
 	}
 
+	if ctx.Err() != nil {
+		layout.Partial = true
+		s.logger.Warn("Optimization stopped early, keeping best layout found",
+			"name", optimization.Name, "reason", ctx.Err())
+	}
+
+	layout.Violations = ValidateLayout(layout, &options)
+	if len(layout.Violations) > 0 {
+		s.logger.Error("Optimization produced an invalid layout", "name", optimization.Name, "algorithm", run.algorithm,
+			"violations", len(layout.Violations), "first", layout.Violations[0].Message)
+	}
+
 	// Set results
 	optimization.Layout = *layout
-	optimization.UsedArea = s.calculateUsedArea(layout.Pieces, designs)
-	optimization.TotalArea = sheet.Area()
-	optimization.ExecutionTime = time.Since(startTime).Seconds()
+	optimization.UsedArea = s.calculateUsedArea(layout.AllPieces())
+	optimization.ExecutionTime = time.Since(run.startTime).Seconds()
 
 	// Calculate statistics
 	optimization.CalculateStatistics()
+	s.findLayoutOffcuts(&optimization.Layout, &options)
+	optimization.Layout.Statistics.SmallestGap = smallestGap(&optimization.Layout)
+	optimization.Options = &options
 
 	// Save optimization
 	if err := s.storage.CreateOptimization(optimization); err != nil {
@@ -102,9 +197,12 @@ func (s *OptimizerService) RunOptimization(req *models.OptimizationRequest, user
 		return nil, err
 	}
 
+	s.recordRemnants(optimization)
+
 	s.logger.Info("Optimization completed successfully",
 		"id", optimization.ID,
 		"utilization", fmt.Sprintf("%.2f%%", optimization.Layout.Statistics.UtilizationRate),
+		"sheets", optimization.Layout.Statistics.SheetsUsed,
 		"execution_time", fmt.Sprintf("%.3fs", optimization.ExecutionTime))
 
 	return optimization, nil
@@ -115,7 +213,64 @@ func (s *OptimizerService) GetOptimization(id int, userID int64) (*models.Optimi
 	if userID == 0 {
 		return nil, models.NewValidationError("user ID is required")
 	}
-	return s.storage.GetOptimization(id, userID)
+
+	optimization, err := s.storage.GetOptimization(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachSheet(optimization); err != nil {
+		return nil, err
+	}
+
+	return optimization, nil
+}
+
+// RerunRequest returns the request that produced a saved optimization: the
+// same designs, candidate sheets and algorithm, with the options and seed it
+// was run with, so running it again reproduces the layout. Layouts saved
+// before options were stored rerun with the defaults.
+func (s *OptimizerService) RerunRequest(optimization *models.Optimization) *models.OptimizationRequest {
+	req := &models.OptimizationRequest{
+		Name:           optimization.Name + " (Rerun)",
+		SheetID:        optimization.SheetID,
+		SheetIDs:       optimization.SheetIDs,
+		MatchingSheets: optimization.MatchingSheets,
+		Designs:        optimization.DesignList,
+		Algorithm:      optimization.Algorithm,
+	}
+	if optimization.Options != nil {
+		req.Options = *optimization.Options
+	}
+	req.Options.Seed = optimization.Seed
+
+	return req
+}
+
+// ValidateOptimization checks a saved layout against the options it was run
+// with, or against the given options when there are any. Defaults of a run
+// fill in options the layout does not record, as for layouts saved before
+// options were stored.
+func (s *OptimizerService) ValidateOptimization(id int, userID int64, options *models.OptimizeOptions) (*models.LayoutValidation, error) {
+	optimization, err := s.GetOptimization(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := models.OptimizeOptions{}
+	switch {
+	case options != nil:
+		effective = *options
+	case optimization.Options != nil:
+		effective = *optimization.Options
+	}
+	applyDefaultOptions(&effective)
+	violations := ValidateLayout(&optimization.Layout, &effective)
+	if violations == nil {
+		violations = []models.Violation{}
+	}
+
+	return &models.LayoutValidation{Valid: len(violations) == 0, Violations: violations}, nil
 }
 
 // GetOptimizations retrieves optimizations with pagination
@@ -143,7 +298,7 @@ func (s *OptimizerService) ExportOptimization(id int, userID int64, format strin
 		return nil, models.NewValidationError("user ID is required")
 	}
 
-	optimization, err := s.storage.GetOptimization(id, userID)
+	optimization, err := s.GetOptimization(id, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -164,21 +319,110 @@ func (s *OptimizerService) ExportOptimization(id int, userID int64, format strin
 
 // Private methods
 
+// applyDefaultOptions fills in the spacing and remnant options left unset
+func applyDefaultOptions(options *models.OptimizeOptions) {
+	if options.MinimumGap == 0 {
+		options.MinimumGap = 2.0 // 2mm default gap
+	}
+	if options.EdgeMargin == 0 {
+		options.EdgeMargin = 5.0 // 5mm default margin
+	}
+	if options.MinRemnantSize == 0 {
+		options.MinRemnantSize = defaultMinRemnantSize
+	}
+}
+
 func (s *OptimizerService) validateOptimizationRequest(req *models.OptimizationRequest) error {
 	if req.Name == "" {
 		return models.NewValidationError("name is required")
 	}
-	if req.SheetID <= 0 {
+	if req.SheetID < 0 || (req.SheetID == 0 && len(req.SheetIDs) == 0) {
 		return models.NewValidationError("sheet_id must be positive")
 	}
+	for _, id := range req.SheetIDs {
+		if id <= 0 {
+			return models.NewValidationError("sheet_ids must be positive")
+		}
+	}
 	if len(req.Designs) == 0 {
 		return models.NewValidationError("at least one design is required")
 	}
 
+	errors := &models.ValidationErrors{}
+
 	// Validate algorithm
-	validAlgorithms := []string{"blf", "genetic", "greedy", "custom"}
-	models.ValidateEnum(req.Algorithm, validAlgorithms, "algorithm", &models.ValidationErrors{})
+	validAlgorithms := []string{
+		"blf", "genetic", "greedy", "guillotine",
+		"maxrects-bssf", "maxrects-blsf", "maxrects-baf", "maxrects-cp", "skyline", "nfp", "exact", "auto",
+	}
+	models.ValidateEnum(req.Algorithm, validAlgorithms, "algorithm", errors)
 
+	if req.Options.MaxCutStages != 0 {
+		models.ValidateRange(float64(req.Options.MaxCutStages), 1, maxGuillotineStages, "max_cut_stages", errors)
+	}
+	if !validSortBy(req.Options.SortBy) {
+		errors.Add("sort_by", "sort_by must be all or sort keys separated by commas: area, perimeter, ratio, max_side, width, height or priority")
+	}
+	if req.Options.SortOrder != "" {
+		models.ValidateEnum(req.Options.SortOrder, []string{"asc", "desc"}, "sort_order", errors)
+	}
+	if req.Options.RotationStep != 0 {
+		models.ValidateRange(float64(req.Options.RotationStep), 1, 360, "rotation_step", errors)
+	}
+	for _, item := range req.Designs {
+		if item.Thickness < 0 {
+			errors.Add("thickness", "thickness cannot be negative")
+			break
+		}
+	}
+	for _, item := range req.Designs {
+		if item.Grain != "" && item.Grain != models.DirectionVertical && item.Grain != models.DirectionHorizontal {
+			errors.Add("grain", "grain must be vertical or horizontal")
+			break
+		}
+	}
+	for _, item := range req.Designs {
+		if _, err := time.Parse(dueDateFormat, item.DueDate); item.DueDate != "" && err != nil {
+			errors.Add("due_date", "due_date must be a date as YYYY-MM-DD")
+			break
+		}
+	}
+	if req.Options.MinRemnantSize < 0 {
+		errors.Add("min_remnant_size", "min_remnant_size cannot be negative")
+	}
+	if req.Options.Kerf < 0 {
+		errors.Add("kerf", "kerf cannot be negative")
+	}
+	if trim := req.Options.Trim; trim.Left < 0 || trim.Right < 0 || trim.Top < 0 || trim.Bottom < 0 {
+		errors.Add("trim", "trim cannot be negative")
+	}
+	if req.Options.GrindingAllowance < 0 {
+		errors.Add("grinding_allowance", "grinding_allowance cannot be negative")
+	}
+	if req.Options.EdgeDeletion < 0 {
+		errors.Add("edge_deletion", "edge_deletion cannot be negative")
+	}
+	if req.Options.ImproveIterations < 0 {
+		errors.Add("improve_iterations", "improve_iterations cannot be negative")
+	}
+	if req.Options.ImproveTimeLimit < 0 {
+		errors.Add("improve_time_limit", "improve_time_limit cannot be negative")
+	}
+
+	if errors.HasErrors() {
+		return errors
+	}
+
+	return nil
+}
+
+func (s *OptimizerService) attachSheet(optimization *models.Optimization) error {
+	sheet, err := s.storage.GetGlassSheet(optimization.SheetID)
+	if err != nil {
+		return err
+	}
+	optimization.Sheet = sheet
+	optimization.TotalCost = optimization.Layout.MaterialCost(sheet)
 	return nil
 }
 
@@ -186,98 +430,443 @@ func (s *OptimizerService) loadDesignsForOptimization(designRequests []models.De
 	designs := make(map[int]*models.Design)
 
 	for _, req := range designRequests {
+		// Custom pieces (DesignID 0) carry their own dimensions
+		if req.DesignID == 0 {
+			continue
+		}
 		if _, exists := designs[req.DesignID]; !exists {
-			if req.DesignID == 0 {
-				// Handle custom pieces - create a temporary design
-				design := &models.Design{
-					ID:        0,
-					Name:      req.Name,
-					Width:     req.Width,
-					Height:    req.Height,
-					Thickness: 6.0, // Default thickness for custom pieces
-					UserID:    userID,
-				}
-				designs[req.DesignID] = design
-			} else {
-				// Load existing design from database
-				design, err := s.storage.GetDesign(req.DesignID, userID)
-				if err != nil {
-					return nil, fmt.Errorf("failed to load design %d: %w", req.DesignID, err)
-				}
-				designs[req.DesignID] = design
+			// Load existing design from database
+			design, err := s.storage.GetDesign(req.DesignID, userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load design %d: %w", req.DesignID, err)
 			}
+			designs[req.DesignID] = design
 		}
 	}
 
 	return designs, nil
 }
 
-func (s *OptimizerService) runOptimizationAlgorithm(algorithm string, sheet *models.GlassSheet, designs map[int]*models.Design, designRequests []models.DesignItem, options *models.OptimizeOptions) (*models.Layout, error) {
+// resolveDesign returns the stored design for a request item, or a temporary
+// design built from the item's own dimensions for custom pieces. Custom
+// pieces without a thickness are as thick as the sheet.
+func (s *OptimizerService) resolveDesign(req models.DesignItem, designs map[int]*models.Design, sheet *models.GlassSheet, userID int64) *models.Design {
+	if design, ok := designs[req.DesignID]; ok {
+		return design
+	}
+
+	thickness := req.Thickness
+	if thickness == 0 {
+		thickness = sheet.Thickness
+	}
+
+	return &models.Design{
+		ID:        0,
+		Name:      req.Name,
+		Width:     req.Width,
+		Height:    req.Height,
+		Thickness: thickness,
+		Grain:     req.Grain,
+		UserID:    userID,
+	}
+}
+
+func (s *OptimizerService) runOptimizationAlgorithm(ctx context.Context, algorithm string, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	var layout *models.Layout
+	var err error
+	if algorithm == "auto" {
+		layout, err = s.runPortfolio(ctx, stock, pieces, options)
+	} else {
+		layout, err = s.runAlgorithm(ctx, algorithm, stock, pieces, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.boundSheets(layout, stock, pieces, options)
+	return layout, nil
+}
+
+// boundSheets records the fewest sheets the pieces fit on in the layout
+// statistics, unless the algorithm proved a higher bound. The bound is only
+// known for layouts placing every piece on full sheets of a single size, and
+// not for shapes nested by their true outline.
+func (s *OptimizerService) boundSheets(layout *models.Layout, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) {
+	if len(stock.sheets) != 1 || len(layout.AllPieces()) != countPieces(pieces) {
+		return
+	}
+	for _, sheet := range layout.Sheets {
+		if sheet.RemnantID != 0 {
+			return
+		}
+	}
+	for _, piece := range pieces {
+		if piece.Outline != nil {
+			return
+		}
+	}
+
+	if bound := s.sheetLowerBound(stock.sheets[0].sheet, pieces, options); bound > layout.Statistics.SheetLowerBound {
+		layout.Statistics.SheetLowerBound = bound
+	}
+}
+
+// runAlgorithm lays out the pieces with a single algorithm
+func (s *OptimizerService) runAlgorithm(ctx context.Context, algorithm string, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
 	switch algorithm {
 	case "blf":
-		return s.runBottomLeftFill(sheet, designs, designRequests, options)
+		return s.runBottomLeftFill(ctx, stock, pieces, options)
 	case "genetic":
-		return s.runGeneticAlgorithm(sheet, designs, designRequests, options)
+		return s.runGeneticAlgorithm(ctx, stock, pieces, options)
 	case "greedy":
-		return s.runGreedyAlgorithm(sheet, designs, designRequests, options)
+		return s.runGreedyAlgorithm(ctx, stock, pieces, options)
+	case "guillotine":
+		return s.runGuillotine(ctx, stock, pieces, options)
+	case "maxrects-bssf", "maxrects-blsf", "maxrects-baf", "maxrects-cp":
+		return s.runMaxRects(ctx, stock, pieces, options, maxRectsAlgorithms[algorithm])
+	case "skyline":
+		return s.runSkyline(ctx, stock, pieces, options)
+	case "nfp":
+		return s.runNesting(ctx, stock, pieces, options)
+	case "exact":
+		return s.runExact(ctx, stock, pieces, options)
 	default:
 		return nil, models.NewValidationError("unsupported algorithm: " + algorithm)
 	}
 }
 
-// Bottom-Left Fill Algorithm
-func (s *OptimizerService) runBottomLeftFill(sheet *models.GlassSheet, designs map[int]*models.Design, designRequests []models.DesignItem, options *models.OptimizeOptions) (*models.Layout, error) {
-	s.logger.Debug("Running Bottom-Left Fill algorithm")
+// ProgressFunc receives progress updates from a running optimization
+type ProgressFunc func(progress models.JobProgress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that delivers the progress of optimizations
+// run with it to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress passes progress to the ProgressFunc attached to ctx, if any
+func reportProgress(ctx context.Context, progress models.JobProgress) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(progress)
+	}
+}
+
+// sheetProgress describes the sheets filled so far by a single-pass packer
+func (s *OptimizerService) sheetProgress(filled []filledSheet) models.JobProgress {
+	progress := models.JobProgress{SheetsUsed: len(filled)}
+
+	usedArea, sheetArea := 0.0, 0.0
+	for _, sheet := range filled {
+		progress.PiecesPlaced += len(sheet.pieces)
+		usedArea += s.calculateUsedArea(sheet.pieces)
+		sheetArea += sheet.sheet.Area()
+	}
+	if sheetArea > 0 {
+		progress.BestUtilization = usedArea / sheetArea * 100
+	}
+
+	return progress
+}
+
+// sheetStock is the glass a run may cut from: remnants of the same glass,
+// each used at most once and tried first, then full sheets of one or more
+// sizes
+type sheetStock struct {
+	sheet    *models.GlassSheet // Sheet of the request
+	sheets   []stockSheet
+	remnants []models.Remnant
+}
+
+// stockSheet is a full sheet size a run may open up to limit times; a limit
+// of 0 leaves the count open
+type stockSheet struct {
+	sheet *models.GlassSheet
+	limit int
+}
 
-	layout := &models.Layout{
+// filledSheet is a sheet or remnant opened by a run with the pieces placed on it
+type filledSheet struct {
+	sheet     *models.GlassSheet // Remnants are represented by a copy of their source sheet resized to the remnant
+	remnantID int
+	instance  int // Stock sheet of its size, set when defects mark single sheets of the size
+	pieces    []models.PlacedPiece
+	placed    []PieceToPlace  // Pieces the placements were made for, in the order they were packed
+	cutTree   *models.CutNode // Set by guillotine packing
+}
+
+// sheetPacker places as many pieces as it can on one empty sheet and returns
+// the placements together with the pieces that did not fit. Packers stop
+// placing when ctx is done and return everything left as unplaced.
+type sheetPacker func(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace)
+
+// sheetFiller is a sheetPacker that also knows the number the sheet will
+// have in the layout and may cut it along a cut tree
+type sheetFiller func(ctx context.Context, sheet *models.GlassSheet, sheetNumber int, pieces []PieceToPlace, options *models.OptimizeOptions) (filledSheet, []PieceToPlace)
+
+// filler adapts a packer to fillSheets; pieces cut from hole drop-outs are
+// placed along with the pieces around them
+func (pack sheetPacker) filler() sheetFiller {
+	return func(ctx context.Context, sheet *models.GlassSheet, sheetNumber int, pieces []PieceToPlace, options *models.OptimizeOptions) (filledSheet, []PieceToPlace) {
+		placed, unplaced := pack(ctx, sheet, pieces, options)
+		return filledSheet{
+			sheet:  sheet,
+			pieces: placeInnerPieces(sheetNumber, pieces, placed, unplaced),
+			placed: placedPieces(pieces, unplaced),
+		}, unplaced
+	}
+}
+
+// packSheets opens new sheets with the given packer until every piece is
+// placed, the remaining pieces cannot fit on an empty sheet or ctx is done
+func (s *OptimizerService) packSheets(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions, pack sheetPacker) *models.Layout {
+	fill := pack.filler()
+	filled, unplaced := s.fillSheets(ctx, stock, pieces, options, fill)
+	return s.finishLayout(ctx, stock, filled, unplaced, options, fill)
+}
+
+// finishLayout builds the layout of the filled sheets, first improving them
+// with the same filler when options.improve is set
+func (s *OptimizerService) finishLayout(ctx context.Context, stock *sheetStock, filled []filledSheet, unplaced []PieceToPlace, options *models.OptimizeOptions, fill sheetFiller) *models.Layout {
+	if options.Improve && len(filled) > 0 {
+		filled = s.improveSheets(ctx, stock, filled, options, fill)
+	}
+	return s.buildLayout(stock, filled, unplaced, options)
+}
+
+// buildLayout lays out the filled sheets in order, marks the orders each
+// sheet completes and logs the pieces that could not be placed
+func (s *OptimizerService) buildLayout(stock *sheetStock, filled []filledSheet, unplaced []PieceToPlace, options *models.OptimizeOptions) *models.Layout {
+	for _, piece := range unplaced {
+		s.logger.Warn("Could not place piece", "design_id", piece.DesignID, "name", piece.Name)
+	}
+
+	layout := newLayout(stock.sheet)
+	for _, sheet := range filled {
+		s.addSheet(layout, sheet, options)
+	}
+	markCompletedOrders(layout, unplaced)
+
+	return layout
+}
+
+// fillSheets fills the remnants in stock and then full sheets, and returns
+// the filled sheets together with the pieces that fit on none of them. A
+// remnant nothing fits on is skipped. With several sheet sizes in stock, the
+// cheapest of the plans built by fillFullSheets is kept.
+func (s *OptimizerService) fillSheets(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions, fill sheetFiller) ([]filledSheet, []PieceToPlace) {
+	var filled []filledSheet
+
+	remaining := pieces
+	for i := range stock.remnants {
+		if len(remaining) == 0 || ctx.Err() != nil {
+			break
+		}
+
+		remnant := &stock.remnants[i]
+		sheet, unplaced := fill(ctx, remnantSheet(stock.sheet, remnant), len(filled)+1, remaining, options)
+		if len(sheet.pieces) > 0 {
+			sheet.remnantID = remnant.ID
+			filled = append(filled, sheet)
+			remaining = unplaced
+			reportProgress(ctx, s.sheetProgress(filled))
+		}
+	}
+
+	if len(stock.sheets) == 1 {
+		return s.fillFullSheets(ctx, stock.sheets, filled, remaining, options, fill, true)
+	}
+
+	// Choosing the cheapest size sheet by sheet can strand the last pieces on
+	// an expensive sheet, so a single size is also tried on its own
+	bestFilled, bestUnplaced := s.fillFullSheets(ctx, stock.sheets, filled, remaining, options, fill, false)
+	for i := range stock.sheets {
+		planFilled, planUnplaced := s.fillFullSheets(ctx, stock.sheets[i:i+1], filled, remaining, options, fill, false)
+		if len(planUnplaced) < len(bestUnplaced) ||
+			(len(planUnplaced) == len(bestUnplaced) && sheetsCost(planFilled) < sheetsCost(bestFilled)) {
+			bestFilled, bestUnplaced = planFilled, planUnplaced
+		}
+	}
+	reportProgress(ctx, s.sheetProgress(bestFilled))
+
+	return bestFilled, bestUnplaced
+}
+
+// fillFullSheets opens sheets of the given sizes after the sheets already
+// filled until every piece is placed, no size with stock left takes any of
+// the remaining pieces or ctx is done
+func (s *OptimizerService) fillFullSheets(ctx context.Context, sizes []stockSheet, filled []filledSheet, pieces []PieceToPlace, options *models.OptimizeOptions, fill sheetFiller, report bool) ([]filledSheet, []PieceToPlace) {
+	filled = filled[:len(filled):len(filled)] // Plans must not share the sheets they add
+	opened := make([]int, len(sizes))
+
+	remaining := pieces
+	for len(remaining) > 0 && ctx.Err() == nil {
+		index, sheet, unplaced := s.fillCheapestSheet(ctx, sizes, opened, len(filled)+1, remaining, options, fill)
+		if index < 0 {
+			break
+		}
+
+		opened[index]++
+		filled = append(filled, sheet)
+		remaining = unplaced
+		if report {
+			reportProgress(ctx, s.sheetProgress(filled))
+		}
+	}
+
+	return filled, remaining
+}
+
+// fillCheapestSheet fills every sheet size with stock left and keeps the one
+// with the lowest cost per area of glass placed. That favours the cheaper
+// glass per square meter while most pieces remain, and the smallest sheet
+// that takes what is left at the end. It returns an index of -1 when no size
+// with stock left takes any piece.
+func (s *OptimizerService) fillCheapestSheet(ctx context.Context, sizes []stockSheet, opened []int, sheetNumber int, pieces []PieceToPlace, options *models.OptimizeOptions, fill sheetFiller) (int, filledSheet, []PieceToPlace) {
+	best := -1
+	var bestSheet filledSheet
+	var bestUnplaced []PieceToPlace
+	bestCost, bestArea := 0.0, 0.0
+
+	for i, size := range sizes {
+		if size.limit > 0 && opened[i] >= size.limit {
+			continue
+		}
+
+		sheet, unplaced := fill(ctx, sheetInstance(size.sheet, opened[i]+1), sheetNumber, pieces, options)
+		if len(sheet.pieces) == 0 {
+			continue
+		}
+		if hasInstanceDefects(size.sheet) {
+			sheet.instance = opened[i] + 1
+		}
+
+		area := s.calculateUsedArea(sheet.pieces)
+		cost := size.sheet.TotalCost() / area
+		if best < 0 || cost < bestCost || (cost == bestCost && area > bestArea) {
+			best, bestSheet, bestUnplaced = i, sheet, unplaced
+			bestCost, bestArea = cost, area
+		}
+	}
+
+	return best, bestSheet, bestUnplaced
+}
+
+// sheetsCost returns the material cost of the filled sheets; remnants are free
+func sheetsCost(filled []filledSheet) float64 {
+	cost := 0.0
+	for _, sheet := range filled {
+		if sheet.remnantID == 0 {
+			cost += sheet.sheet.TotalCost()
+		}
+	}
+	return cost
+}
+
+// newLayout creates an empty layout for the given sheet
+func newLayout(sheet *models.GlassSheet) *models.Layout {
+	return &models.Layout{
 		SheetWidth:  sheet.Width,
 		SheetHeight: sheet.Height,
 		Pieces:      []models.PlacedPiece{},
 		CutPaths:    []models.CutPath{},
+		Sheets:      []models.SheetLayout{},
 	}
+}
 
-	// Create list of pieces to place
-	pieces := s.createPieceList(designs, designRequests)
+// addSheet appends a filled sheet to the layout and mirrors the first sheet
+// into Layout.Pieces and Layout.CutPaths for single-sheet consumers. The cut
+// paths follow the guillotine cut tree when one is given.
+func (s *OptimizerService) addSheet(layout *models.Layout, filled filledSheet, options *models.OptimizeOptions) {
+	sheet, pieces := filled.sheet, filled.pieces
+	sheetNumber := len(layout.Sheets) + 1
+	for i := range pieces {
+		pieces[i].Sheet = sheetNumber
+		pieces[i].Pattern = patternIn(sheet.Specs.Pattern, pieces[i].Rotation)
+		if pieces[i].ID == "" {
+			pieces[i].ID = placementID(sheetNumber, i+1)
+		}
+	}
 
-	// Sort pieces by area (largest first) or by specified criteria
-	s.sortPieces(pieces, options.SortBy, options.SortOrder)
+	usedArea := s.calculateUsedArea(pieces)
+	sheetLayout := models.SheetLayout{
+		SheetNumber:     sheetNumber,
+		SheetID:         sheet.ID,
+		Width:           sheet.Width,
+		Height:          sheet.Height,
+		Pieces:          pieces,
+		UsedArea:        usedArea,
+		UtilizationRate: usedArea / sheet.Area() * 100,
+		CutTree:         filled.cutTree,
+		RemnantID:       filled.remnantID,
+		Instance:        filled.instance,
+		Defects:         sheet.Defects,
+	}
+	if filled.remnantID == 0 {
+		sheetLayout.Cost = sheet.TotalCost()
+	}
+	sheetLayout.CutPaths = s.planCuts(sheet.Width, sheet.Height, pieces, filled.cutTree, options)
+	layout.Sheets = append(layout.Sheets, sheetLayout)
+
+	if sheetNumber == 1 {
+		layout.Pieces = sheetLayout.Pieces
+		layout.CutPaths = sheetLayout.CutPaths
+	}
+
+	s.logger.Debug("Sheet completed", "sheet", sheetNumber, "pieces", len(pieces),
+		"utilization", fmt.Sprintf("%.2f%%", sheetLayout.UtilizationRate))
+}
+
+// placementID names a placed piece by its sheet and position in the placement
+// order, so that repeating a run reproduces its IDs
+func placementID(sheetNumber, index int) string {
+	return fmt.Sprintf("s%d-p%d", sheetNumber, index)
+}
+
+// Bottom-Left Fill Algorithm
+func (s *OptimizerService) runBottomLeftFill(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	s.logger.Debug("Running Bottom-Left Fill algorithm")
+
+	return s.packOrdered(ctx, stock, pieces, options, s.placeBottomLeftFill), nil
+}
+
+// placeBottomLeftFill fills a single sheet using the bottom-left heuristic
+func (s *OptimizerService) placeBottomLeftFill(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace) {
+	var placedPieces []models.PlacedPiece
+	var unplaced []PieceToPlace
 
 	// Available space tracking
-	availableSpaces := []Rectangle{{X: options.EdgeMargin, Y: options.EdgeMargin,
-		Width: sheet.Width - 2*options.EdgeMargin, Height: sheet.Height - 2*options.EdgeMargin}}
+	availableSpaces := []Rectangle{usableArea(sheet, options)}
+	spacing := pieceSpacing(options)
+	for _, defect := range defectAreas(sheet.Defects, spacing) {
+		availableSpaces = s.updateAvailableSpaces(availableSpaces, defect)
+	}
+
+	for i, piece := range pieces {
+		if ctx.Err() != nil {
+			unplaced = append(unplaced, pieces[i:]...)
+			break
+		}
 
-	for _, piece := range pieces {
-		design := designs[piece.DesignID]
 		placed := false
 
 		// Try different orientations if rotation is allowed
-		orientations := s.getOrientations(design, options.AllowRotation)
+		orientations := s.getOrientations(piece, options.AllowRotation)
 
 		for _, orientation := range orientations {
 			pieceWidth, pieceHeight := orientation.Width, orientation.Height
 
 			// Find best position using bottom-left heuristic
-			bestPos := s.findBottomLeftPosition(availableSpaces, pieceWidth, pieceHeight, options.MinimumGap)
+			bestPos := s.findBottomLeftPosition(availableSpaces, pieceWidth, pieceHeight, spacing)
 
 			if bestPos != nil {
 				// Place the piece
-				placedPiece := models.PlacedPiece{
-					ID:         models.GenerateID(),
-					DesignID:   piece.DesignID,
-					DesignName: design.Name,
-					X:          bestPos.X,
-					Y:          bestPos.Y,
-					Width:      pieceWidth,
-					Height:     pieceHeight,
-					Rotation:   orientation.Rotation,
-				}
-
-				layout.Pieces = append(layout.Pieces, placedPiece)
+				placedPiece := placePiece(piece, bestPos.X, bestPos.Y, orientation)
+				placedPieces = append(placedPieces, placedPiece)
 
-				// Update available spaces
-				availableSpaces = s.updateAvailableSpaces(availableSpaces, Rectangle{
-					X: bestPos.X, Y: bestPos.Y, Width: pieceWidth, Height: pieceHeight,
-				})
+				// Update available spaces, keeping the spacing clear around the piece
+				availableSpaces = s.updateAvailableSpaces(availableSpaces, inflateRectangle(placedPiece, spacing))
 
 				placed = true
 				break
@@ -285,162 +874,179 @@ func (s *OptimizerService) runBottomLeftFill(sheet *models.GlassSheet, designs m
 		}
 
 		if !placed {
-			s.logger.Warn("Could not place piece", "design_id", piece.DesignID, "name", design.Name)
+			unplaced = append(unplaced, piece)
 		}
 	}
 
-	// Generate cut paths
-	layout.CutPaths = s.generateCutPaths(layout.Pieces)
-
-	return layout, nil
+	return placedPieces, unplaced
 }
 
 // Greedy Algorithm (simpler, faster)
-func (s *OptimizerService) runGreedyAlgorithm(sheet *models.GlassSheet, designs map[int]*models.Design, designRequests []models.DesignItem, options *models.OptimizeOptions) (*models.Layout, error) {
+func (s *OptimizerService) runGreedyAlgorithm(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
 	s.logger.Debug("Running Greedy algorithm")
 
-	layout := &models.Layout{
-		SheetWidth:  sheet.Width,
-		SheetHeight: sheet.Height,
-		Pieces:      []models.PlacedPiece{},
-		CutPaths:    []models.CutPath{},
-	}
+	return s.packOrdered(ctx, stock, pieces, options, s.placeGreedyRows), nil
+}
 
-	pieces := s.createPieceList(designs, designRequests)
-	s.sortPieces(pieces, "area", "desc") // Always sort by area for greedy
+// placeGreedyRows fills a single sheet row by row in the given piece order
+func (s *OptimizerService) placeGreedyRows(ctx context.Context, sheet *models.GlassSheet, pieces []PieceToPlace, options *models.OptimizeOptions) ([]models.PlacedPiece, []PieceToPlace) {
+	var placedPieces []models.PlacedPiece
+	var unplaced []PieceToPlace
+
+	area := usableArea(sheet, options)
+	spacing := pieceSpacing(options)
+	defects := defectAreas(sheet.Defects, spacing)
+	currentX, currentY := area.X, area.Y
+	rowTop := currentY // Where the next row starts, past the pieces and defects of this one
+
+	for i, piece := range pieces {
+		if ctx.Err() != nil {
+			unplaced = append(unplaced, pieces[i:]...)
+			break
+		}
 
-	currentX, currentY := options.EdgeMargin, options.EdgeMargin
-	rowHeight := 0.0
+		orientation := s.getOrientations(piece, false)[0]
+		pieceWidth, pieceHeight := orientation.Width, orientation.Height
+		placed := false
 
-	for _, piece := range pieces {
-		design := designs[piece.DesignID]
-		pieceWidth, pieceHeight := design.Width, design.Height
-
-		// Check if piece fits in current row
-		if currentX+pieceWidth+options.EdgeMargin <= sheet.Width {
-			// Place in current row
-			placedPiece := models.PlacedPiece{
-				ID:         models.GenerateID(),
-				DesignID:   piece.DesignID,
-				DesignName: design.Name,
-				X:          currentX,
-				Y:          currentY,
-				Width:      pieceWidth,
-				Height:     pieceHeight,
-				Rotation:   0,
+		for {
+			// Start a new row when the piece does not fit in the current one
+			if currentX+pieceWidth > area.X+area.Width && rowTop > currentY {
+				currentX, currentY = area.X, rowTop
 			}
 
-			layout.Pieces = append(layout.Pieces, placedPiece)
+			// Check if the piece fits on the sheet at the current position
+			if currentX+pieceWidth > area.X+area.Width || currentY+pieceHeight > area.Y+area.Height {
+				break
+			}
 
-			currentX += pieceWidth + options.MinimumGap
-			if pieceHeight > rowHeight {
-				rowHeight = pieceHeight
+			// Move along the row past a defect in the way
+			position := Rectangle{X: currentX, Y: currentY, Width: pieceWidth, Height: pieceHeight}
+			if defect, blocked := s.firstOverlap(position, defects); blocked {
+				currentX = defect.X + defect.Width
+				rowTop = math.Max(rowTop, defect.Y+defect.Height)
+				continue
 			}
-		} else {
-			// Start new row
-			currentX = options.EdgeMargin
-			currentY += rowHeight + options.MinimumGap
-			rowHeight = 0
-
-			// Check if new row fits
-			if currentY+pieceHeight+options.EdgeMargin <= sheet.Height {
-				placedPiece := models.PlacedPiece{
-					ID:         models.GenerateID(),
-					DesignID:   piece.DesignID,
-					DesignName: design.Name,
-					X:          currentX,
-					Y:          currentY,
-					Width:      pieceWidth,
-					Height:     pieceHeight,
-					Rotation:   0,
-				}
 
-				layout.Pieces = append(layout.Pieces, placedPiece)
+			placedPieces = append(placedPieces, placePiece(piece, currentX, currentY, orientation))
+			currentX += pieceWidth + spacing
+			rowTop = math.Max(rowTop, currentY+pieceHeight+spacing)
+			placed = true
+			break
+		}
 
-				currentX += pieceWidth + options.MinimumGap
-				rowHeight = pieceHeight
-			} else {
-				s.logger.Warn("Could not place piece", "design_id", piece.DesignID, "name", design.Name)
-			}
+		if !placed {
+			unplaced = append(unplaced, piece)
 		}
 	}
 
-	layout.CutPaths = s.generateCutPaths(layout.Pieces)
-	return layout, nil
+	return placedPieces, unplaced
 }
 
 // Genetic Algorithm (for complex optimization)
-func (s *OptimizerService) runGeneticAlgorithm(sheet *models.GlassSheet, designs map[int]*models.Design, designRequests []models.DesignItem, options *models.OptimizeOptions) (*models.Layout, error) {
+//
+// Each individual encodes a piece sequence and a rotation gene per piece. The
+// bottom-left fill placer decodes it into a legal multi-sheet layout, and the
+// fitness is the material utilization of that layout. Evolution stops when ctx
+// is done and the best individual found so far is decoded in full.
+func (s *OptimizerService) runGeneticAlgorithm(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
 	s.logger.Debug("Running Genetic algorithm")
 
-	// Initialize random seed
-	rand.Seed(time.Now().UnixNano())
-
-	pieces := s.createPieceList(designs, designRequests)
-	populationSize := options.PopulationSize
-	if populationSize == 0 {
-		populationSize = 50
+	if len(pieces) == 0 {
+		return newLayout(stock.sheet), nil
 	}
 
-	maxGenerations := options.MaxIterations
-	if maxGenerations == 0 {
-		maxGenerations = 100
-	}
+	rng := rand.New(rand.NewSource(options.Seed))
+	params := newGeneticParams(options)
 
-	// Create initial population
-	population := make([]*GeneticIndividual, populationSize)
-	for i := 0; i < populationSize; i++ {
-		individual := s.createRandomIndividual(pieces, sheet, options)
-		individual.Fitness = s.evaluateFitness(individual, sheet)
-		population[i] = individual
+	// Seed the population with the usual sorting heuristics, fill the rest randomly
+	population := make([]*GeneticIndividual, 0, params.populationSize)
+	for _, order := range s.heuristicOrders(pieces) {
+		if len(population) == params.populationSize {
+			break
+		}
+		population = append(population, &GeneticIndividual{Order: order, Rotations: make([]bool, len(pieces))})
+	}
+	for len(population) < params.populationSize {
+		population = append(population, s.createRandomIndividual(len(pieces), options, rng))
+	}
+	for _, individual := range population {
+		s.evaluateFitness(individual, stock, pieces, options)
 	}
 
-	bestIndividual := population[0]
+	best := s.fittest(population)
+	reportProgress(ctx, best.progress(0))
 
 	// Evolution loop
-	for generation := 0; generation < maxGenerations; generation++ {
-		// Sort by fitness
+	generation := 0
+	for ; generation < params.maxGenerations; generation++ {
+		if best.Utilization >= params.qualityTarget {
+			s.logger.Debug("Genetic algorithm reached quality target", "generation", generation)
+			break
+		}
+		if ctx.Err() != nil {
+			s.logger.Debug("Genetic algorithm stopped", "generation", generation, "reason", ctx.Err())
+			break
+		}
+
 		sort.Slice(population, func(i, j int) bool {
 			return population[i].Fitness > population[j].Fitness
 		})
 
-		// Track best individual
-		if population[0].Fitness > bestIndividual.Fitness {
-			bestIndividual = population[0]
-		}
-
-		// Create new generation
-		newPopulation := make([]*GeneticIndividual, populationSize)
+		newPopulation := make([]*GeneticIndividual, 0, params.populationSize)
 
 		// Keep best individuals (elitism)
-		eliteCount := populationSize / 4
-		for i := 0; i < eliteCount; i++ {
-			newPopulation[i] = s.cloneIndividual(population[i])
+		for i := 0; i < params.eliteCount; i++ {
+			newPopulation = append(newPopulation, s.cloneIndividual(population[i]))
 		}
 
 		// Generate offspring
-		for i := eliteCount; i < populationSize; i++ {
-			parent1 := s.tournamentSelection(population)
-			parent2 := s.tournamentSelection(population)
+		for len(newPopulation) < params.populationSize && ctx.Err() == nil {
+			parent1 := s.tournamentSelection(population, rng)
+			parent2 := s.tournamentSelection(population, rng)
 
-			offspring := s.crossover(parent1, parent2, options)
-			s.mutate(offspring, options)
-			offspring.Fitness = s.evaluateFitness(offspring, sheet)
+			var offspring *GeneticIndividual
+			if rng.Float64() < params.crossoverRate {
+				offspring = s.crossover(parent1, parent2, rng)
+			} else {
+				offspring = s.cloneIndividual(parent1)
+			}
+			s.mutate(offspring, params.mutationRate, options.AllowRotation, rng)
+			s.evaluateFitness(offspring, stock, pieces, options)
 
-			newPopulation[i] = offspring
+			newPopulation = append(newPopulation, offspring)
+		}
+
+		if ctx.Err() != nil {
+			// Discard the unfinished generation
+			continue
 		}
 
 		population = newPopulation
+		if fittest := s.fittest(population); fittest.Fitness > best.Fitness {
+			best = fittest
+		}
+		reportProgress(ctx, best.progress(generation+1))
 
 		if generation%10 == 0 {
 			s.logger.Debug("Genetic algorithm progress",
 				"generation", generation,
-				"best_fitness", fmt.Sprintf("%.2f", bestIndividual.Fitness))
+				"best_fitness", fmt.Sprintf("%.2f", best.Fitness),
+				"sheets", best.Sheets)
 		}
 	}
 
-	// Convert best individual to layout
-	return s.individualToLayout(bestIndividual, sheet, designs), nil
+	s.logger.Debug("Genetic algorithm finished",
+		"generations", generation,
+		"sheets", best.Sheets,
+		"utilization", fmt.Sprintf("%.2f%%", best.Utilization*100))
+
+	// The final decode repeats the best individual, whose progress is already
+	// reported. Only the improvement pass stops with ctx.
+	decodeCtx := WithProgress(context.WithoutCancel(ctx), nil)
+	fill := sheetPacker(s.placeBottomLeftFill).filler()
+	filled, unplaced := s.fillSheets(decodeCtx, stock, s.decodeIndividual(best, pieces), options, fill)
+	return s.finishLayout(ctx, stock, filled, unplaced, options, fill), nil
 }
 
 // Helper types and methods
@@ -451,8 +1057,37 @@ type Rectangle struct {
 
 type PieceToPlace struct {
 	DesignID int
+	Name     string
+	Width    float64
+	Height   float64
 	Quantity int
 	Priority int
+	// DueDate is the date the piece is needed by as YYYY-MM-DD, or empty
+	DueDate string
+	// Order is the customer order the piece belongs to
+	Order string
+	// PreferRotated makes placers try the 90 degree orientation first
+	PreferRotated bool
+	// Grain limits the rotations to those that run the sheet pattern the way
+	// the design asks
+	Grain grainLock
+	// Outline is the true shape of a non-rectangular piece in design
+	// coordinates; only true-shape nesting uses it
+	Outline []models.Point
+	// Tolerance is how far the outline's edges may lie inside the true curve
+	Tolerance float64
+	// Holes are the parts of the piece's hole drop-outs other pieces may be
+	// cut from, in design coordinates
+	Holes []Rectangle
+	// Inner are the pieces cut from the hole drop-outs; they travel with the
+	// piece and are placed with it
+	Inner []innerPiece
+	// Allowance is the grinding allowance added to every edge; Width, Height,
+	// Outline and Holes include it
+	Allowance float64
+	// EdgeDeletion is the low-E coating to remove along every edge
+	EdgeDeletion float64
+	serial       int // Position in the piece list, telling copies of a piece apart
 }
 
 type Orientation struct {
@@ -460,54 +1095,119 @@ type Orientation struct {
 	Rotation      int
 }
 
+// GeneticIndividual is a chromosome of the genetic algorithm: the order in
+// which pieces are handed to the placer and whether each piece is rotated
 type GeneticIndividual struct {
-	Pieces  []GeneticPiece
-	Fitness float64
+	Order       []int  // permutation of indices into the piece list
+	Rotations   []bool // rotation gene, indexed like the piece list
+	Fitness     float64
+	Sheets      int     // sheets used by the decoded layout
+	Placed      int     // pieces placed by the decoded layout
+	Utilization float64 // used area over the area of the sheets used (0-1)
 }
 
-type GeneticPiece struct {
-	DesignID int
-	X, Y     float64
-	Width    float64
-	Height   float64
-	Rotation int
+// progress reports the individual as the best layout after a generation
+func (individual *GeneticIndividual) progress(generation int) models.JobProgress {
+	return models.JobProgress{
+		Generation:      generation,
+		BestUtilization: individual.Utilization * 100,
+		SheetsUsed:      individual.Sheets,
+		PiecesPlaced:    individual.Placed,
+	}
 }
 
-func (s *OptimizerService) createPieceList(designs map[int]*models.Design, designRequests []models.DesignItem) []PieceToPlace {
+// geneticParams holds the genetic algorithm settings resolved from the options
+type geneticParams struct {
+	populationSize int
+	maxGenerations int
+	eliteCount     int
+	crossoverRate  float64
+	mutationRate   float64
+	qualityTarget  float64
+}
+
+func newGeneticParams(options *models.OptimizeOptions) geneticParams {
+	params := geneticParams{
+		populationSize: options.PopulationSize,
+		maxGenerations: options.MaxIterations,
+		crossoverRate:  options.CrossoverRate,
+		mutationRate:   options.MutationRate,
+		qualityTarget:  options.QualityTarget,
+	}
+
+	if params.populationSize < 2 {
+		params.populationSize = 50
+	}
+	if params.maxGenerations <= 0 {
+		params.maxGenerations = 100
+	}
+	if params.crossoverRate <= 0 {
+		params.crossoverRate = 0.8
+	}
+	if params.mutationRate <= 0 {
+		params.mutationRate = 0.1
+	}
+	if params.qualityTarget <= 0 || params.qualityTarget > 1 {
+		params.qualityTarget = 1
+	}
+
+	params.eliteCount = params.populationSize / 10
+	if params.eliteCount < 1 {
+		params.eliteCount = 1
+	}
+
+	return params
+}
+
+func (s *OptimizerService) createPieceList(items []models.DesignItem, sheet *models.GlassSheet, options *models.OptimizeOptions) []PieceToPlace {
 	var pieces []PieceToPlace
 
-	for _, req := range designRequests {
-		for i := 0; i < req.Quantity; i++ {
-			pieces = append(pieces, PieceToPlace{
-				DesignID: req.DesignID,
-				Quantity: 1,
-				Priority: req.Priority,
-			})
+	for _, item := range items {
+		piece := PieceToPlace{
+			DesignID:     item.DesignID,
+			Name:         item.Design.Name,
+			Width:        item.Design.Width,
+			Height:       item.Design.Height,
+			Quantity:     1,
+			Priority:     item.Priority,
+			DueDate:      item.DueDate,
+			Order:        item.Order,
+			Grain:        pieceGrain(item.Design.Grain, sheet.Specs.Pattern),
+			Holes:        designHoleAreas(item.Design),
+			EdgeDeletion: options.EdgeDeletion,
+		}
+		piece.Outline, piece.Tolerance = designOutline(item.Design)
+		addGrindingAllowance(&piece, options.GrindingAllowance)
+
+		for i := 0; i < item.Quantity; i++ {
+			piece.serial = len(pieces)
+			pieces = append(pieces, piece)
 		}
 	}
 
 	return pieces
 }
 
-func (s *OptimizerService) sortPieces(pieces []PieceToPlace, sortBy, sortOrder string) {
-	// For now, implement basic sorting - can be enhanced with more criteria
-	sort.Slice(pieces, func(i, j int) bool {
-		if sortOrder == "desc" {
-			return pieces[i].Priority > pieces[j].Priority
-		}
-		return pieces[i].Priority < pieces[j].Priority
-	})
-}
+func (s *OptimizerService) getOrientations(piece PieceToPlace, allowRotation bool) []Orientation {
+	// A grain lock holds whether rotation is allowed or not
+	switch piece.Grain {
+	case grainUpright:
+		return []Orientation{{Width: piece.Width, Height: piece.Height, Rotation: 0}}
+	case grainTurned:
+		return []Orientation{{Width: piece.Height, Height: piece.Width, Rotation: 90}}
+	}
 
-func (s *OptimizerService) getOrientations(design *models.Design, allowRotation bool) []Orientation {
 	orientations := []Orientation{
-		{Width: design.Width, Height: design.Height, Rotation: 0},
+		{Width: piece.Width, Height: piece.Height, Rotation: 0},
 	}
 
 	if allowRotation {
-		orientations = append(orientations, Orientation{
-			Width: design.Height, Height: design.Width, Rotation: 90,
-		})
+		rotated := Orientation{Width: piece.Height, Height: piece.Width, Rotation: 90}
+		if piece.PreferRotated {
+			orientations = append([]Orientation{rotated}, orientations...)
+		} else {
+			orientations = append(orientations, rotated)
+		}
 	}
 
 	return orientations
@@ -548,7 +1248,42 @@ func (s *OptimizerService) updateAvailableSpaces(spaces []Rectangle, placedPiece
 	}
 
 	// Remove spaces that are too small to be useful
-	return s.filterSmallSpaces(newSpaces, 10.0) // Minimum 10mm spaces
+	newSpaces = s.filterSmallSpaces(newSpaces, 10.0) // Minimum 10mm spaces
+
+	return s.pruneContainedSpaces(newSpaces)
+}
+
+// pruneContainedSpaces drops free rectangles that lie inside another one;
+// any piece fitting a contained space also fits its container further
+// bottom-left, so they only slow the search down
+func (s *OptimizerService) pruneContainedSpaces(spaces []Rectangle) []Rectangle {
+	var pruned []Rectangle
+
+	for i, space := range spaces {
+		contained := false
+		for j, other := range spaces {
+			if i == j || !rectangleContains(other, space) {
+				continue
+			}
+			// Keep the first of two identical rectangles
+			if rectangleContains(space, other) && i < j {
+				continue
+			}
+			contained = true
+			break
+		}
+		if !contained {
+			pruned = append(pruned, space)
+		}
+	}
+
+	return pruned
+}
+
+func rectangleContains(outer, inner Rectangle) bool {
+	return inner.X >= outer.X && inner.Y >= outer.Y &&
+		inner.X+inner.Width <= outer.X+outer.Width &&
+		inner.Y+inner.Height <= outer.Y+outer.Height
 }
 
 func (s *OptimizerService) rectanglesIntersect(a, b Rectangle) bool {
@@ -603,128 +1338,128 @@ func (s *OptimizerService) filterSmallSpaces(spaces []Rectangle, minSize float64
 	return filtered
 }
 
-func (s *OptimizerService) generateCutPaths(pieces []models.PlacedPiece) []models.CutPath {
-	var cutPaths []models.CutPath
-	pathID := 1
-
+func (s *OptimizerService) calculateUsedArea(pieces []models.PlacedPiece) float64 {
+	totalArea := 0.0
 	for _, piece := range pieces {
-		// Generate rectangular cut path for each piece
-		// Bottom edge
-		cutPaths = append(cutPaths, models.CutPath{
-			ID:       fmt.Sprintf("cut_%d_bottom", pathID),
-			Type:     "horizontal",
-			StartX:   piece.X,
-			StartY:   piece.Y,
-			EndX:     piece.X + piece.Width,
-			EndY:     piece.Y,
-			Order:    pathID * 4,
-			ToolType: "straight",
-			Speed:    100.0,
-			Pieces:   []string{piece.ID},
-		})
+		if len(piece.Outline) > 0 {
+			totalArea += math.Abs(polygonArea(piece.Outline))
+		} else {
+			totalArea += piece.Width * piece.Height
+		}
+	}
+	return totalArea
+}
 
-		// Right edge
-		cutPaths = append(cutPaths, models.CutPath{
-			ID:       fmt.Sprintf("cut_%d_right", pathID),
-			Type:     "vertical",
-			StartX:   piece.X + piece.Width,
-			StartY:   piece.Y,
-			EndX:     piece.X + piece.Width,
-			EndY:     piece.Y + piece.Height,
-			Order:    pathID*4 + 1,
-			ToolType: "straight",
-			Speed:    100.0,
-			Pieces:   []string{piece.ID},
-		})
+// Genetic Algorithm Helper Methods
 
-		// Top edge
-		cutPaths = append(cutPaths, models.CutPath{
-			ID:       fmt.Sprintf("cut_%d_top", pathID),
-			Type:     "horizontal",
-			StartX:   piece.X + piece.Width,
-			StartY:   piece.Y + piece.Height,
-			EndX:     piece.X,
-			EndY:     piece.Y + piece.Height,
-			Order:    pathID*4 + 2,
-			ToolType: "straight",
-			Speed:    100.0,
-			Pieces:   []string{piece.ID},
-		})
+// heuristicOrders returns piece sequences sorted by area, longest side,
+// height and width (all descending) to seed the initial population
+func (s *OptimizerService) heuristicOrders(pieces []PieceToPlace) [][]int {
+	keys := []func(p PieceToPlace) float64{
+		func(p PieceToPlace) float64 { return p.Width * p.Height },
+		func(p PieceToPlace) float64 { return math.Max(p.Width, p.Height) },
+		func(p PieceToPlace) float64 { return p.Height },
+		func(p PieceToPlace) float64 { return p.Width },
+	}
 
-		// Left edge
-		cutPaths = append(cutPaths, models.CutPath{
-			ID:       fmt.Sprintf("cut_%d_left", pathID),
-			Type:     "vertical",
-			StartX:   piece.X,
-			StartY:   piece.Y + piece.Height,
-			EndX:     piece.X,
-			EndY:     piece.Y,
-			Order:    pathID*4 + 3,
-			ToolType: "straight",
-			Speed:    100.0,
-			Pieces:   []string{piece.ID},
+	orders := make([][]int, 0, len(keys))
+	for _, key := range keys {
+		order := make([]int, len(pieces))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return key(pieces[order[i]]) > key(pieces[order[j]])
 		})
-
-		pathID++
+		orders = append(orders, order)
 	}
 
-	return cutPaths
+	return orders
 }
 
-func (s *OptimizerService) calculateUsedArea(pieces []models.PlacedPiece, designs map[int]*models.Design) float64 {
-	totalArea := 0.0
-	for _, piece := range pieces {
-		totalArea += piece.Width * piece.Height
+func (s *OptimizerService) createRandomIndividual(pieceCount int, options *models.OptimizeOptions, rng *rand.Rand) *GeneticIndividual {
+	individual := &GeneticIndividual{
+		Order:     rng.Perm(pieceCount),
+		Rotations: make([]bool, pieceCount),
 	}
-	return totalArea
-}
 
-// Genetic Algorithm Helper Methods
+	if options.AllowRotation {
+		for i := range individual.Rotations {
+			individual.Rotations[i] = rng.Intn(2) == 1
+		}
+	}
 
-func (s *OptimizerService) createRandomIndividual(pieces []PieceToPlace, sheet *models.GlassSheet, options *models.OptimizeOptions) *GeneticIndividual {
-	individual := &GeneticIndividual{
-		Pieces: []GeneticPiece{},
+	return individual
+}
+
+// decodeIndividual returns the pieces in chromosome order with the preferred
+// orientation taken from the rotation genes. The chromosome only orders
+// pieces due the same day, whatever their priority.
+func (s *OptimizerService) decodeIndividual(individual *GeneticIndividual, pieces []PieceToPlace) []PieceToPlace {
+	ordered := make([]PieceToPlace, len(individual.Order))
+	for i, index := range individual.Order {
+		piece := pieces[index]
+		piece.PreferRotated = individual.Rotations[index]
+		ordered[i] = piece
 	}
+	sequencePieces(ordered, nil)
+	return ordered
+}
 
-	// Randomly place pieces (this is a simplified version)
-	for _, piece := range pieces {
-		x := rand.Float64() * (sheet.Width - 100)
-		y := rand.Float64() * (sheet.Height - 100)
-
-		individual.Pieces = append(individual.Pieces, GeneticPiece{
-			DesignID: piece.DesignID,
-			X:        x,
-			Y:        y,
-			Width:    100, // Simplified
-			Height:   100,
-			Rotation: 0,
-		})
+// evaluateFitness decodes the individual and scores it by the utilization of
+// the sheets it uses; a nearly empty last sheet breaks ties because it is the
+// easiest one to eliminate. Decoding is never cut short so that scores stay
+// comparable.
+func (s *OptimizerService) evaluateFitness(individual *GeneticIndividual, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) {
+	filled, _ := s.fillSheets(context.Background(), stock, s.decodeIndividual(individual, pieces), options, sheetPacker(s.placeBottomLeftFill).filler())
+
+	individual.Sheets = len(filled)
+	if len(filled) == 0 {
+		individual.Placed = 0
+		individual.Utilization = 0
+		individual.Fitness = 0
+		return
 	}
 
-	return individual
+	progress := s.sheetProgress(filled)
+	last := filled[len(filled)-1]
+	lastSheetUtilization := s.calculateUsedArea(last.pieces) / last.sheet.Area()
+
+	individual.Placed = progress.PiecesPlaced
+	individual.Utilization = progress.BestUtilization / 100
+	individual.Fitness = individual.Utilization*100 + (1 - lastSheetUtilization)
 }
 
-func (s *OptimizerService) evaluateFitness(individual *GeneticIndividual, sheet *models.GlassSheet) float64 {
-	// Simplified fitness function - in reality this would be much more complex
-	utilization := float64(len(individual.Pieces)) / (sheet.Area() / 10000.0)
-	return math.Min(utilization, 1.0) * 100.0
+func (s *OptimizerService) fittest(population []*GeneticIndividual) *GeneticIndividual {
+	best := population[0]
+	for _, individual := range population[1:] {
+		if individual.Fitness > best.Fitness {
+			best = individual
+		}
+	}
+	return best
 }
 
 func (s *OptimizerService) cloneIndividual(individual *GeneticIndividual) *GeneticIndividual {
 	clone := &GeneticIndividual{
-		Pieces:  make([]GeneticPiece, len(individual.Pieces)),
-		Fitness: individual.Fitness,
+		Order:       make([]int, len(individual.Order)),
+		Rotations:   make([]bool, len(individual.Rotations)),
+		Fitness:     individual.Fitness,
+		Sheets:      individual.Sheets,
+		Placed:      individual.Placed,
+		Utilization: individual.Utilization,
 	}
-	copy(clone.Pieces, individual.Pieces)
+	copy(clone.Order, individual.Order)
+	copy(clone.Rotations, individual.Rotations)
 	return clone
 }
 
-func (s *OptimizerService) tournamentSelection(population []*GeneticIndividual) *GeneticIndividual {
+func (s *OptimizerService) tournamentSelection(population []*GeneticIndividual, rng *rand.Rand) *GeneticIndividual {
 	tournamentSize := 3
-	best := population[rand.Intn(len(population))]
+	best := population[rng.Intn(len(population))]
 
 	for i := 1; i < tournamentSize; i++ {
-		candidate := population[rand.Intn(len(population))]
+		candidate := population[rng.Intn(len(population))]
 		if candidate.Fitness > best.Fitness {
 			best = candidate
 		}
@@ -733,59 +1468,68 @@ func (s *OptimizerService) tournamentSelection(population []*GeneticIndividual)
 	return best
 }
 
-func (s *OptimizerService) crossover(parent1, parent2 *GeneticIndividual, options *models.OptimizeOptions) *GeneticIndividual {
-	// Simplified crossover - take pieces from both parents
+// crossover combines two parents with order crossover (OX): a slice of the
+// first parent's sequence is kept in place and the remaining pieces follow in
+// the order they appear in the second parent. Rotation genes are inherited
+// from either parent at random.
+func (s *OptimizerService) crossover(parent1, parent2 *GeneticIndividual, rng *rand.Rand) *GeneticIndividual {
+	n := len(parent1.Order)
 	offspring := &GeneticIndividual{
-		Pieces: []GeneticPiece{},
+		Order:     make([]int, n),
+		Rotations: make([]bool, n),
 	}
 
-	crossoverPoint := len(parent1.Pieces) / 2
-	offspring.Pieces = append(offspring.Pieces, parent1.Pieces[:crossoverPoint]...)
-	offspring.Pieces = append(offspring.Pieces, parent2.Pieces[crossoverPoint:]...)
+	start, end := rng.Intn(n), rng.Intn(n)
+	if start > end {
+		start, end = end, start
+	}
 
-	return offspring
-}
+	inherited := make([]bool, n)
+	for i := start; i <= end; i++ {
+		offspring.Order[i] = parent1.Order[i]
+		inherited[parent1.Order[i]] = true
+	}
 
-func (s *OptimizerService) mutate(individual *GeneticIndividual, options *models.OptimizeOptions) {
-	mutationRate := options.MutationRate
-	if mutationRate == 0 {
-		mutationRate = 0.1
+	position := (end + 1) % n
+	for i := 0; i < n; i++ {
+		gene := parent2.Order[(end+1+i)%n]
+		if inherited[gene] {
+			continue
+		}
+		offspring.Order[position] = gene
+		position = (position + 1) % n
 	}
 
-	for i := range individual.Pieces {
-		if rand.Float64() < mutationRate {
-			// Slightly adjust position
-			individual.Pieces[i].X += (rand.Float64() - 0.5) * 20
-			individual.Pieces[i].Y += (rand.Float64() - 0.5) * 20
+	for i := range offspring.Rotations {
+		if rng.Intn(2) == 0 {
+			offspring.Rotations[i] = parent1.Rotations[i]
+		} else {
+			offspring.Rotations[i] = parent2.Rotations[i]
 		}
 	}
+
+	return offspring
 }
 
-func (s *OptimizerService) individualToLayout(individual *GeneticIndividual, sheet *models.GlassSheet, designs map[int]*models.Design) *models.Layout {
-	layout := &models.Layout{
-		SheetWidth:  sheet.Width,
-		SheetHeight: sheet.Height,
-		Pieces:      []models.PlacedPiece{},
-		CutPaths:    []models.CutPath{},
-	}
+// mutate swaps pieces in the sequence and flips rotation genes, each gene
+// changing with probability mutationRate
+func (s *OptimizerService) mutate(individual *GeneticIndividual, mutationRate float64, allowRotation bool, rng *rand.Rand) {
+	n := len(individual.Order)
 
-	for _, piece := range individual.Pieces {
-		design := designs[piece.DesignID]
-		placedPiece := models.PlacedPiece{
-			ID:         models.GenerateID(),
-			DesignID:   piece.DesignID,
-			DesignName: design.Name,
-			X:          piece.X,
-			Y:          piece.Y,
-			Width:      piece.Width,
-			Height:     piece.Height,
-			Rotation:   piece.Rotation,
+	for i := range individual.Order {
+		if rng.Float64() < mutationRate {
+			j := rng.Intn(n)
+			individual.Order[i], individual.Order[j] = individual.Order[j], individual.Order[i]
 		}
-		layout.Pieces = append(layout.Pieces, placedPiece)
 	}
 
-	layout.CutPaths = s.generateCutPaths(layout.Pieces)
-	return layout
+	if allowRotation {
+		for i := range individual.Rotations {
+			if rng.Float64() < mutationRate {
+				individual.Rotations[i] = !individual.Rotations[i]
+			}
+		}
+	}
 }
 
 // Export Methods
@@ -799,19 +1543,70 @@ func (s *OptimizerService) exportAsJSON(optimization *models.Optimization) (*Exp
 }
 
 func (s *OptimizerService) exportAsSVG(optimization *models.Optimization) (*ExportResult, error) {
+	sheets := exportSheets(optimization)
+
+	// Sheets are stacked vertically with a small spacing between them. Sheets
+	// can differ in size, so the canvas is as wide as the widest one.
+	const spacing = 20.0
+	canvasWidth, canvasHeight := 0.0, -spacing
+	for _, sheet := range sheets {
+		canvasWidth = max(canvasWidth, sheet.Width/10)
+		canvasHeight += sheet.Height/10 + spacing
+	}
+
 	// Generate SVG representation
 	svg := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<svg width="%.2f" height="%.2f" xmlns="http://www.w3.org/2000/svg">
-  <rect x="0" y="0" width="%.2f" height="%.2f" fill="none" stroke="black" stroke-width="2"/>`,
-		optimization.Sheet.Width/10, optimization.Sheet.Height/10,
-		optimization.Sheet.Width/10, optimization.Sheet.Height/10)
+<svg width="%.2f" height="%.2f" xmlns="http://www.w3.org/2000/svg">`,
+		canvasWidth, max(canvasHeight, 0))
 
-	for _, piece := range optimization.Layout.Pieces {
+	offsetY := 0.0
+	for _, sheet := range sheets {
 		svg += fmt.Sprintf(`
-  <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="lightblue" stroke="blue" stroke-width="1"/>
+  <g id="sheet-%d" transform="translate(0,%.2f)">
+  <rect x="0" y="0" width="%.2f" height="%.2f" fill="none" stroke="black" stroke-width="2"/>`,
+			sheet.SheetNumber, offsetY, sheet.Width/10, sheet.Height/10)
+
+		for _, piece := range sheet.Pieces {
+			if len(piece.Outline) > 0 {
+				points := ""
+				for i, point := range piece.Outline {
+					if i > 0 {
+						points += " "
+					}
+					points += fmt.Sprintf("%.2f,%.2f", point.X/10, point.Y/10)
+				}
+				svg += fmt.Sprintf(`
+  <polygon points="%s" fill="lightblue" stroke="blue" stroke-width="1"/>`, points)
+			} else {
+				svg += fmt.Sprintf(`
+  <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="lightblue" stroke="blue" stroke-width="1"/>`,
+					piece.X/10, piece.Y/10, piece.Width/10, piece.Height/10)
+			}
+			svg += fmt.Sprintf(`
   <text x="%.2f" y="%.2f" font-size="8" fill="black">%s</text>`,
-			piece.X/10, piece.Y/10, piece.Width/10, piece.Height/10,
-			(piece.X+piece.Width/2)/10, (piece.Y+piece.Height/2)/10, piece.DesignName)
+				(piece.X+piece.Width/2)/10, (piece.Y+piece.Height/2)/10, piece.DesignName)
+		}
+
+		// Cuts are drawn over the pieces, titled with their place in the sequence
+		for _, cut := range sheet.CutPaths {
+			svg += fmt.Sprintf(`
+  <line class="cut" x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="green" stroke-width="0.5"><title>Cut %d</title></line>`,
+				cut.StartX/10, cut.StartY/10, cut.EndX/10, cut.EndY/10, cut.Order)
+		}
+
+		// Defects are drawn over the pieces so the cutter can check them on the table
+		for _, defect := range sheet.Defects {
+			label := defect.Type
+			if label == "" {
+				label = "defect"
+			}
+			svg += fmt.Sprintf(`
+  <rect class="defect" x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="red" fill-opacity="0.4" stroke="red" stroke-width="1"><title>%s</title></rect>`,
+				defect.X/10, defect.Y/10, defect.Width/10, defect.Height/10, label)
+		}
+
+		svg += "\n  </g>"
+		offsetY += sheet.Height/10 + spacing
 	}
 
 	svg += "\n</svg>"
@@ -825,11 +1620,23 @@ func (s *OptimizerService) exportAsSVG(optimization *models.Optimization) (*Expo
 
 func (s *OptimizerService) exportAsDXF(optimization *models.Optimization) (*ExportResult, error) {
 	// Simplified DXF export (would need full DXF library for production)
+	// Each sheet is written on its own layer named after the sheet number, and
+	// each piece as a closed polyline along its outline. The cuts of a sheet
+	// are lines on a layer of their own, in the order they are made.
 	dxf := "0\nSECTION\n2\nENTITIES\n"
 
-	for _, piece := range optimization.Layout.Pieces {
-		dxf += fmt.Sprintf("0\nLINE\n8\n0\n10\n%.2f\n20\n%.2f\n30\n0.0\n11\n%.2f\n21\n%.2f\n31\n0.0\n",
-			piece.X, piece.Y, piece.X+piece.Width, piece.Y)
+	for _, sheet := range exportSheets(optimization) {
+		for _, piece := range sheet.Pieces {
+			outline := pieceOutline(piece)
+			dxf += fmt.Sprintf("0\nLWPOLYLINE\n8\nSHEET_%d\n90\n%d\n70\n1\n", sheet.SheetNumber, len(outline))
+			for _, point := range outline {
+				dxf += fmt.Sprintf("10\n%.2f\n20\n%.2f\n", point.X, point.Y)
+			}
+		}
+		for _, cut := range sheet.CutPaths {
+			dxf += fmt.Sprintf("0\nLINE\n8\nCUTS_%d\n10\n%.2f\n20\n%.2f\n11\n%.2f\n21\n%.2f\n",
+				sheet.SheetNumber, cut.StartX, cut.StartY, cut.EndX, cut.EndY)
+		}
 	}
 
 	dxf += "0\nENDSEC\n0\nEOF\n"
@@ -843,24 +1650,93 @@ func (s *OptimizerService) exportAsDXF(optimization *models.Optimization) (*Expo
 
 func (s *OptimizerService) exportAsCuttingList(optimization *models.Optimization) (*ExportResult, error) {
 	list := fmt.Sprintf("Cutting List for Optimization: %s\n", optimization.Name)
-	list += fmt.Sprintf("Sheet: %s (%.0f x %.0f x %.0fmm)\n\n",
+	list += fmt.Sprintf("Sheet: %s (%.0f x %.0f x %.0fmm)\n",
 		optimization.Sheet.Name, optimization.Sheet.Width, optimization.Sheet.Height, optimization.Sheet.Thickness)
+	if optimization.Sheet.Specs.Pattern != "" {
+		list += fmt.Sprintf("Pattern: %s\n", optimization.Sheet.Specs.Pattern)
+	}
+	list += fmt.Sprintf("Sheets Used: %d\n", optimization.Layout.SheetCount())
+	if stats := optimization.Layout.Statistics; stats.Optimal {
+		list += "Fewest Sheets Possible: yes\n"
+	} else if stats.SheetLowerBound > 0 {
+		list += fmt.Sprintf("Fewest Sheets Possible: at least %d (%d more used)\n", stats.SheetLowerBound, stats.SheetGap)
+	}
+	list += "\n"
 
 	list += fmt.Sprintf("Utilization: %.2f%%\n", optimization.Layout.Statistics.UtilizationRate)
 	list += fmt.Sprintf("Waste: %.2f%%\n\n", optimization.Layout.Statistics.WasteRate)
 
+	sheets := exportSheets(optimization)
+	for _, sheet := range sheets {
+		if len(sheet.Pieces) > 0 && sheet.Pieces[0].EdgeDeletion > 0 {
+			list += fmt.Sprintf("Edge Deletion: %.1fmm on every edge\n\n", sheet.Pieces[0].EdgeDeletion)
+			break
+		}
+	}
+
+	// Pieces are cut to their gross size and ground down to their net size.
+	// The pattern column gives the way the pattern runs through each piece.
 	list += "Pieces to Cut:\n"
-	list += "ID\tDesign\tX\tY\tWidth\tHeight\tRotation\n"
+	list += "Sheet\tID\tDesign\tX\tY\tGross Width\tGross Height\tNet Width\tNet Height\tRotation\tPattern\n"
+
+	for _, sheet := range sheets {
+		list += cuttingListSheet(sheet, optimization.Sheet)
+		for _, piece := range sheet.Pieces {
+			netWidth, netHeight := piece.Width, piece.Height
+			if piece.NetWidth > 0 {
+				netWidth, netHeight = piece.NetWidth, piece.NetHeight
+			}
+			pattern := "-"
+			if piece.Pattern != "" {
+				pattern = string(piece.Pattern)
+			}
+			list += fmt.Sprintf("%d\t%s\t%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%d°\t%s\n",
+				sheet.SheetNumber, piece.ID, piece.DesignName, piece.X, piece.Y,
+				piece.Width, piece.Height, netWidth, netHeight, piece.Rotation, pattern)
+		}
+	}
+
+	// Cutting the sheets in order finishes each order on the sheet listing it
+	completed := ""
+	for _, sheet := range sheets {
+		if len(sheet.CompletedOrders) > 0 {
+			completed += fmt.Sprintf("%d\t%s\n", sheet.SheetNumber, strings.Join(sheet.CompletedOrders, ", "))
+		}
+	}
+	if completed != "" {
+		list += "\nOrders Completed:\nSheet\tOrders\n" + completed
+	}
 
-	for _, piece := range optimization.Layout.Pieces {
-		list += fmt.Sprintf("%s\t%s\t%.1f\t%.1f\t%.1f\t%.1f\t%d°\n",
-			piece.ID[:8], piece.DesignName, piece.X, piece.Y,
-			piece.Width, piece.Height, piece.Rotation)
+	// Cuts are listed in the order they are made: each break cut runs from
+	// edge to edge of the panel left by the stage before it
+	list += "\nCutting Sequence:\n"
+	list += "Sheet\tOrder\tStage\tType\tFrom\tTo\tLength\tPieces\n"
+	for _, sheet := range sheets {
+		list += cuttingListSheet(sheet, optimization.Sheet)
+		for _, cut := range sheet.CutPaths {
+			pieces := "-"
+			if len(cut.Pieces) > 0 {
+				pieces = strings.Join(cut.Pieces, ", ")
+			}
+			list += fmt.Sprintf("%d\t%d\t%d\t%s\t(%.1f, %.1f)\t(%.1f, %.1f)\t%.1f\t%s\n",
+				sheet.SheetNumber, cut.Order, cut.Stage, cut.Type, cut.StartX, cut.StartY, cut.EndX, cut.EndY,
+				math.Hypot(cut.EndX-cut.StartX, cut.EndY-cut.StartY), pieces)
+		}
 	}
 
-	list += fmt.Sprintf("\nTotal Pieces: %d\n", len(optimization.Layout.Pieces))
-	list += fmt.Sprintf("Cutting Length: %.2fmm\n", optimization.Layout.Statistics.CuttingLength)
-	list += fmt.Sprintf("Estimated Cutting Time: %.1f minutes\n", optimization.Layout.Statistics.CuttingTime)
+	stats := optimization.Layout.Statistics
+	list += fmt.Sprintf("\nTotal Pieces: %d\n", len(optimization.Layout.AllPieces()))
+	if stats.UnplacedPieces > 0 {
+		list += fmt.Sprintf("Pieces Not Placed: %d of %d\n", stats.UnplacedPieces, stats.TotalPieces)
+	}
+	list += fmt.Sprintf("Material Cost: %.2f\n", optimization.TotalCost)
+	list += fmt.Sprintf("Largest Waste Area: %.0fmm²\n", stats.LargestWasteArea)
+	if stats.SmallestGap > 0 {
+		list += fmt.Sprintf("Smallest Gap Between Pieces: %.1fmm\n", stats.SmallestGap)
+	}
+	list += fmt.Sprintf("Cutting Length: %.2fmm\n", stats.CuttingLength)
+	list += fmt.Sprintf("Travel Length: %.2fmm in %d moves\n", stats.TravelLength, stats.RapidMoves)
+	list += fmt.Sprintf("Estimated Cutting Time: %.1f minutes\n", stats.CuttingTime)
 
 	return &ExportResult{
 		Format:   "txt",
@@ -869,6 +1745,46 @@ func (s *OptimizerService) exportAsCuttingList(optimization *models.Optimization
 	}, nil
 }
 
+// cuttingListSheet returns the line naming the glass to load for a sheet of
+// the cutting list: its size, stock sheet and the remnant it is cut from.
+// Sheets saved before mixed sizes are of the request sheet.
+func cuttingListSheet(sheet models.SheetLayout, requested *models.GlassSheet) string {
+	sheetID := sheet.SheetID
+	if sheetID == 0 && requested != nil {
+		sheetID = requested.ID
+	}
+	line := fmt.Sprintf("-- Sheet %d: %.0f x %.0fmm, stock sheet %d", sheet.SheetNumber, sheet.Width, sheet.Height, sheetID)
+	if sheet.RemnantID > 0 {
+		line += fmt.Sprintf(", remnant %d", sheet.RemnantID)
+	}
+	if sheet.Instance > 0 {
+		line += fmt.Sprintf(", instance %d", sheet.Instance)
+	}
+	return line + "\n"
+}
+
+// exportSheets returns the sheets of an optimization, wrapping layouts saved
+// before multi-sheet support into a single sheet
+func exportSheets(optimization *models.Optimization) []models.SheetLayout {
+	return layoutSheets(&optimization.Layout)
+}
+
+// layoutSheets returns the sheets of a layout, wrapping layouts saved before
+// multi-sheet support into a single sheet
+func layoutSheets(layout *models.Layout) []models.SheetLayout {
+	if !layout.SingleSheet() {
+		return layout.Sheets
+	}
+
+	return []models.SheetLayout{{
+		SheetNumber: 1,
+		Width:       layout.SheetWidth,
+		Height:      layout.SheetHeight,
+		Pieces:      layout.Pieces,
+		CutPaths:    layout.CutPaths,
+	}}
+}
+
 // Response types
 type OptimizationListResponse struct {
 	Optimizations []models.Optimization `json:"optimizations"`
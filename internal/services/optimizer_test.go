@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/storage"
+)
+
+// newTestOptimizer creates an optimizer backed by a fresh SQLite database
+// with the default sheet catalogue and a single user
+func newTestOptimizer(t *testing.T) (*OptimizerService, storage.Storage, int64) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := storage.InitializeDatabase(filepath.Join(t.TempDir(), "test.db"), logger)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := storage.NewSQLiteStorage(db, logger)
+	user := &models.User{Email: "planner@example.com", PasswordHash: "x", FirstName: "Test", LastName: "User"}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	return NewOptimizerService(store, logger), store, user.ID
+}
+
+func TestRunOptimizationMultiSheet(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	for _, algorithm := range []string{"blf", "greedy", "genetic"} {
+		t.Run(algorithm, func(t *testing.T) {
+			req := &models.OptimizationRequest{
+				Name:      "Multi-sheet " + algorithm,
+				SheetID:   1, // Standard 2m x 3m
+				Algorithm: algorithm,
+				Designs: []models.DesignItem{
+					{DesignID: 0, Name: "Door", Width: 900, Height: 2100, Quantity: 4},
+					{DesignID: 0, Name: "Shelf", Width: 600, Height: 400, Quantity: 6},
+				},
+				Options: models.OptimizeOptions{AllowRotation: true},
+			}
+
+			optimization, err := service.RunOptimization(context.Background(), req, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+
+			if optimization.ID == 0 {
+				t.Errorf("Optimization was not persisted")
+			}
+
+			if got := len(optimization.Layout.AllPieces()); got != 10 {
+				t.Errorf("Placed pieces incorrect: got %d, want %d", got, 10)
+			}
+
+			if len(optimization.Layout.Sheets) < 2 {
+				t.Errorf("Expected pieces to spread over several sheets, got %d", len(optimization.Layout.Sheets))
+			}
+
+			// Custom pieces must keep their own dimensions
+			for _, piece := range optimization.Layout.AllPieces() {
+				if piece.DesignName == "Shelf" && piece.Width*piece.Height != 600*400 {
+					t.Errorf("Shelf placed with wrong size: %.0fx%.0f", piece.Width, piece.Height)
+				}
+			}
+
+			stored, err := service.GetOptimization(optimization.ID, userID)
+			if err != nil {
+				t.Fatalf("GetOptimization() error = %v", err)
+			}
+
+			if len(stored.Layout.Sheets) != len(optimization.Layout.Sheets) {
+				t.Errorf("Stored sheets incorrect: got %d, want %d", len(stored.Layout.Sheets), len(optimization.Layout.Sheets))
+			}
+		})
+	}
+}
+
+func TestAlgorithmsProduceLegalLayouts(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	algorithms := []string{"genetic", "maxrects-bssf", "maxrects-blsf", "maxrects-baf", "maxrects-cp", "skyline"}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			req := &models.OptimizationRequest{
+				Name:      "Legal " + algorithm,
+				SheetID:   1,
+				Algorithm: algorithm,
+				Designs: []models.DesignItem{
+					{DesignID: 0, Name: "Panel", Width: 700, Height: 1300, Quantity: 5},
+					{DesignID: 0, Name: "Strip", Width: 1800, Height: 250, Quantity: 4},
+					{DesignID: 0, Name: "Tile", Width: 450, Height: 450, Quantity: 8},
+				},
+				Options: models.OptimizeOptions{
+					AllowRotation:  true,
+					PopulationSize: 20,
+					MaxIterations:  15,
+					MutationRate:   0.2,
+					CrossoverRate:  0.9,
+				},
+			}
+
+			optimization, err := service.RunOptimization(context.Background(), req, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+
+			assertLegalLayout(t, &optimization.Layout)
+
+			if got := len(optimization.Layout.AllPieces()); got != 17 {
+				t.Errorf("Placed pieces incorrect: got %d, want %d", got, 17)
+			}
+
+			if optimization.Layout.Statistics.SheetsUsed != 2 {
+				t.Errorf("Sheets used incorrect: got %d, want %d", optimization.Layout.Statistics.SheetsUsed, 2)
+			}
+		})
+	}
+}
+
+// assertLegalLayout fails the test with every violation the validator found
+// in the layout after the run
+func assertLegalLayout(t *testing.T, layout *models.Layout) {
+	t.Helper()
+
+	for _, violation := range layout.Violations {
+		t.Errorf("Sheet %d: %s", violation.Sheet, violation.Message)
+	}
+	for _, sheet := range layout.Sheets {
+		assertCutsClear(t, sheet)
+		assertBreakingSequence(t, sheet)
+	}
+}
+
+func TestGuillotineCutsRunThroughPanels(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	for _, stages := range []int{2, 3, 4} {
+		req := &models.OptimizationRequest{
+			Name:      "Guillotine",
+			SheetID:   1,
+			Algorithm: "guillotine",
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Door", Width: 900, Height: 2100, Quantity: 2},
+				{DesignID: 0, Name: "Pane", Width: 500, Height: 700, Quantity: 7},
+				{DesignID: 0, Name: "Strip", Width: 1500, Height: 120, Quantity: 5},
+			},
+			Options: models.OptimizeOptions{AllowRotation: true, MaxCutStages: stages},
+		}
+
+		optimization, err := service.RunOptimization(context.Background(), req, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%d stages) error = %v", stages, err)
+		}
+
+		if got := len(optimization.Layout.AllPieces()); got != 14 {
+			t.Errorf("%d stages: placed pieces incorrect: got %d, want %d", stages, got, 14)
+		}
+
+		for _, sheet := range optimization.Layout.Sheets {
+			if sheet.CutTree == nil {
+				t.Fatalf("%d stages: sheet %d has no cut tree", stages, sheet.SheetNumber)
+			}
+
+			for _, cut := range sheet.CutPaths {
+				if cut.Stage > stages+1 {
+					t.Errorf("%d stages: cut %s at stage %d", stages, cut.ID, cut.Stage)
+				}
+				for _, piece := range sheet.Pieces {
+					crossesX := cut.StartX == cut.EndX && piece.X < cut.StartX && cut.StartX < piece.X+piece.Width &&
+						piece.Y < cut.EndY && cut.StartY < piece.Y+piece.Height
+					crossesY := cut.StartY == cut.EndY && piece.Y < cut.StartY && cut.StartY < piece.Y+piece.Height &&
+						piece.X < cut.EndX && cut.StartX < piece.X+piece.Width
+					if crossesX || crossesY {
+						t.Errorf("%d stages: cut %s runs through piece %s", stages, cut.ID, piece.DesignName)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSeededRunsAreReproducible(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	run := func(seed int64) *models.Optimization {
+		req := &models.OptimizationRequest{
+			Name:      "Seeded",
+			SheetID:   1,
+			Algorithm: "genetic",
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Panel", Width: 700, Height: 1300, Quantity: 5},
+				{DesignID: 0, Name: "Tile", Width: 450, Height: 450, Quantity: 8},
+			},
+			Options: models.OptimizeOptions{AllowRotation: true, PopulationSize: 10, MaxIterations: 10, Seed: seed},
+		}
+
+		optimization, err := service.RunOptimization(context.Background(), req, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization() error = %v", err)
+		}
+		return optimization
+	}
+
+	first, second := run(42), run(42)
+
+	firstLayout, _ := json.Marshal(first.Layout)
+	secondLayout, _ := json.Marshal(second.Layout)
+	if !bytes.Equal(firstLayout, secondLayout) {
+		t.Errorf("Layouts differ for the same seed")
+	}
+
+	stored, err := service.GetOptimization(first.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	if stored.Seed != 42 {
+		t.Errorf("Stored seed incorrect: got %d, want %d", stored.Seed, 42)
+	}
+
+	unseeded := run(0)
+	if unseeded.Seed == 0 {
+		t.Errorf("Expected a seed to be picked and stored for an unseeded run")
+	}
+
+	// Rerunning a stored optimization reuses its options and the seed picked
+	// for it, and reproduces its layout
+	stored, err = service.GetOptimization(unseeded.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	rerun, err := service.RunOptimization(context.Background(), service.RerunRequest(stored), userID)
+	if err != nil {
+		t.Fatalf("RunOptimization(rerun) error = %v", err)
+	}
+	if rerun.Seed != unseeded.Seed || !reflect.DeepEqual(rerun.Options, stored.Options) {
+		t.Errorf("Rerun options incorrect: got seed %d %+v, want seed %d %+v", rerun.Seed, rerun.Options, unseeded.Seed, stored.Options)
+	}
+	storedLayout, _ := json.Marshal(stored.Layout)
+	rerunLayout, _ := json.Marshal(rerun.Layout)
+	if !bytes.Equal(storedLayout, rerunLayout) {
+		t.Errorf("Rerun layout differs from the stored layout")
+	}
+}
+
+func TestCancelledRunReturnsPartialLayout(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, algorithm := range []string{"blf", "genetic"} {
+		req := &models.OptimizationRequest{
+			Name:      "Cancelled " + algorithm,
+			SheetID:   1,
+			Algorithm: algorithm,
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Tile", Width: 450, Height: 450, Quantity: 12},
+			},
+			Options: models.OptimizeOptions{Seed: 7},
+		}
+
+		optimization, err := service.RunOptimization(ctx, req, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+
+		if !optimization.Layout.Partial {
+			t.Errorf("%s: expected layout to be flagged partial", algorithm)
+		}
+		if optimization.ID == 0 {
+			t.Errorf("%s: partial optimization was not persisted", algorithm)
+		}
+	}
+}
+
+func TestOversizedPiecesUseNoSheets(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Oversized", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Wall", Width: 5000, Height: 4000, Quantity: 2}},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+
+	// The stored layout is read back the same way
+	stored, err := service.GetOptimization(optimization.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	for _, got := range []*models.Optimization{optimization, stored} {
+		stats := got.Layout.Statistics
+		if got.Layout.SheetCount() != 0 || stats.SheetsUsed != 0 {
+			t.Errorf("Sheets used incorrect: got %d (%d in statistics), want 0", got.Layout.SheetCount(), stats.SheetsUsed)
+		}
+		if got.TotalCost != 0 || got.TotalArea != 0 || stats.UtilizationRate != 0 {
+			t.Errorf("Empty layout incorrect: got cost %.2f, area %.0f, utilization %.2f, want 0",
+				got.TotalCost, got.TotalArea, stats.UtilizationRate)
+		}
+		if stats.UnplacedPieces != 2 {
+			t.Errorf("Unplaced pieces incorrect: got %d, want %d", stats.UnplacedPieces, 2)
+		}
+	}
+
+	// Layouts saved before multi-sheet support still count their one sheet
+	legacy := models.Optimization{LayoutData: `{"sheet_width":2000,"sheet_height":3000,"pieces":[],"cut_paths":[]}`}
+	if err := legacy.UnmarshalLayoutData(); err != nil {
+		t.Fatalf("UnmarshalLayoutData() error = %v", err)
+	}
+	if got := legacy.Layout.SheetCount(); got != 1 {
+		t.Errorf("Legacy sheet count incorrect: got %d, want %d", got, 1)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"glass-optimizer/internal/models"
+)
+
+// portfolioHeuristics are the placement heuristics the auto algorithm tries
+// with every ordering of sortStrategies
+var portfolioHeuristics = []string{
+	"blf", "greedy", "guillotine", "maxrects-bssf", "maxrects-blsf", "maxrects-baf", "maxrects-cp", "skyline",
+}
+
+// portfolioStrategy is an algorithm and piece order tried by the auto
+// algorithm
+type portfolioStrategy struct {
+	algorithm string
+	sortBy    string // Empty for algorithms that order the pieces themselves
+}
+
+func (strategy portfolioStrategy) String() string {
+	if strategy.sortBy == "" {
+		return strategy.algorithm
+	}
+	return strategy.algorithm + "/" + strategy.sortBy
+}
+
+// portfolioStrategies returns the strategies the auto algorithm tries: every
+// placement heuristic with every ordering, followed by the genetic algorithm.
+// True-shape nesting joins the heuristics when a piece has an outline.
+func portfolioStrategies(pieces []PieceToPlace) []portfolioStrategy {
+	heuristics := portfolioHeuristics
+	for _, piece := range pieces {
+		if piece.Outline != nil {
+			heuristics = append(heuristics[:len(heuristics):len(heuristics)], "nfp")
+			break
+		}
+	}
+
+	var strategies []portfolioStrategy
+	for _, algorithm := range heuristics {
+		for _, sortBy := range sortStrategies {
+			strategies = append(strategies, portfolioStrategy{algorithm: algorithm, sortBy: sortBy})
+		}
+	}
+	return append(strategies, portfolioStrategy{algorithm: "genetic"})
+}
+
+// runPortfolio runs the strategies of portfolioStrategies concurrently, one
+// per CPU, and returns the best layout by betterLayout with a scoreboard of
+// every strategy. Strategies not started when ctx is done are skipped; those
+// running stop early and compete with what they placed. Ties go to the
+// strategy listed first, so the result does not depend on scheduling. With
+// options.improve, only the winner goes through the improvement pass.
+func (s *OptimizerService) runPortfolio(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions) (*models.Layout, error) {
+	strategies := portfolioStrategies(pieces)
+	s.logger.Debug("Running auto algorithm", "strategies", len(strategies))
+
+	layouts := make([]*models.Layout, len(strategies))
+	scores := make([]models.StrategyScore, len(strategies))
+	errs := make([]error, len(strategies))
+
+	// Progress is only reported for the best layout so far
+	quietCtx := WithProgress(ctx, nil)
+	var mu sync.Mutex
+	var best *models.Layout
+
+	run := func(i int) {
+		strategy := strategies[i]
+		strategyOptions := *options
+		strategyOptions.SortBy = strategy.sortBy
+		strategyOptions.Improve = false // Only the winner is improved
+		strategyPieces := make([]PieceToPlace, len(pieces))
+		copy(strategyPieces, pieces)
+
+		start := time.Now()
+		layout, err := s.runAlgorithm(quietCtx, strategy.algorithm, stock, strategyPieces, &strategyOptions)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+
+		layouts[i] = layout
+		scores[i] = strategyScore(strategy, layout)
+		scores[i].ExecutionTime = time.Since(start).Seconds()
+		scores[i].Partial = ctx.Err() != nil
+
+		mu.Lock()
+		defer mu.Unlock()
+		if best == nil || betterLayout(layout, best) {
+			best = layout
+			reportProgress(ctx, layoutProgress(best))
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), len(strategies)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				run(i)
+			}
+		}()
+	}
+
+	// The first strategy always runs so that there is a layout to return
+	jobs <- 0
+	started := 1
+feed:
+	for ; started < len(strategies) && ctx.Err() == nil; started++ {
+		select {
+		case jobs <- started:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	winner := -1
+	for i, layout := range layouts {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if i >= started {
+			scores[i] = models.StrategyScore{
+				Strategy:  strategies[i].String(),
+				Algorithm: strategies[i].algorithm,
+				SortBy:    strategies[i].sortBy,
+				Skipped:   true,
+			}
+			continue
+		}
+		if winner < 0 || betterLayout(layout, layouts[winner]) {
+			winner = i
+		}
+	}
+
+	layout := layouts[winner]
+	if options.Improve && ctx.Err() == nil {
+		// Running the winning strategy again reproduces its layout, which the
+		// improvement pass then starts from
+		strategy := strategies[winner]
+		strategyOptions := *options
+		strategyOptions.SortBy = strategy.sortBy
+		strategyPieces := make([]PieceToPlace, len(pieces))
+		copy(strategyPieces, pieces)
+
+		start := time.Now()
+		improved, err := s.runAlgorithm(quietCtx, strategy.algorithm, stock, strategyPieces, &strategyOptions)
+		if err != nil {
+			return nil, err
+		}
+		layout = improved
+		executionTime := scores[winner].ExecutionTime + time.Since(start).Seconds()
+		scores[winner] = strategyScore(strategy, layout)
+		scores[winner].ExecutionTime = executionTime
+		scores[winner].Partial = ctx.Err() != nil
+	}
+
+	scores[winner].Winner = true
+	layout.Scoreboard = scores
+
+	s.logger.Debug("Auto algorithm finished", "winner", strategies[winner].String(),
+		"strategies", started, "skipped", len(strategies)-started)
+
+	return layout, nil
+}
+
+// strategyScore summarises the layout a strategy produced
+func strategyScore(strategy portfolioStrategy, layout *models.Layout) models.StrategyScore {
+	progress := layoutProgress(layout)
+	return models.StrategyScore{
+		Strategy:        strategy.String(),
+		Algorithm:       strategy.algorithm,
+		SortBy:          strategy.sortBy,
+		SheetsUsed:      progress.SheetsUsed,
+		PiecesPlaced:    progress.PiecesPlaced,
+		UtilizationRate: progress.BestUtilization,
+		Cost:            layoutCost(layout),
+	}
+}
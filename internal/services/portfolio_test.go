@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestAutoAlgorithmKeepsTheBestStrategy(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	designs := []models.DesignItem{
+		{DesignID: 0, Name: "Door", Width: 800, Height: 2100, Quantity: 3},
+		{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 9},
+		{DesignID: 0, Name: "Light", Width: 350, Height: 350, Quantity: 12},
+	}
+	options := models.OptimizeOptions{AllowRotation: true, MaxIterations: 5, PopulationSize: 6, Seed: 7}
+
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Auto", SheetID: 1, Algorithm: "auto", Designs: designs, Options: options,
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	layout := &optimization.Layout
+	assertLegalLayout(t, layout)
+	if got := len(layout.AllPieces()); got != 24 {
+		t.Fatalf("Placed pieces incorrect: got %d, want %d", got, 24)
+	}
+
+	if got, want := len(layout.Scoreboard), len(portfolioHeuristics)*len(sortStrategies)+1; got != want {
+		t.Fatalf("Scoreboard entries incorrect: got %d, want %d", got, want)
+	}
+	winners := 0
+	for _, score := range layout.Scoreboard {
+		if score.Skipped || score.Partial {
+			t.Errorf("Strategy %s did not finish", score.Strategy)
+		}
+		if !score.Winner {
+			continue
+		}
+		winners++
+		if score.SheetsUsed != len(layout.Sheets) || score.PiecesPlaced != len(layout.AllPieces()) {
+			t.Errorf("Winner %s does not match the layout: %d sheets, %d pieces", score.Strategy, score.SheetsUsed, score.PiecesPlaced)
+		}
+	}
+	if winners != 1 {
+		t.Errorf("Winners incorrect: got %d, want %d", winners, 1)
+	}
+
+	// No single algorithm does better, and the scoreboard is stored
+	for _, algorithm := range []string{"blf", "genetic", "guillotine", "maxrects-bssf"} {
+		single, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name: algorithm, SheetID: 1, Algorithm: algorithm, Designs: designs, Options: options,
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+		if betterLayout(&single.Layout, layout) {
+			t.Errorf("%s beats the auto algorithm", algorithm)
+		}
+	}
+	stored, err := service.GetOptimization(optimization.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	if len(stored.Layout.Scoreboard) != len(layout.Scoreboard) {
+		t.Errorf("Stored scoreboard entries incorrect: got %d, want %d", len(stored.Layout.Scoreboard), len(layout.Scoreboard))
+	}
+
+	// Out of time, only the first strategy runs
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	partial, err := service.RunOptimization(ctx, &models.OptimizationRequest{
+		Name: "Auto cancelled", SheetID: 1, Algorithm: "auto", Designs: designs, Options: options,
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	if !partial.Layout.Partial {
+		t.Error("Cancelled layout not marked partial")
+	}
+	for i, score := range partial.Layout.Scoreboard {
+		if score.Skipped != (i > 0) || score.Winner != (i == 0) {
+			t.Errorf("Strategy %s incorrect: skipped %v, winner %v", score.Strategy, score.Skipped, score.Winner)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"sort"
+
+	"glass-optimizer/internal/models"
+)
+
+// defaultMinRemnantSize is the shortest side, in millimeters, an offcut must
+// have to be kept as a remnant when the request does not set one
+const defaultMinRemnantSize = 300.0
+
+// ConfirmOptimization releases an optimization for cutting. The remnants its
+// layout cuts from are consumed and the offcuts it leaves become available to
+// later optimizations.
+func (s *OptimizerService) ConfirmOptimization(id int, userID int64) (*models.Optimization, error) {
+	optimization, err := s.GetOptimization(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var remnantIDs []int
+	for _, sheet := range optimization.Layout.Sheets {
+		if sheet.RemnantID != 0 {
+			remnantIDs = append(remnantIDs, sheet.RemnantID)
+		}
+	}
+
+	if err := s.storage.ConfirmOptimization(id, userID, remnantIDs); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Optimization confirmed", "id", id, "remnants_used", len(remnantIDs))
+
+	return s.GetOptimization(id, userID)
+}
+
+// remnantSheet represents a remnant as a sheet of its source glass cut to the
+// remnant's size
+func remnantSheet(sheet *models.GlassSheet, remnant *models.Remnant) *models.GlassSheet {
+	resized := *sheet
+	resized.ID = remnant.SheetID
+	resized.Width = remnant.Width
+	resized.Height = remnant.Height
+	resized.Defects = nil
+	return &resized
+}
+
+// findLayoutOffcuts records on every sheet of the layout the leftovers large
+// enough to keep as remnants, and the largest waste area of the layout
+func (s *OptimizerService) findLayoutOffcuts(layout *models.Layout, options *models.OptimizeOptions) {
+	layout.Statistics.LargestWasteArea = 0
+
+	for i := range layout.Sheets {
+		sheet := &layout.Sheets[i]
+		free := s.freeRectangles(sheet, options)
+
+		for _, space := range free {
+			if area := space.Width * space.Height; area > layout.Statistics.LargestWasteArea {
+				layout.Statistics.LargestWasteArea = area
+			}
+		}
+
+		sheet.Offcuts = s.selectOffcuts(free, options)
+	}
+}
+
+// freeRectangles returns the maximal free rectangles of the usable area of a
+// sheet. Pieces and defects are grown by the piece spacing so that free space
+// starts past the cut between them and the leftover.
+func (s *OptimizerService) freeRectangles(sheet *models.SheetLayout, options *models.OptimizeOptions) []Rectangle {
+	spaces := []Rectangle{usableArea(&models.GlassSheet{Width: sheet.Width, Height: sheet.Height}, options)}
+
+	for _, piece := range sheet.Pieces {
+		spaces = s.updateAvailableSpaces(spaces, inflateRectangle(piece, pieceSpacing(options)))
+	}
+	for _, defect := range defectAreas(sheet.Defects, pieceSpacing(options)) {
+		spaces = s.updateAvailableSpaces(spaces, defect)
+	}
+
+	return spaces
+}
+
+// selectOffcuts picks non-overlapping offcuts from the free rectangles of a
+// sheet, largest first, keeping those with both sides at least the minimum
+// remnant size
+func (s *OptimizerService) selectOffcuts(free []Rectangle, options *models.OptimizeOptions) []models.Offcut {
+	var offcuts []models.Offcut
+
+	for {
+		candidates := make([]Rectangle, 0, len(free))
+		for _, space := range free {
+			if space.Width >= options.MinRemnantSize && space.Height >= options.MinRemnantSize {
+				candidates = append(candidates, space)
+			}
+		}
+		if len(candidates) == 0 {
+			return offcuts
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Width*candidates[i].Height > candidates[j].Width*candidates[j].Height
+		})
+		best := candidates[0]
+
+		offcuts = append(offcuts, models.Offcut{X: best.X, Y: best.Y, Width: best.Width, Height: best.Height})
+		spacing := pieceSpacing(options)
+		free = s.updateAvailableSpaces(free, Rectangle{
+			X:      best.X - spacing,
+			Y:      best.Y - spacing,
+			Width:  best.Width + 2*spacing,
+			Height: best.Height + 2*spacing,
+		})
+	}
+}
+
+// recordRemnants stores the offcuts of a saved optimization as pending
+// remnants; they become available when the optimization is confirmed
+func (s *OptimizerService) recordRemnants(optimization *models.Optimization) {
+	for _, sheet := range optimization.Layout.Sheets {
+		for _, offcut := range sheet.Offcuts {
+			remnant := &models.Remnant{
+				SheetID:              sheet.SheetID,
+				Width:                offcut.Width,
+				Height:               offcut.Height,
+				Thickness:            optimization.Sheet.Thickness,
+				Material:             optimization.Sheet.Material,
+				Status:               models.RemnantStatusPending,
+				SourceOptimizationID: &optimization.ID,
+			}
+			if err := s.storage.CreateRemnant(remnant); err != nil {
+				s.logger.Warn("Failed to record remnant", "error", err, "optimization_id", optimization.ID)
+			}
+		}
+	}
+}
+
+func inflateRectangle(piece models.PlacedPiece, margin float64) Rectangle {
+	return Rectangle{
+		X:      piece.X - margin,
+		Y:      piece.Y - margin,
+		Width:  piece.Width + 2*margin,
+		Height: piece.Height + 2*margin,
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestRemnantsFeedBackIntoOptimization(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	run := func(name string, width, height float64) *models.Optimization {
+		t.Helper()
+		req := &models.OptimizationRequest{
+			Name:      name,
+			SheetID:   1, // Standard 2m x 3m
+			Algorithm: "blf",
+			Designs:   []models.DesignItem{{DesignID: 0, Name: name, Width: width, Height: height, Quantity: 1}},
+			Options:   models.OptimizeOptions{MinRemnantSize: 500},
+		}
+		optimization, err := service.RunOptimization(context.Background(), req, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization() error = %v", err)
+		}
+		return optimization
+	}
+
+	// A single panel leaves large offcuts, held back until the job is confirmed
+	first := run("Panel", 1200, 1200)
+	offcuts := first.Layout.Sheets[0].Offcuts
+	if len(offcuts) == 0 {
+		t.Fatalf("Expected offcuts, got none")
+	}
+	for _, offcut := range offcuts {
+		if offcut.Width < 500 || offcut.Height < 500 {
+			t.Errorf("Offcut below minimum remnant size: %+v", offcut)
+		}
+	}
+	if first.Layout.Statistics.LargestWasteArea <= 0 {
+		t.Errorf("LargestWasteArea not computed")
+	}
+
+	pending, _, err := store.GetRemnants(models.RemnantStatusPending, 100, 0)
+	if err != nil {
+		t.Fatalf("GetRemnants() error = %v", err)
+	}
+	if len(pending) != len(offcuts) {
+		t.Fatalf("Pending remnants: got %d, want %d", len(pending), len(offcuts))
+	}
+	if run("Before confirm", 400, 400).Layout.Sheets[0].RemnantID != 0 {
+		t.Errorf("Unconfirmed offcuts must not be offered to other runs")
+	}
+
+	if _, err := service.ConfirmOptimization(first.ID, userID); err != nil {
+		t.Fatalf("ConfirmOptimization() error = %v", err)
+	}
+	if _, err := service.ConfirmOptimization(first.ID, userID); !models.IsConflictError(err) {
+		t.Errorf("Confirming twice: got %v, want conflict", err)
+	}
+
+	// Two plans cut from the same remnant; only the first confirmed wins it
+	second := run("Tile", 400, 400)
+	third := run("Tile again", 400, 400)
+	for _, optimization := range []*models.Optimization{second, third} {
+		sheet := optimization.Layout.Sheets[0]
+		if sheet.RemnantID == 0 {
+			t.Fatalf("%s: expected the piece to be cut from a remnant", optimization.Name)
+		}
+		if sheet.Cost != 0 || optimization.TotalCost != 0 {
+			t.Errorf("%s: remnant should be free, got cost %.2f", optimization.Name, optimization.TotalCost)
+		}
+	}
+
+	// The cutting list names the remnant to load, over the pieces and the cuts
+	list, err := service.ExportOptimization(second.ID, userID, "cutting_list")
+	if err != nil {
+		t.Fatalf("ExportOptimization() error = %v", err)
+	}
+	sheet := second.Layout.Sheets[0]
+	want := fmt.Sprintf("-- Sheet 1: %.0f x %.0fmm, stock sheet 1, remnant %d\n", sheet.Width, sheet.Height, sheet.RemnantID)
+	if got := strings.Count(list.Data.(string), want); got != 2 {
+		t.Errorf("Cutting list names the remnant %d times, want 2: %q", got, want)
+	}
+
+	if _, err := service.ConfirmOptimization(second.ID, userID); err != nil {
+		t.Fatalf("ConfirmOptimization() error = %v", err)
+	}
+	remnant, err := store.GetRemnant(second.Layout.Sheets[0].RemnantID)
+	if err != nil {
+		t.Fatalf("GetRemnant() error = %v", err)
+	}
+	if remnant.Status != models.RemnantStatusConsumed {
+		t.Errorf("Remnant status: got %s, want %s", remnant.Status, models.RemnantStatusConsumed)
+	}
+
+	if third.Layout.Sheets[0].RemnantID != remnant.ID {
+		t.Fatalf("Expected both plans to use the smallest remnant %d, got %d", remnant.ID, third.Layout.Sheets[0].RemnantID)
+	}
+	if _, err := service.ConfirmOptimization(third.ID, userID); !models.IsConflictError(err) {
+		t.Errorf("Confirming with a consumed remnant: got %v, want conflict", err)
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"sort"
+
+	"glass-optimizer/internal/models"
+)
+
+// dueDateFormat is the layout of DesignItem.DueDate. Dates in this layout
+// sort as strings.
+const dueDateFormat = "2006-01-02"
+
+// moreUrgent reports whether piece a must be cut before piece b because it is
+// due earlier. Pieces without a due date come after all pieces that have one.
+// Priority is not compared: it is a sort key that options.sort_by may weigh
+// against the others.
+func moreUrgent(a, b *PieceToPlace) bool {
+	return a.DueDate != b.DueDate && (b.DueDate == "" || (a.DueDate != "" && a.DueDate < b.DueDate))
+}
+
+// sequencePieces orders pieces by due date, and pieces due the same day by
+// less when it is given. Packers fill sheets one after the other in this
+// order, so the pieces due first end up on the first sheets and the others
+// fill the space they leave. The sort is stable.
+func sequencePieces(pieces []PieceToPlace, less func(a, b *PieceToPlace) bool) {
+	sort.SliceStable(pieces, func(i, j int) bool {
+		a, b := &pieces[i], &pieces[j]
+		if moreUrgent(a, b) {
+			return true
+		}
+		if moreUrgent(b, a) || less == nil {
+			return false
+		}
+		return less(a, b)
+	})
+}
+
+// markCompletedOrders records on every sheet of the layout the orders whose
+// last pieces are cut from it. An order with pieces left unplaced is never
+// completed.
+func markCompletedOrders(layout *models.Layout, unplaced []PieceToPlace) {
+	open := make(map[string]bool)
+	for _, piece := range unplaced {
+		open[piece.Order] = true
+		for _, inner := range piece.Inner {
+			open[inner.piece.Order] = true
+		}
+	}
+
+	last := make(map[string]int)
+	var orders []string
+	for i, sheet := range layout.Sheets {
+		for _, piece := range sheet.Pieces {
+			if piece.Order == "" || open[piece.Order] {
+				continue
+			}
+			if _, seen := last[piece.Order]; !seen {
+				orders = append(orders, piece.Order)
+			}
+			last[piece.Order] = i
+		}
+	}
+
+	for _, order := range orders {
+		sheet := &layout.Sheets[last[order]]
+		sheet.CompletedOrders = append(sheet.CompletedOrders, order)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestUrgentOrdersGoOnTheFirstSheets(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	// Six panes fit on a sheet; the least urgent order is listed first
+	designs := []models.DesignItem{
+		{DesignID: 0, Name: "Stock", Width: 900, Height: 900, Quantity: 10, Order: "B"},
+		{DesignID: 0, Name: "Rush", Width: 900, Height: 900, Quantity: 4, DueDate: "2026-10-27", Order: "C"},
+		{DesignID: 0, Name: "Due", Width: 900, Height: 900, Quantity: 4, DueDate: "2026-10-20", Order: "A"},
+	}
+	want := [][]string{{"A"}, {"C"}, {"B"}}
+
+	algorithms := []string{"blf", "genetic", "greedy", "guillotine", "maxrects-bssf", "skyline", "nfp"}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+				Name:      "Orders " + algorithm,
+				SheetID:   1, // Standard 2m x 3m
+				Algorithm: algorithm,
+				Designs:   designs,
+				Options:   models.OptimizeOptions{AllowRotation: true, MaxIterations: 5, PopulationSize: 6},
+			}, userID)
+			if err != nil {
+				t.Fatalf("RunOptimization() error = %v", err)
+			}
+			if got := len(optimization.Layout.AllPieces()); got != 18 {
+				t.Fatalf("Placed pieces incorrect: got %d, want %d", got, 18)
+			}
+			if len(optimization.Layout.Sheets) != len(want) {
+				t.Fatalf("Sheets incorrect: got %d, want %d", len(optimization.Layout.Sheets), len(want))
+			}
+			assertLegalLayout(t, &optimization.Layout)
+
+			for i, sheet := range optimization.Layout.Sheets {
+				if !reflect.DeepEqual(sheet.CompletedOrders, want[i]) {
+					t.Errorf("Sheet %d completed orders incorrect: got %v, want %v", sheet.SheetNumber, sheet.CompletedOrders, want[i])
+				}
+			}
+			if first := optimization.Layout.Sheets[0]; len(first.Pieces) != 6 {
+				t.Errorf("First sheet pieces incorrect: got %d, want %d", len(first.Pieces), 6)
+			} else {
+				for _, piece := range first.Pieces {
+					if piece.Order == "B" {
+						t.Errorf("Piece %s of order B placed on the first sheet", piece.ID)
+					}
+				}
+			}
+
+			if algorithm != "blf" {
+				return
+			}
+			export, err := service.ExportOptimization(optimization.ID, userID, "cutting_list")
+			if err != nil {
+				t.Fatalf("ExportOptimization() error = %v", err)
+			}
+			if list := export.Data.(string); !strings.Contains(list, "Orders Completed:\nSheet\tOrders\n1\tA\n2\tC\n3\tB\n") {
+				t.Errorf("Cutting list does not list completed orders:\n%s", list)
+			}
+		})
+	}
+
+	// Priority only leads while sort_by puts it first
+	prioritized := []models.DesignItem{
+		{DesignID: 0, Name: "Stock", Width: 950, Height: 950, Quantity: 6, Order: "B"},
+		{DesignID: 0, Name: "Rush", Width: 900, Height: 900, Quantity: 6, Priority: 5, Order: "C"},
+	}
+	for sortBy, want := range map[string]string{"": "C", "area": "B", "area,priority": "B", "priority,area": "C"} {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name: "Priority " + sortBy, SheetID: 1, Algorithm: "blf", Designs: prioritized,
+			Options: models.OptimizeOptions{AllowRotation: true, SortBy: sortBy},
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%q) error = %v", sortBy, err)
+		}
+		if first := optimization.Layout.Sheets[0]; !reflect.DeepEqual(first.CompletedOrders, []string{want}) {
+			t.Errorf("First sheet orders sorted by %q incorrect: got %v, want [%s]", sortBy, first.CompletedOrders, want)
+		}
+	}
+
+	// An order with a piece left over is not completed
+	optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Oversized", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{
+			{DesignID: 0, Name: "Pane", Width: 900, Height: 900, Quantity: 2, Order: "D"},
+			{DesignID: 0, Name: "Wall", Width: 2500, Height: 3500, Quantity: 1, Order: "D"},
+		},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	for _, sheet := range optimization.Layout.Sheets {
+		if len(sheet.CompletedOrders) > 0 {
+			t.Errorf("Sheet %d completes %v with a piece of order D unplaced", sheet.SheetNumber, sheet.CompletedOrders)
+		}
+	}
+
+	req := &models.OptimizationRequest{Name: "Bad date", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 400, Height: 300, Quantity: 1, DueDate: "20/10/2026"}}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Invalid due date: got %v, want validation error", err)
+	}
+}
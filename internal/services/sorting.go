@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"glass-optimizer/internal/models"
+)
+
+// sortAll is the sort_by value that tries every ordering of sortStrategies
+const sortAll = "all"
+
+// sortKeys are the measures options.sort_by may order pieces by. Several keys
+// separated by commas compare pieces by the first key and break ties with
+// the next.
+var sortKeys = map[string]func(p *PieceToPlace) float64{
+	"area":      func(p *PieceToPlace) float64 { return p.Width * p.Height },
+	"perimeter": func(p *PieceToPlace) float64 { return 2 * (p.Width + p.Height) },
+	"ratio":     func(p *PieceToPlace) float64 { return math.Max(p.Width, p.Height) / math.Min(p.Width, p.Height) },
+	"max_side":  func(p *PieceToPlace) float64 { return math.Max(p.Width, p.Height) },
+	"width":     func(p *PieceToPlace) float64 { return p.Width },
+	"height":    func(p *PieceToPlace) float64 { return p.Height },
+	"priority":  func(p *PieceToPlace) float64 { return float64(p.Priority) },
+}
+
+// defaultSortBy orders pieces when options.sort_by names no key: higher
+// priority first, then larger area
+const defaultSortBy = "priority,area"
+
+// sortStrategies are the orderings sort_by "all" lays pieces out in. Each
+// keeps pieces of higher priority first, as the default ordering does.
+var sortStrategies = []string{"priority,area", "priority,perimeter", "priority,max_side", "priority,ratio", "priority,width,height", "priority,height,width"}
+
+// validSortBy reports whether sort_by names known keys, or asks for all
+// orderings
+func validSortBy(sortBy string) bool {
+	if sortBy == "" || sortBy == sortAll {
+		return true
+	}
+	for _, name := range strings.Split(sortBy, ",") {
+		if _, ok := sortKeys[strings.TrimSpace(name)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sortPieces orders pieces of the same urgency by the keys of sortBy, by
+// defaultSortBy when none is given. Larger values come first unless
+// sortOrder is "asc"; higher priority always comes first. Priority is a key
+// like any other, so a sortBy that leaves it out ignores it.
+func (s *OptimizerService) sortPieces(pieces []PieceToPlace, sortBy, sortOrder string) {
+	if sortBy == "" {
+		sortBy = defaultSortBy
+	}
+
+	type sortKey struct {
+		value     func(p *PieceToPlace) float64
+		ascending bool
+	}
+	var keys []sortKey
+	for _, name := range strings.Split(sortBy, ",") {
+		name = strings.TrimSpace(name)
+		if value, ok := sortKeys[name]; ok {
+			keys = append(keys, sortKey{value: value, ascending: sortOrder == "asc" && name != "priority"})
+		}
+	}
+
+	sequencePieces(pieces, func(a, b *PieceToPlace) bool {
+		for _, key := range keys {
+			if valueA, valueB := key.value(a), key.value(b); valueA != valueB {
+				return (valueA < valueB) == key.ascending
+			}
+		}
+		return false
+	})
+}
+
+// packOrdered lays out the pieces with the given packer in the order
+// options.sort_by asks for
+func (s *OptimizerService) packOrdered(ctx context.Context, stock *sheetStock, pieces []PieceToPlace, options *models.OptimizeOptions, pack sheetPacker) *models.Layout {
+	return s.orderedLayout(ctx, pieces, options, func(ctx context.Context, pieces []PieceToPlace) *models.Layout {
+		return s.packSheets(ctx, stock, pieces, options, pack)
+	})
+}
+
+// orderedLayout sorts the pieces as options.sort_by asks and lays them out.
+// With sort_by "all" the pieces are laid out in every ordering of
+// sortStrategies and the best layout is kept. Orderings left untried when ctx
+// is done are skipped.
+func (s *OptimizerService) orderedLayout(ctx context.Context, pieces []PieceToPlace, options *models.OptimizeOptions, layout func(ctx context.Context, pieces []PieceToPlace) *models.Layout) *models.Layout {
+	if options.SortBy != sortAll {
+		s.sortPieces(pieces, options.SortBy, options.SortOrder)
+		return layout(ctx, pieces)
+	}
+
+	// Progress is only reported for the best layout so far
+	quietCtx := WithProgress(ctx, nil)
+
+	var best *models.Layout
+	bestSortBy := ""
+	for _, sortBy := range sortStrategies {
+		if best != nil && ctx.Err() != nil {
+			break
+		}
+
+		ordered := make([]PieceToPlace, len(pieces))
+		copy(ordered, pieces)
+		s.sortPieces(ordered, sortBy, options.SortOrder)
+
+		candidate := layout(quietCtx, ordered)
+		if best == nil || betterLayout(candidate, best) {
+			best, bestSortBy = candidate, sortBy
+		}
+		reportProgress(ctx, layoutProgress(best))
+	}
+
+	s.logger.Debug("Kept best piece ordering", "sort_by", bestSortBy)
+	return best
+}
+
+// betterLayout reports whether layout a beats layout b: it places more
+// pieces, or as many for less material cost, or on fewer sheets. Between
+// otherwise equal layouts, the one leaving its last sheet emptier is better,
+// since that sheet is the easiest to save or keep as a remnant.
+func betterLayout(a, b *models.Layout) bool {
+	if placedA, placedB := len(a.AllPieces()), len(b.AllPieces()); placedA != placedB {
+		return placedA > placedB
+	}
+	if costA, costB := layoutCost(a), layoutCost(b); math.Abs(costA-costB) > 1e-9 {
+		return costA < costB
+	}
+	if len(a.Sheets) != len(b.Sheets) {
+		return len(a.Sheets) < len(b.Sheets)
+	}
+	if len(a.Sheets) == 0 {
+		return false
+	}
+	return a.Sheets[len(a.Sheets)-1].UsedArea < b.Sheets[len(b.Sheets)-1].UsedArea
+}
+
+// layoutCost returns the material cost of the sheets of a layout
+func layoutCost(layout *models.Layout) float64 {
+	cost := 0.0
+	for _, sheet := range layout.Sheets {
+		cost += sheet.Cost
+	}
+	return cost
+}
+
+// layoutProgress describes a finished layout as the best one so far
+func layoutProgress(layout *models.Layout) models.JobProgress {
+	progress := models.JobProgress{SheetsUsed: len(layout.Sheets), PiecesPlaced: len(layout.AllPieces())}
+
+	usedArea, sheetArea := 0.0, 0.0
+	for _, sheet := range layout.Sheets {
+		usedArea += sheet.UsedArea
+		sheetArea += sheet.Width * sheet.Height
+	}
+	if sheetArea > 0 {
+		progress.BestUtilization = usedArea / sheetArea * 100
+	}
+
+	return progress
+}
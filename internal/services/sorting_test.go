@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestSortPiecesByEveryKey(t *testing.T) {
+	service, _, _ := newTestOptimizer(t)
+
+	pieces := []PieceToPlace{
+		{Name: "A", Width: 100, Height: 400},
+		{Name: "B", Width: 300, Height: 300},
+		{Name: "C", Width: 200, Height: 250},
+		{Name: "D", Width: 150, Height: 600},
+	}
+
+	tests := []struct {
+		sortBy    string
+		sortOrder string
+		want      []string
+	}{
+		{"", "", []string{"B", "D", "C", "A"}},
+		{"area", "desc", []string{"B", "D", "C", "A"}},
+		{"area,perimeter", "desc", []string{"D", "B", "C", "A"}},
+		{"perimeter", "asc", []string{"C", "A", "B", "D"}},
+		{"ratio", "desc", []string{"A", "D", "C", "B"}},
+		{"max_side", "desc", []string{"D", "A", "B", "C"}},
+		{"width", "asc", []string{"A", "D", "C", "B"}},
+		{"height, width", "desc", []string{"D", "A", "B", "C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy+" "+tt.sortOrder, func(t *testing.T) {
+			sorted := append([]PieceToPlace(nil), pieces...)
+			service.sortPieces(sorted, tt.sortBy, tt.sortOrder)
+
+			var got []string
+			for _, piece := range sorted {
+				got = append(got, piece.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order incorrect: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Priority is a key like the others: it leads by default and whenever
+	// sort_by puts it first, always higher priority first, and is ignored
+	// when sort_by leaves it out
+	prioritized := append([]PieceToPlace(nil), pieces...)
+	prioritized[0].Priority = 1
+	priorityTests := []struct {
+		sortBy    string
+		sortOrder string
+		want      []string
+	}{
+		{"", "", []string{"A", "B", "D", "C"}},
+		{"priority,area", "asc", []string{"A", "C", "B", "D"}},
+		{"area", "desc", []string{"B", "D", "C", "A"}},
+		{"area,priority", "desc", []string{"B", "D", "C", "A"}},
+		{"all", "desc", []string{"A", "B", "D", "C"}},
+	}
+	for _, tt := range priorityTests {
+		sorted := append([]PieceToPlace(nil), prioritized...)
+		sortBy := tt.sortBy
+		if sortBy == sortAll {
+			sortBy = sortStrategies[0]
+		}
+		service.sortPieces(sorted, sortBy, tt.sortOrder)
+
+		var got []string
+		for _, piece := range sorted {
+			got = append(got, piece.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Order with priority by %q %s incorrect: got %v, want %v", tt.sortBy, tt.sortOrder, got, tt.want)
+		}
+	}
+}
+
+func TestSortByAllKeepsTheBestOrdering(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	designs := []models.DesignItem{
+		{DesignID: 0, Name: "Door", Width: 800, Height: 2100, Quantity: 3},
+		{DesignID: 0, Name: "Strip", Width: 150, Height: 1900, Quantity: 5},
+		{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 9},
+		{DesignID: 0, Name: "Light", Width: 350, Height: 350, Quantity: 12},
+	}
+	run := func(algorithm, sortBy string) *models.Optimization {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name:      "Sort " + algorithm + " " + sortBy,
+			SheetID:   1, // Standard 2m x 3m
+			Algorithm: algorithm,
+			Designs:   designs,
+			Options:   models.OptimizeOptions{AllowRotation: true, SortBy: sortBy, SortOrder: "desc"},
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s, %s) error = %v", algorithm, sortBy, err)
+		}
+		assertLegalLayout(t, &optimization.Layout)
+		return optimization
+	}
+
+	for _, algorithm := range []string{"blf", "greedy", "guillotine", "maxrects-bssf", "skyline"} {
+		t.Run(algorithm, func(t *testing.T) {
+			best := run(algorithm, "all")
+			for _, sortBy := range sortStrategies {
+				single := run(algorithm, sortBy)
+				if betterLayout(&single.Layout, &best.Layout) {
+					t.Errorf("Ordering %s beats sort_by all: %d sheets, %d pieces vs %d sheets, %d pieces", sortBy,
+						len(single.Layout.Sheets), len(single.Layout.AllPieces()), len(best.Layout.Sheets), len(best.Layout.AllPieces()))
+				}
+			}
+		})
+	}
+
+	req := &models.OptimizationRequest{Name: "Bad sort", SheetID: 1, Algorithm: "blf", Designs: designs,
+		Options: models.OptimizeOptions{SortBy: "area,volume"}}
+	if _, err := service.RunOptimization(context.Background(), req, userID); !models.IsValidationError(err) {
+		t.Errorf("Unknown sort key: got %v, want validation error", err)
+	}
+}
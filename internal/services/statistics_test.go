@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestLayoutStatistics(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	sheet, err := store.GetGlassSheet(1)
+	if err != nil {
+		t.Fatalf("GetGlassSheet() error = %v", err)
+	}
+
+	// A single piece in the corner leaves the rest of the usable area, past
+	// the 2mm gap around the piece
+	single, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Single", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 1000, Height: 1000, Quantity: 1}},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	stats := single.Layout.Statistics
+	if want := (2995.0 - 1007) * (1995 - 5); math.Abs(stats.LargestWasteArea-want) > 1e-6 {
+		t.Errorf("Largest waste area incorrect: got %.0f, want %.0f", stats.LargestWasteArea, want)
+	}
+	if stats.SmallestGap != 0 {
+		t.Errorf("Smallest gap of a single piece incorrect: got %.1f, want %.1f", stats.SmallestGap, 0.0)
+	}
+	if math.Abs(single.TotalCost-sheet.TotalCost()) > 1e-6 {
+		t.Errorf("Total cost incorrect: got %.2f, want %.2f", single.TotalCost, sheet.TotalCost())
+	}
+
+	// Pieces are counted by quantity, and packed as close as the gap plus kerf
+	for _, options := range []models.OptimizeOptions{{}, {MinimumGap: 4, Kerf: 3}} {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name: "Panes", SheetID: 1, Algorithm: "blf",
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 14},
+				{DesignID: 0, Name: "Door", Width: 800, Height: 2100, Quantity: 3},
+			},
+			Options: options,
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization() error = %v", err)
+		}
+		stats := optimization.Layout.Statistics
+		if stats.TotalPieces != 17 || stats.PlacedPieces != 17 || stats.UnplacedPieces != 0 {
+			t.Errorf("Pieces incorrect: got %d total, %d placed, %d unplaced, want 17, 17, 0",
+				stats.TotalPieces, stats.PlacedPieces, stats.UnplacedPieces)
+		}
+		want := 2.0
+		if options.MinimumGap > 0 {
+			want = options.MinimumGap + options.Kerf
+		}
+		if math.Abs(stats.SmallestGap-want) > 1e-6 {
+			t.Errorf("Smallest gap incorrect: got %.2f, want %.2f", stats.SmallestGap, want)
+		}
+		if want := sheet.TotalCost() * float64(stats.SheetsUsed); math.Abs(optimization.TotalCost-want) > 1e-6 {
+			t.Errorf("Total cost incorrect: got %.2f, want %.2f", optimization.TotalCost, want)
+		}
+
+		list, err := service.ExportOptimization(optimization.ID, userID, "cutting_list")
+		if err != nil {
+			t.Fatalf("ExportOptimization() error = %v", err)
+		}
+		for _, line := range []string{"Total Pieces: 17", "Material Cost: ", "Largest Waste Area: ", "Smallest Gap Between Pieces: "} {
+			if !strings.Contains(list.Data.(string), line) {
+				t.Errorf("Cutting list misses %q", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+
+	"glass-optimizer/internal/models"
+)
+
+// loadSheetStock collects the glass a run may cut from: the available
+// remnants of the sheet's material and thickness, unless the request ignores
+// them, followed by full sheets
+func (s *OptimizerService) loadSheetStock(sheet *models.GlassSheet, req *models.OptimizationRequest, options *models.OptimizeOptions) (*sheetStock, error) {
+	sheets, err := s.loadStockSheets(sheet, req)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := &sheetStock{sheet: sheet, sheets: sheets}
+	if options.IgnoreRemnants {
+		return stock, nil
+	}
+
+	remnants, err := s.storage.GetAvailableRemnants(sheet.Material, sheet.Thickness)
+	if err != nil {
+		return nil, err
+	}
+	stock.remnants = remnants
+
+	return stock, nil
+}
+
+// loadStockSheets returns the full sheet sizes a run may open. A plain
+// request cuts as many sheets of its sheet as it needs. A request naming
+// candidate sheets, or asking for every matching sheet, chooses among the
+// in-stock sheets of the same material and thickness and opens no more of
+// each than are in stock.
+func (s *OptimizerService) loadStockSheets(sheet *models.GlassSheet, req *models.OptimizationRequest) ([]stockSheet, error) {
+	if len(req.SheetIDs) == 0 && !req.MatchingSheets {
+		return []stockSheet{{sheet: sheet}}, nil
+	}
+
+	candidates := []models.GlassSheet{*sheet}
+	for _, id := range req.SheetIDs {
+		candidate, err := s.storage.GetGlassSheet(id)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, *candidate)
+	}
+	if req.MatchingSheets {
+		matching, err := s.storage.GetInStockGlassSheets(sheet.Material, sheet.Thickness)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range matching {
+			// Pieces are turned to suit the pattern of the request sheet
+			if candidate.Specs.Pattern == sheet.Specs.Pattern {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	var sheets []stockSheet
+	seen := make(map[int]bool)
+	for i := range candidates {
+		candidate := &candidates[i]
+		if seen[candidate.ID] {
+			continue
+		}
+		seen[candidate.ID] = true
+
+		if candidate.Material != sheet.Material || candidate.Thickness != sheet.Thickness {
+			return nil, models.NewValidationError(fmt.Sprintf(
+				"sheet %d is %.1fmm %s glass; candidate sheets must match sheet %d (%.1fmm %s)",
+				candidate.ID, candidate.Thickness, candidate.Material, sheet.ID, sheet.Thickness, sheet.Material))
+		}
+		if candidate.Specs.Pattern != sheet.Specs.Pattern {
+			return nil, models.NewValidationError(fmt.Sprintf(
+				"sheet %d has a different pattern direction from sheet %d", candidate.ID, sheet.ID))
+		}
+		if candidate.InStock <= 0 {
+			continue
+		}
+
+		sheets = append(sheets, stockSheet{sheet: candidate, limit: candidate.InStock})
+	}
+
+	if len(sheets) == 0 {
+		return nil, models.NewValidationError("none of the candidate sheets are in stock")
+	}
+
+	return sheets, nil
+}
+
+// requestSheetID returns the sheet a request is cut from: sheet_id, or the
+// first candidate when only candidates are given
+func requestSheetID(req *models.OptimizationRequest) int {
+	if req.SheetID == 0 && len(req.SheetIDs) > 0 {
+		return req.SheetIDs[0]
+	}
+	return req.SheetID
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+	"glass-optimizer/internal/storage"
+)
+
+func TestMixedSheetSizesChooseCheapestStock(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	// Seeded catalogue: Standard 2m x 3m (id 1, 15 in stock) and Large
+	// 2.5m x 3.5m (id 2, 8 in stock), both 6mm clear
+	small := addTestSheet(t, store, "Small 1m x 1.2m", 1000, 1200, 2)
+	tinted := &models.GlassSheet{Name: "Tinted", Width: 2000, Height: 3000, Thickness: 6, PricePerSqm: 60, InStock: 5, Material: "tinted"}
+	if err := store.CreateGlassSheet(tinted); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+
+	request := func(sheetIDs []int, matching bool) *models.OptimizationRequest {
+		return &models.OptimizationRequest{
+			Name:           "Mixed",
+			SheetID:        1,
+			SheetIDs:       sheetIDs,
+			MatchingSheets: matching,
+			Algorithm:      "blf",
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Door", Width: 900, Height: 2100, Quantity: 2},
+				{DesignID: 0, Name: "Panel", Width: 900, Height: 900, Quantity: 2},
+			},
+		}
+	}
+
+	single, err := service.RunOptimization(context.Background(), request(nil, false), userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+
+	// Doors on a standard sheet, one panel on each small sheet
+	mixed, err := service.RunOptimization(context.Background(), request(nil, true), userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	if placed := len(mixed.Layout.AllPieces()); placed != 4 {
+		t.Fatalf("Placed pieces: got %d, want 4", placed)
+	}
+	if mixed.TotalCost >= single.TotalCost {
+		t.Errorf("Mixed sizes cost %.2f, want less than %.2f on standard sheets only", mixed.TotalCost, single.TotalCost)
+	}
+
+	used := make(map[int]int)
+	total := 0.0
+	for _, sheet := range mixed.Layout.Sheets {
+		used[sheet.SheetID]++
+		total += sheet.Cost
+	}
+	if used[1] != 1 || used[small.ID] != 2 {
+		t.Errorf("Sheets used: got %v, want one standard and two small", used)
+	}
+	if math.Abs(total-mixed.TotalCost) > 1e-6 {
+		t.Errorf("Sheet costs add up to %.2f, total cost is %.2f", total, mixed.TotalCost)
+	}
+
+	// With one small sheet in stock, a single large sheet is cheapest
+	small.InStock = 1
+	if err := store.UpdateGlassSheet(small); err != nil {
+		t.Fatalf("UpdateGlassSheet() error = %v", err)
+	}
+	limited, err := service.RunOptimization(context.Background(), request([]int{2, small.ID}, false), userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() error = %v", err)
+	}
+	if len(limited.Layout.Sheets) != 1 || limited.Layout.Sheets[0].SheetID != 2 {
+		t.Errorf("Expected a single large sheet, got %d sheets starting with sheet %d",
+			len(limited.Layout.Sheets), limited.Layout.Sheets[0].SheetID)
+	}
+
+	// A rerun chooses from the same candidate sheets
+	stored, err := store.GetOptimization(limited.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	if len(stored.SheetIDs) != 2 || stored.SheetIDs[0] != 2 || stored.SheetIDs[1] != small.ID || stored.MatchingSheets {
+		t.Errorf("Stored candidate sheets: got %v (matching %v), want [2 %d]", stored.SheetIDs, stored.MatchingSheets, small.ID)
+	}
+	rerun, err := service.RunOptimization(context.Background(), service.RerunRequest(stored), userID)
+	if err != nil {
+		t.Fatalf("RunOptimization() rerun error = %v", err)
+	}
+	if len(rerun.Layout.Sheets) != 1 || rerun.Layout.Sheets[0].SheetID != 2 {
+		t.Errorf("Rerun: expected a single large sheet, got %d sheets starting with sheet %d",
+			len(rerun.Layout.Sheets), rerun.Layout.Sheets[0].SheetID)
+	}
+	stored, err = store.GetOptimization(mixed.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	if len(stored.SheetIDs) != 0 || !stored.MatchingSheets {
+		t.Errorf("Stored candidate sheets: got %v (matching %v), want matching sheets", stored.SheetIDs, stored.MatchingSheets)
+	}
+
+	// The cutting list names the stock sheet to load for each sheet
+	list, err := service.ExportOptimization(mixed.ID, userID, "cutting_list")
+	if err != nil {
+		t.Fatalf("ExportOptimization() error = %v", err)
+	}
+	for _, sheet := range mixed.Layout.Sheets {
+		want := fmt.Sprintf("-- Sheet %d: %.0f x %.0fmm, stock sheet %d\n", sheet.SheetNumber, sheet.Width, sheet.Height, sheet.SheetID)
+		if !strings.Contains(list.Data.(string), want) {
+			t.Errorf("Cutting list misses %q", want)
+		}
+	}
+
+	// The SVG canvas fits every sheet, whatever size the request sheet is
+	for _, tt := range []struct {
+		optimization *models.Optimization
+		want         string
+	}{
+		{mixed, `<svg width="200.00" height="580.00"`},
+		{limited, `<svg width="250.00" height="350.00"`},
+	} {
+		svg, err := service.ExportOptimization(tt.optimization.ID, userID, "svg")
+		if err != nil {
+			t.Fatalf("ExportOptimization() error = %v", err)
+		}
+		if !strings.Contains(svg.Data.(string), tt.want) {
+			t.Errorf("SVG canvas incorrect: got %.80s, want %s", strings.SplitN(svg.Data.(string), "\n", 2)[1], tt.want)
+		}
+	}
+
+	if _, err := service.RunOptimization(context.Background(), request([]int{tinted.ID}, false), userID); !models.IsValidationError(err) {
+		t.Errorf("Mixing glass types: got %v, want validation error", err)
+	}
+}
+
+func addTestSheet(t *testing.T, store storage.Storage, name string, width, height float64, inStock int) *models.GlassSheet {
+	t.Helper()
+
+	sheet := &models.GlassSheet{Name: name, Width: width, Height: height, Thickness: 6, PricePerSqm: 45.50, InStock: inStock, Material: "clear"}
+	if err := store.CreateGlassSheet(sheet); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+	return sheet
+}
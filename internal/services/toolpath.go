@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"glass-optimizer/internal/models"
+)
+
+// maxToolPathPasses bounds the 2-opt passes over the cuts of a sheet
+const maxToolPathPasses = 50
+
+// toolPathUnit is a run of cuts the head makes without lifting: a single
+// straight cut, or the edges of an outline that join end to start. A unit can
+// be cut either way round.
+type toolPathUnit struct {
+	cuts       []models.CutPath
+	start, end models.Point
+	after      []int // Units that must be cut first
+}
+
+// toolPathStep is a unit in the tool path and whether it is cut backwards
+type toolPathStep struct {
+	unit     int
+	reversed bool
+}
+
+// orderToolPath reorders the cuts of a sheet to keep the travel of the
+// cutting head between cuts short, and numbers them in the new order. The
+// cuts before first, those of nested pieces, are kept ahead of the rest.
+// Each cut that runs to an earlier cut still comes after it, so the glass is
+// broken in a valid sequence; within that, the cuts are ordered nearest
+// first from the corner of the sheet, then improved by reversing runs of
+// cuts, each cut then going the other way, while that shortens the travel.
+// Cuts that join end to start, such as the edges of an outline, stay
+// together.
+func orderToolPath(paths []models.CutPath, first int) []models.CutPath {
+	ordered := make([]models.CutPath, 0, len(paths))
+	head := models.Point{}
+	for _, block := range [][]models.CutPath{paths[:first], paths[first:]} {
+		units := toolPathUnits(block)
+
+		// The planned sequence is improved too, so the result never travels
+		// further than it
+		planned := make([]toolPathStep, len(units))
+		for i := range planned {
+			planned[i] = toolPathStep{unit: i}
+		}
+		steps := improveToolPath(units, nearestToolPath(units, head), head)
+		if planned = improveToolPath(units, planned, head); stepsTravel(units, planned, head) < stepsTravel(units, steps, head) {
+			steps = planned
+		}
+
+		for _, step := range steps {
+			unit := units[step.unit]
+			cuts := unit.cuts
+			if step.reversed {
+				cuts = make([]models.CutPath, len(unit.cuts))
+				for i, cut := range unit.cuts {
+					cut.StartX, cut.StartY, cut.EndX, cut.EndY = cut.EndX, cut.EndY, cut.StartX, cut.StartY
+					cuts[len(cuts)-1-i] = cut
+				}
+			}
+			ordered = append(ordered, cuts...)
+		}
+		if n := len(ordered); n > 0 {
+			head = models.Point{X: ordered[n-1].EndX, Y: ordered[n-1].EndY}
+		}
+	}
+
+	for i := range ordered {
+		ordered[i].Order = i + 1
+		ordered[i].ID = fmt.Sprintf("%s_%d", ordered[i].ID[:strings.LastIndex(ordered[i].ID, "_")], i+1)
+	}
+	return ordered
+}
+
+// toolPathUnits groups the cuts into units and finds which units each must
+// follow: a straight cut ending on an earlier straight cut, other than a
+// score, is made after it
+func toolPathUnits(paths []models.CutPath) []toolPathUnit {
+	var units []toolPathUnit
+	unitOf := make([]int, len(paths))
+	for i, cut := range paths {
+		start := models.Point{X: cut.StartX, Y: cut.StartY}
+		end := models.Point{X: cut.EndX, Y: cut.EndY}
+
+		if n := len(units); n > 0 && cut.Type == "curve" {
+			last := &units[n-1]
+			previous := last.cuts[len(last.cuts)-1]
+			if previous.Type == "curve" && slices.Equal(previous.Pieces, cut.Pieces) &&
+				math.Hypot(last.end.X-start.X, last.end.Y-start.Y) <= guillotineEpsilon {
+				last.cuts = append(last.cuts, cut)
+				last.end = end
+				unitOf[i] = n - 1
+				continue
+			}
+		}
+		units = append(units, toolPathUnit{cuts: []models.CutPath{cut}, start: start, end: end})
+		unitOf[i] = len(units) - 1
+	}
+
+	for i, cut := range paths {
+		if cut.Type == "curve" || strings.HasPrefix(cut.ID, "cut_score") {
+			continue
+		}
+		for _, point := range []models.Point{{X: cut.StartX, Y: cut.StartY}, {X: cut.EndX, Y: cut.EndY}} {
+			for j, earlier := range paths[:i] {
+				if earlier.Type == "curve" || unitOf[j] == unitOf[i] {
+					continue
+				}
+				start := models.Point{X: earlier.StartX, Y: earlier.StartY}
+				end := models.Point{X: earlier.EndX, Y: earlier.EndY}
+				if pointSegmentDistance(point, start, end) <= guillotineEpsilon {
+					units[unitOf[i]].after = append(units[unitOf[i]].after, unitOf[j])
+				}
+			}
+		}
+	}
+	return units
+}
+
+// stepEnds returns where the head starts and ends a step
+func stepEnds(units []toolPathUnit, step toolPathStep) (models.Point, models.Point) {
+	unit := units[step.unit]
+	if step.reversed {
+		return unit.end, unit.start
+	}
+	return unit.start, unit.end
+}
+
+// travel returns the distance between two points
+func travel(a, b models.Point) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// stepsTravel returns how far the head moves between the steps
+func stepsTravel(units []toolPathUnit, steps []toolPathStep, head models.Point) float64 {
+	distance := 0.0
+	for _, step := range steps {
+		start, end := stepEnds(units, step)
+		distance += travel(head, start)
+		head = end
+	}
+	return distance
+}
+
+// nearestToolPath orders the units by moving the head to the nearest end of
+// a unit whose preceding units are all cut
+func nearestToolPath(units []toolPathUnit, head models.Point) []toolPathStep {
+	done := make([]bool, len(units))
+	steps := make([]toolPathStep, 0, len(units))
+	for len(steps) < len(units) {
+		best, bestDistance := toolPathStep{unit: -1}, math.Inf(1)
+		for i, unit := range units {
+			if done[i] || !allDone(unit.after, done) {
+				continue
+			}
+			if d := travel(head, unit.start); d < bestDistance-guillotineEpsilon {
+				best, bestDistance = toolPathStep{unit: i}, d
+			}
+			if d := travel(head, unit.end); d < bestDistance-guillotineEpsilon {
+				best, bestDistance = toolPathStep{unit: i, reversed: true}, d
+			}
+		}
+		done[best.unit] = true
+		steps = append(steps, best)
+		_, head = stepEnds(units, best)
+	}
+	return steps
+}
+
+// allDone reports whether all the units are cut
+func allDone(units []int, done []bool) bool {
+	for _, unit := range units {
+		if !done[unit] {
+			return false
+		}
+	}
+	return true
+}
+
+// improveToolPath applies 2-opt moves: a run of steps is cut in reverse
+// order, each step the other way round, when that shortens the travel into
+// and out of the run. A run is only reversed when none of its units must
+// follow another unit of the run.
+func improveToolPath(units []toolPathUnit, steps []toolPathStep, home models.Point) []toolPathStep {
+	position := make([]int, len(units))
+	latest := make([]int, len(steps)) // Position of the last unit each step must follow
+	index := func() {
+		for i, step := range steps {
+			position[step.unit] = i
+		}
+		for i, step := range steps {
+			latest[i] = -1
+			for _, unit := range units[step.unit].after {
+				latest[i] = max(latest[i], position[unit])
+			}
+		}
+	}
+	index()
+
+	for pass := 0; pass < maxToolPathPasses; pass++ {
+		improved := false
+		for i := range steps {
+			before := home
+			if i > 0 {
+				_, before = stepEnds(units, steps[i-1])
+			}
+			first, _ := stepEnds(units, steps[i])
+
+			for j := i; j < len(steps) && latest[j] < i; j++ {
+				_, last := stepEnds(units, steps[j])
+				current := travel(before, first)
+				reversed := travel(before, last)
+				if j+1 < len(steps) {
+					next, _ := stepEnds(units, steps[j+1])
+					current += travel(last, next)
+					reversed += travel(first, next)
+				}
+				if reversed >= current-guillotineEpsilon {
+					continue
+				}
+
+				for a, b := i, j; a <= b; a, b = a+1, b-1 {
+					steps[a], steps[b] = steps[b], steps[a]
+					steps[a].reversed = !steps[a].reversed
+					if a != b {
+						steps[b].reversed = !steps[b].reversed
+					}
+				}
+				index()
+				improved = true
+				first, _ = stepEnds(units, steps[i])
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return steps
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestOrderToolPath(t *testing.T) {
+	// Scores across a panel, listed out of order: the head should work across
+	// them, flipping direction each time
+	var paths []models.CutPath
+	for i, x := range []float64{100, 500, 200, 400, 300} {
+		paths = append(paths, models.CutPath{
+			ID: fmt.Sprintf("cut_score_%d", i+1), Type: "vertical", StartX: x, StartY: 0, EndX: x, EndY: 1000, Order: i + 1,
+		})
+	}
+	if got := toolPathTravel(paths); got < 2000 {
+		t.Fatalf("Travel before ordering incorrect: got %.0f, want at least %.0f", got, 2000.0)
+	}
+
+	ordered := orderToolPath(paths, 0)
+	if got := toolPathTravel(ordered); math.Abs(got-500) > 1e-6 {
+		t.Errorf("Travel incorrect: got %.0f, want %.0f", got, 500.0)
+	}
+	for i, cut := range ordered {
+		if want := float64(100 * (i + 1)); cut.StartX != want {
+			t.Errorf("Cut %d incorrect: got x = %.0f, want %.0f", i+1, cut.StartX, want)
+		}
+		if up := i%2 == 0; up != (cut.StartY < cut.EndY) {
+			t.Errorf("Cut %d direction incorrect: got %.0f to %.0f", i+1, cut.StartY, cut.EndY)
+		}
+		if want := fmt.Sprintf("cut_score_%d", i+1); cut.ID != want || cut.Order != i+1 {
+			t.Errorf("Cut %d numbering incorrect: got %s order %d", i+1, cut.ID, cut.Order)
+		}
+	}
+
+	// A break resting on an earlier cut is still made after it, however near
+	// the head it starts
+	paths = []models.CutPath{
+		{ID: "cut_edge_1", Type: "horizontal", StartX: 0, StartY: 500, EndX: 1000, EndY: 500},
+		{ID: "cut_break_2", Type: "vertical", StartX: 10, StartY: 0, EndX: 10, EndY: 500},
+	}
+	ordered = orderToolPath(paths, 0)
+	if !strings.HasPrefix(ordered[0].ID, "cut_edge") {
+		t.Errorf("Break cut before the cut it rests on: got %+v", ordered)
+	}
+}
+
+func TestToolPathStatistics(t *testing.T) {
+	service, _, userID := newTestOptimizer(t)
+
+	for _, algorithm := range []string{"blf", "guillotine", "maxrects-bssf"} {
+		optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+			Name: "Travel " + algorithm, SheetID: 1, Algorithm: algorithm,
+			Designs: []models.DesignItem{
+				{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 14},
+				{DesignID: 0, Name: "Tile", Width: 300, Height: 350, Quantity: 10},
+			},
+		}, userID)
+		if err != nil {
+			t.Fatalf("RunOptimization(%s) error = %v", algorithm, err)
+		}
+		assertLegalLayout(t, &optimization.Layout)
+
+		stats := optimization.Layout.Statistics
+		travel := 0.0
+		for _, sheet := range optimization.Layout.Sheets {
+			travel += toolPathTravel(sheet.CutPaths)
+		}
+		if stats.TravelLength <= 0 || math.Abs(stats.TravelLength-travel) > 1e-3 {
+			t.Errorf("%s travel length incorrect: got %.1f, want %.1f", algorithm, stats.TravelLength, travel)
+		}
+		if stats.RapidMoves <= 0 || stats.RapidMoves > len(optimization.Layout.AllCutPaths()) {
+			t.Errorf("%s rapid moves incorrect: got %d", algorithm, stats.RapidMoves)
+		}
+		// Cutting runs at 100mm/min; the travel takes time on top
+		if cutting := stats.CuttingLength / 100; stats.CuttingTime <= cutting {
+			t.Errorf("%s cutting time incorrect: got %.1f, want more than %.1f", algorithm, stats.CuttingTime, cutting)
+		}
+
+		list, err := service.ExportOptimization(optimization.ID, userID, "cutting_list")
+		if err != nil {
+			t.Fatalf("ExportOptimization() error = %v", err)
+		}
+		if !strings.Contains(list.Data.(string), "Travel Length: ") {
+			t.Errorf("%s cutting list misses the travel length", algorithm)
+		}
+	}
+}
+
+// toolPathTravel returns how far the head moves between the cuts, starting
+// from the corner of the sheet
+func toolPathTravel(paths []models.CutPath) float64 {
+	distance, head := 0.0, models.Point{}
+	for _, cut := range paths {
+		distance += travel(head, models.Point{X: cut.StartX, Y: cut.StartY})
+		head = models.Point{X: cut.EndX, Y: cut.EndY}
+	}
+	return distance
+}
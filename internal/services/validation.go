@@ -0,0 +1,334 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"glass-optimizer/internal/models"
+)
+
+// validationEpsilon absorbs floating point error in layout coordinates (mm)
+const validationEpsilon = 1e-6
+
+// ValidateLayout checks that a layout can be cut as laid out. Every piece must
+// lie on its sheet outside the edge margin or trim, keep the minimum gap plus
+// kerf from the other pieces and from the defects of the sheet, and have a cut
+// path along each of its edges except those on the border of the usable area.
+// Shaped pieces are measured by their outline. Pieces cut from the drop-out of
+// a hole lie inside the piece around them and are not checked against it.
+func ValidateLayout(layout *models.Layout, options *models.OptimizeOptions) []models.Violation {
+	var violations []models.Violation
+	for _, sheet := range layoutSheets(layout) {
+		violations = append(violations, validateSheet(&sheet, options)...)
+	}
+	return violations
+}
+
+// smallestGap returns the smallest distance between two pieces on the same
+// sheet of the layout, or 0 when no sheet holds two pieces. Pieces cut from a
+// hole drop-out are not measured against the piece around them.
+func smallestGap(layout *models.Layout) float64 {
+	gap := math.Inf(1)
+	for _, sheet := range layoutSheets(layout) {
+		outlines, bounds, parents := sheetShapes(&sheet)
+		for i, a := range sheet.Pieces {
+			for j := i + 1; j < len(sheet.Pieces); j++ {
+				b := sheet.Pieces[j]
+				if nestedIn(a.ID, b.ID, parents) || nestedIn(b.ID, a.ID, parents) {
+					continue
+				}
+				distance, _ := shapeSeparation(outlines[i], bounds[i], outlines[j], bounds[j], gap)
+				gap = min(gap, distance)
+			}
+		}
+	}
+
+	if math.IsInf(gap, 1) {
+		return 0
+	}
+	return gap
+}
+
+// sheetShapes returns the outline and bounds of every piece on a sheet, and
+// the piece each piece cut from a hole drop-out lies in
+func sheetShapes(sheet *models.SheetLayout) ([][]models.Point, []Rectangle, map[string]string) {
+	outlines := make([][]models.Point, len(sheet.Pieces))
+	bounds := make([]Rectangle, len(sheet.Pieces))
+	parents := make(map[string]string)
+	for i, piece := range sheet.Pieces {
+		outlines[i] = pieceOutline(piece)
+		x, y := outlineOrigin(outlines[i])
+		right, top := outlineSize(outlines[i])
+		bounds[i] = Rectangle{X: x, Y: y, Width: right - x, Height: top - y}
+		if piece.ParentID != "" {
+			parents[piece.ID] = piece.ParentID
+		}
+	}
+	return outlines, bounds, parents
+}
+
+// validateSheet returns the violations of the pieces on one sheet
+func validateSheet(sheet *models.SheetLayout, options *models.OptimizeOptions) []models.Violation {
+	var violations []models.Violation
+	add := func(violation models.Violation) {
+		violation.Sheet = sheet.SheetNumber
+		violations = append(violations, violation)
+	}
+
+	usable := usableArea(&models.GlassSheet{Width: sheet.Width, Height: sheet.Height}, options)
+	spacing := pieceSpacing(options)
+
+	outlines, bounds, parents := sheetShapes(sheet)
+	for i, piece := range sheet.Pieces {
+		if past := distancePast(bounds[i], Rectangle{Width: sheet.Width, Height: sheet.Height}); past > validationEpsilon {
+			add(models.Violation{
+				Type:     models.ViolationOutOfBounds,
+				PieceID:  piece.ID,
+				Distance: past,
+				Message:  fmt.Sprintf("Piece %s extends %.1fmm past the sheet", piece.ID, past),
+			})
+		} else if past := distancePast(bounds[i], usable); past > validationEpsilon {
+			add(models.Violation{
+				Type:     models.ViolationEdgeMargin,
+				PieceID:  piece.ID,
+				Distance: past,
+				Message:  fmt.Sprintf("Piece %s extends %.1fmm into the edge margin", piece.ID, past),
+			})
+		}
+
+		for _, defect := range sheet.Defects {
+			area := Rectangle{X: defect.X, Y: defect.Y, Width: defect.Width, Height: defect.Height}
+			if distance, overlap := shapeSeparation(outlines[i], bounds[i], rectangleOutline(area), area, spacing); overlap || distance < spacing-validationEpsilon {
+				add(models.Violation{
+					Type:     models.ViolationDefect,
+					PieceID:  piece.ID,
+					Distance: distance,
+					Required: spacing,
+					Message:  fmt.Sprintf("Piece %s is %.1fmm from a defect, needs %.1fmm", piece.ID, distance, spacing),
+				})
+			}
+		}
+
+		if uncut := uncutLength(outlines[i], usable, sheet.CutPaths, spacing); uncut > validationEpsilon {
+			add(models.Violation{
+				Type:     models.ViolationUncut,
+				PieceID:  piece.ID,
+				Distance: uncut,
+				Message:  fmt.Sprintf("Piece %s has %.1fmm of edge without a cut path", piece.ID, uncut),
+			})
+		}
+	}
+
+	for i, a := range sheet.Pieces {
+		for j := i + 1; j < len(sheet.Pieces); j++ {
+			b := sheet.Pieces[j]
+			if nestedIn(a.ID, b.ID, parents) || nestedIn(b.ID, a.ID, parents) {
+				continue
+			}
+
+			distance, overlap := shapeSeparation(outlines[i], bounds[i], outlines[j], bounds[j], spacing)
+			switch {
+			case overlap:
+				add(models.Violation{
+					Type:    models.ViolationOverlap,
+					PieceID: a.ID,
+					OtherID: b.ID,
+					Message: fmt.Sprintf("Piece %s overlaps piece %s", a.ID, b.ID),
+				})
+			case distance < spacing-validationEpsilon:
+				add(models.Violation{
+					Type:     models.ViolationGap,
+					PieceID:  a.ID,
+					OtherID:  b.ID,
+					Distance: distance,
+					Required: spacing,
+					Message:  fmt.Sprintf("Pieces %s and %s are %.1fmm apart, need %.1fmm", a.ID, b.ID, distance, spacing),
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+// distancePast returns how far the rectangle extends past the area
+func distancePast(rect, area Rectangle) float64 {
+	return max(0,
+		area.X-rect.X,
+		area.Y-rect.Y,
+		rect.X+rect.Width-(area.X+area.Width),
+		rect.Y+rect.Height-(area.Y+area.Height),
+	)
+}
+
+// nestedIn reports whether the piece was cut from a hole of the host, or of a
+// piece cut from a hole of the host
+func nestedIn(id, host string, parents map[string]string) bool {
+	for parent, ok := parents[id]; ok; parent, ok = parents[parent] {
+		if parent == host {
+			return true
+		}
+	}
+	return false
+}
+
+// shapeSeparation returns the distance between two outlines with the given
+// bounds, and whether they overlap. Outlines whose bounds are further apart
+// than reach are not measured exactly.
+func shapeSeparation(a []models.Point, boundsA Rectangle, b []models.Point, boundsB Rectangle, reach float64) (float64, bool) {
+	dx := max(boundsB.X-(boundsA.X+boundsA.Width), boundsA.X-(boundsB.X+boundsB.Width))
+	dy := max(boundsB.Y-(boundsA.Y+boundsA.Height), boundsA.Y-(boundsB.Y+boundsB.Height))
+	boundsDistance := math.Hypot(max(dx, 0), max(dy, 0))
+
+	rectangles := len(a) == 4 && len(b) == 4 && math.Abs(polygonArea(a)) >= boundsA.Width*boundsA.Height-validationEpsilon &&
+		math.Abs(polygonArea(b)) >= boundsB.Width*boundsB.Height-validationEpsilon
+	if rectangles {
+		return boundsDistance, dx < -validationEpsilon && dy < -validationEpsilon
+	}
+	if boundsDistance > reach {
+		return boundsDistance, false
+	}
+
+	distance := math.Inf(1)
+	for _, ea := range outlineEdges(a) {
+		for _, eb := range outlineEdges(b) {
+			if segmentsCross(ea[0], ea[1], eb[0], eb[1]) {
+				return 0, true
+			}
+			distance = min(distance, segmentDistance(ea[0], ea[1], eb[0], eb[1]))
+		}
+	}
+	if outlineInside(a, b) || outlineInside(b, a) {
+		return 0, true
+	}
+	return distance, false
+}
+
+// segmentsCross reports whether segments ab and cd cross at a point inside
+// both. Segments that only touch or run along each other do not cross.
+func segmentsCross(a, b, c, d models.Point) bool {
+	side := func(p, q, r models.Point) int {
+		length := math.Hypot(q.X-p.X, q.Y-p.Y)
+		if length < validationEpsilon {
+			return 0
+		}
+		switch turn := cross(p, q, r) / length; {
+		case turn > validationEpsilon:
+			return 1
+		case turn < -validationEpsilon:
+			return -1
+		}
+		return 0
+	}
+	return side(a, b, c)*side(a, b, d) < 0 && side(c, d, a)*side(c, d, b) < 0
+}
+
+// segmentDistance returns the distance between segments ab and cd that do
+// not cross
+func segmentDistance(a, b, c, d models.Point) float64 {
+	return min(pointSegmentDistance(a, c, d), pointSegmentDistance(b, c, d),
+		pointSegmentDistance(c, a, b), pointSegmentDistance(d, a, b))
+}
+
+// pointSegmentDistance returns the distance from the point to segment ab
+func pointSegmentDistance(point, a, b models.Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, ((point.X-a.X)*dx+(point.Y-a.Y)*dy)/lengthSquared))
+	}
+	return math.Hypot(point.X-(a.X+t*dx), point.Y-(a.Y+t*dy))
+}
+
+// outlineInside reports whether part of the inner outline lies strictly
+// inside the outer one, given that their edges do not cross: one of its
+// corners does, or its centre when the two coincide
+func outlineInside(inner, outer []models.Point) bool {
+	var centre models.Point
+	for _, point := range inner {
+		if pointStrictlyInside(point, outer) {
+			return true
+		}
+		centre.X += point.X / float64(len(inner))
+		centre.Y += point.Y / float64(len(inner))
+	}
+	return pointStrictlyInside(centre, inner) && pointStrictlyInside(centre, outer)
+}
+
+// pointStrictlyInside reports whether the point lies inside the outline and
+// not on its boundary
+func pointStrictlyInside(point models.Point, outline []models.Point) bool {
+	inside := false
+	for _, edge := range outlineEdges(outline) {
+		a, b := edge[0], edge[1]
+		if pointSegmentDistance(point, a, b) <= validationEpsilon {
+			return false
+		}
+		if (a.Y > point.Y) != (b.Y > point.Y) && point.X < a.X+(point.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// uncutLength returns the total length of the outline edges no cut path runs
+// along. A cut runs along an edge when it is parallel to it on the outside,
+// no further away than the spacing: a guillotine cut between two strips
+// separates the pieces on both sides of the gap. Edges on the border of the
+// usable area need no cut.
+func uncutLength(outline []models.Point, usable Rectangle, cuts []models.CutPath, spacing float64) float64 {
+	near := func(u, v float64) bool { return math.Abs(u-v) <= validationEpsilon }
+	onBorder := func(a, b models.Point) bool {
+		return near(a.X, usable.X) && near(b.X, usable.X) ||
+			near(a.X, usable.X+usable.Width) && near(b.X, usable.X+usable.Width) ||
+			near(a.Y, usable.Y) && near(b.Y, usable.Y) ||
+			near(a.Y, usable.Y+usable.Height) && near(b.Y, usable.Y+usable.Height)
+	}
+
+	// Points outside the outline lie on this side of its edges
+	outside := -1.0
+	if polygonArea(outline) < 0 {
+		outside = 1
+	}
+
+	uncut := 0.0
+	for _, edge := range outlineEdges(outline) {
+		a, b := edge[0], edge[1]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if length < validationEpsilon || onBorder(a, b) {
+			continue
+		}
+
+		// Spans of the edge covered by cuts, as distances from a
+		var spans [][2]float64
+		for _, cut := range cuts {
+			c := models.Point{X: cut.StartX, Y: cut.StartY}
+			d := models.Point{X: cut.EndX, Y: cut.EndY}
+			offset := outside * cross(a, b, c) / length
+			if offset < -validationEpsilon || offset > spacing+validationEpsilon ||
+				math.Abs(outside*cross(a, b, d)/length-offset) > validationEpsilon {
+				continue
+			}
+			start := ((c.X-a.X)*(b.X-a.X) + (c.Y-a.Y)*(b.Y-a.Y)) / length
+			end := ((d.X-a.X)*(b.X-a.X) + (d.Y-a.Y)*(b.Y-a.Y)) / length
+			spans = append(spans, [2]float64{min(start, end), max(start, end)})
+		}
+		sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+		reached := 0.0
+		for _, span := range spans {
+			if span[0] > reached {
+				uncut += min(span[0], length) - reached
+			}
+			reached = max(reached, span[1])
+			if reached >= length {
+				break
+			}
+		}
+		if reached < length {
+			uncut += length - reached
+		}
+	}
+	return uncut
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"glass-optimizer/internal/models"
+)
+
+func TestEveryAlgorithmPassesValidation(t *testing.T) {
+	service, store, userID := newTestOptimizer(t)
+
+	sheet := &models.GlassSheet{
+		Name: "Flawed 2m x 3m", Width: 2000, Height: 3000, Thickness: 6, PricePerSqm: 45.50, InStock: 5, Material: "clear",
+		Defects: []models.SheetDefect{{Type: "bubble", X: 900, Y: 1400, Width: 200, Height: 150}},
+	}
+	if err := store.CreateGlassSheet(sheet); err != nil {
+		t.Fatalf("CreateGlassSheet() error = %v", err)
+	}
+	disc := &models.Design{
+		Name: "Table Top", Width: 500, Height: 500, Thickness: 6, UserID: userID,
+		Elements: models.Elements{Shapes: []models.Shape{{
+			Type: models.ShapeCircle, Points: []models.Point{{X: 250, Y: 250}}, Visible: true,
+		}}},
+	}
+	frame := &models.Design{
+		Name: "Frame", Width: 1000, Height: 1200, Thickness: 6, UserID: userID,
+		Elements: models.Elements{Holes: []models.Hole{{
+			Type: models.HoleRectangular, Center: models.Point{X: 500, Y: 600}, Width: 800, Height: 800, Visible: true,
+		}}},
+	}
+	for _, design := range []*models.Design{disc, frame} {
+		if err := store.CreateDesign(design); err != nil {
+			t.Fatalf("CreateDesign() error = %v", err)
+		}
+	}
+
+	designs := []models.DesignItem{
+		{DesignID: frame.ID, Quantity: 2},
+		{DesignID: disc.ID, Quantity: 6},
+		{DesignID: 0, Name: "Door", Width: 800, Height: 2100, Quantity: 2},
+		{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 8},
+		{DesignID: 0, Name: "Tile", Width: 300, Height: 350, Quantity: 10},
+	}
+	optionSets := map[string]models.OptimizeOptions{
+		"defaults": {MaxIterations: 5, PopulationSize: 6, Seed: 3},
+		"allowances": {
+			AllowRotation: true, EnableNesting: true, Kerf: 3, MinimumGap: 4, GrindingAllowance: 1,
+			Trim: models.EdgeTrim{Left: 12, Bottom: 20}, MaxIterations: 5, PopulationSize: 6, Seed: 3,
+		},
+		"improved": {AllowRotation: true, Improve: true, ImproveIterations: 50, MaxIterations: 5, PopulationSize: 6, Seed: 3},
+	}
+
+	algorithms := []string{
+		"blf", "genetic", "greedy", "guillotine", "maxrects-bssf", "maxrects-blsf", "maxrects-baf", "maxrects-cp", "skyline", "nfp",
+	}
+	for name, options := range optionSets {
+		for _, algorithm := range algorithms {
+			t.Run(name+"/"+algorithm, func(t *testing.T) {
+				optimization, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+					Name: "Validation " + algorithm, SheetID: sheet.ID, Algorithm: algorithm, Designs: designs, Options: options,
+				}, userID)
+				if err != nil {
+					t.Fatalf("RunOptimization() error = %v", err)
+				}
+				if got := len(optimization.Layout.AllPieces()); got < 28 {
+					t.Errorf("Placed pieces incorrect: got %d, want at least %d", got, 28)
+				}
+				assertLegalLayout(t, &optimization.Layout)
+			})
+		}
+	}
+
+	exact, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Validation exact", SheetID: 1, Algorithm: "exact",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 900, Height: 1400, Quantity: 7}},
+		Options: models.OptimizeOptions{AllowRotation: true, Kerf: 3},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization(exact) error = %v", err)
+	}
+	assertLegalLayout(t, &exact.Layout)
+
+	// A stored layout is checked against the options it was run with, and
+	// fails once the gap asked for is wider than it was laid out with
+	validation, err := service.ValidateOptimization(exact.ID, userID, nil)
+	if err != nil {
+		t.Fatalf("ValidateOptimization() error = %v", err)
+	}
+	if !validation.Valid || len(validation.Violations) != 0 {
+		t.Errorf("Validation incorrect: got %+v, want valid", validation)
+	}
+	validation, err = service.ValidateOptimization(exact.ID, userID, &models.OptimizeOptions{MinimumGap: 10})
+	if err != nil {
+		t.Fatalf("ValidateOptimization() error = %v", err)
+	}
+	if validation.Valid || countViolations(validation.Violations, models.ViolationGap) == 0 {
+		t.Errorf("Validation incorrect: got %+v, want gap violations", validation)
+	}
+	if _, err := service.ValidateOptimization(exact.ID+1000, userID, nil); err == nil {
+		t.Error("ValidateOptimization() of a missing optimization succeeded")
+	}
+
+	// A layout run with a narrow margin stays valid against its own options,
+	// not against the default margin
+	narrow, err := service.RunOptimization(context.Background(), &models.OptimizationRequest{
+		Name: "Validation margin", SheetID: 1, Algorithm: "blf",
+		Designs: []models.DesignItem{{DesignID: 0, Name: "Pane", Width: 600, Height: 450, Quantity: 4}},
+		Options: models.OptimizeOptions{EdgeMargin: 1},
+	}, userID)
+	if err != nil {
+		t.Fatalf("RunOptimization(blf) error = %v", err)
+	}
+	stored, err := service.GetOptimization(narrow.ID, userID)
+	if err != nil {
+		t.Fatalf("GetOptimization() error = %v", err)
+	}
+	if stored.Options == nil || stored.Options.EdgeMargin != 1 || stored.Options.MinimumGap != 2 || stored.Options.Seed != stored.Seed {
+		t.Errorf("Stored options incorrect: got %+v", stored.Options)
+	}
+	if validation, err := service.ValidateOptimization(narrow.ID, userID, nil); err != nil || !validation.Valid {
+		t.Errorf("Validation against stored options incorrect: got %+v (%v), want valid", validation, err)
+	}
+	validation, err = service.ValidateOptimization(narrow.ID, userID, &models.OptimizeOptions{})
+	if err != nil {
+		t.Fatalf("ValidateOptimization() error = %v", err)
+	}
+	if countViolations(validation.Violations, models.ViolationEdgeMargin) == 0 {
+		t.Errorf("Validation against default options incorrect: got %+v, want margin violations", validation)
+	}
+}
+
+func TestValidateLayoutFindsViolations(t *testing.T) {
+	options := &models.OptimizeOptions{MinimumGap: 2, EdgeMargin: 5}
+
+	// cutPiece returns a rectangular piece with a cut along each edge
+	cutPiece := func(id string, x, y, width, height float64) (models.PlacedPiece, []models.CutPath) {
+		piece := models.PlacedPiece{ID: id, X: x, Y: y, Width: width, Height: height}
+		var cuts []models.CutPath
+		for _, edge := range outlineEdges(pieceOutline(piece)) {
+			cuts = append(cuts, models.CutPath{StartX: edge[0].X, StartY: edge[0].Y, EndX: edge[1].X, EndY: edge[1].Y})
+		}
+		return piece, cuts
+	}
+
+	tests := []struct {
+		name   string
+		pieces [][4]float64
+		drop   int // Number of cut paths to leave out of the layout
+		want   map[string]int
+	}{
+		{name: "legal", pieces: [][4]float64{{5, 5, 500, 400}, {507, 5, 500, 400}, {5, 407, 300, 300}}},
+		{name: "overlap", pieces: [][4]float64{{5, 5, 500, 400}, {400, 300, 500, 400}}, want: map[string]int{models.ViolationOverlap: 1}},
+		{name: "touching", pieces: [][4]float64{{5, 5, 500, 400}, {505, 5, 500, 400}}, want: map[string]int{models.ViolationGap: 1}},
+		{name: "corners too close", pieces: [][4]float64{{5, 5, 500, 400}, {506, 406, 100, 100}}, want: map[string]int{models.ViolationGap: 1}},
+		{name: "off the sheet", pieces: [][4]float64{{1600, 5, 500, 400}}, want: map[string]int{models.ViolationOutOfBounds: 1}},
+		{name: "in the margin", pieces: [][4]float64{{2, 5, 500, 400}}, want: map[string]int{models.ViolationEdgeMargin: 1}},
+		{name: "near a defect", pieces: [][4]float64{{5, 5, 500, 400}, {5, 1000, 200, 200}}, want: map[string]int{models.ViolationDefect: 1}},
+		{name: "missing cut", pieces: [][4]float64{{100, 100, 500, 400}}, drop: 1, want: map[string]int{models.ViolationUncut: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet := models.SheetLayout{SheetNumber: 1, Width: 2000, Height: 3000}
+			if tt.name == "near a defect" {
+				sheet.Defects = []models.SheetDefect{{X: 206, Y: 1000, Width: 50, Height: 50}}
+			}
+			for i, rect := range tt.pieces {
+				piece, cuts := cutPiece(placementID(1, i+1), rect[0], rect[1], rect[2], rect[3])
+				sheet.Pieces = append(sheet.Pieces, piece)
+				sheet.CutPaths = append(sheet.CutPaths, cuts...)
+			}
+			sheet.CutPaths = sheet.CutPaths[:len(sheet.CutPaths)-tt.drop]
+
+			violations := ValidateLayout(&models.Layout{Sheets: []models.SheetLayout{sheet}}, options)
+			total := 0
+			for kind, want := range tt.want {
+				total += want
+				if got := countViolations(violations, kind); got != want {
+					t.Errorf("%s violations incorrect: got %d, want %d", kind, got, want)
+				}
+			}
+			if len(violations) != total {
+				t.Errorf("Violations incorrect: got %+v", violations)
+			}
+		})
+	}
+
+	// Outlines are measured by their shape, not their bounding box
+	discs := models.SheetLayout{SheetNumber: 1, Width: 2000, Height: 3000}
+	for i, center := range []models.Point{{X: 300, Y: 300}, {X: 680, Y: 680}} {
+		outline, _ := ellipseOutline(center, 250, 250)
+		x, y := outlineOrigin(outline)
+		discs.Pieces = append(discs.Pieces, models.PlacedPiece{ID: placementID(1, i+1), X: x, Y: y, Width: 500, Height: 500, Outline: outline})
+		for _, edge := range outlineEdges(outline) {
+			discs.CutPaths = append(discs.CutPaths, models.CutPath{StartX: edge[0].X, StartY: edge[0].Y, EndX: edge[1].X, EndY: edge[1].Y})
+		}
+	}
+	if violations := ValidateLayout(&models.Layout{Sheets: []models.SheetLayout{discs}}, options); len(violations) != 0 {
+		t.Errorf("Discs with overlapping bounding boxes incorrect: got %+v, want none", violations)
+	}
+	discs.Pieces[1] = models.PlacedPiece{ID: "s1-p2", X: 300, Y: 300, Width: 100, Height: 100}
+	if violations := ValidateLayout(&models.Layout{Sheets: []models.SheetLayout{discs}}, options); countViolations(violations, models.ViolationOverlap) != 1 {
+		t.Errorf("Square inside a disc incorrect: got %+v, want an overlap", violations)
+	}
+}
+
+// countViolations returns how many of the violations are of the given kind
+func countViolations(violations []models.Violation, kind string) int {
+	count := 0
+	for _, violation := range violations {
+		if violation.Type == kind {
+			count++
+		}
+	}
+	return count
+}
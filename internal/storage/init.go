@@ -49,10 +49,36 @@ func InitializeDatabase(dbPath string, logger *slog.Logger) (*sql.DB, error) {
 		}
 	}
 
+	if err := seedGlassSheets(db, logger); err != nil {
+		logger.Error("Failed to seed glass sheets", "error", err)
+		return nil, err
+	}
+
 	logger.Info("Database initialized successfully")
 	return db, nil
 }
 
+// seedGlassSheets inserts the standard sheet catalogue into an empty glass_sheets table
+func seedGlassSheets(db *sql.DB, logger *slog.Logger) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM glass_sheets").Scan(&count); err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	logger.Info("Seeding default glass sheet catalogue")
+	_, err := db.Exec(`
+		INSERT INTO glass_sheets (name, width, height, thickness, price_per_sqm, in_stock, material, properties)
+		VALUES
+			('Standard 2m x 3m', 2000, 3000, 6, 45.50, 15, 'clear', '{}'),
+			('Large 2.5m x 3.5m', 2500, 3500, 6, 48.00, 8, 'clear', '{}')
+	`)
+	return err
+}
+
 // isNewDatabase checks if the database is newly created
 func isNewDatabase(db *sql.DB) (bool, error) {
 	var count int
@@ -303,6 +329,145 @@ func runMigrations(db *sql.DB, logger *slog.Logger) error {
 		}
 	}
 
+	// Check and migrate optimizations table for seed if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('optimizations')
+		WHERE name = 'seed'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating optimizations table to add seed")
+
+		_, err = db.Exec(`ALTER TABLE optimizations ADD COLUMN seed INTEGER DEFAULT 0`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate optimizations table for seed", "error", err)
+		} else {
+			logger.Info("Optimizations table seed migration completed")
+		}
+	}
+
+	// Check and migrate optimizations table for options if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('optimizations')
+		WHERE name = 'options'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating optimizations table to add options")
+
+		_, err = db.Exec(`ALTER TABLE optimizations ADD COLUMN options TEXT DEFAULT NULL`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate optimizations table for options", "error", err)
+		} else {
+			logger.Info("Optimizations table options migration completed")
+		}
+	}
+
+	// Check and migrate optimizations table for sheet_ids if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('optimizations')
+		WHERE name = 'sheet_ids'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating optimizations table to add sheet_ids and matching_sheets")
+
+		_, err = db.Exec(`
+			ALTER TABLE optimizations ADD COLUMN sheet_ids TEXT DEFAULT NULL;
+			ALTER TABLE optimizations ADD COLUMN matching_sheets INTEGER DEFAULT 0;
+		`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate optimizations table for sheet_ids", "error", err)
+		} else {
+			logger.Info("Optimizations table sheet_ids migration completed")
+		}
+	}
+
+	// Check and migrate optimizations table for confirmed_at if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('optimizations')
+		WHERE name = 'confirmed_at'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating optimizations table to add confirmed_at")
+
+		_, err = db.Exec(`ALTER TABLE optimizations ADD COLUMN confirmed_at DATETIME DEFAULT NULL`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate optimizations table for confirmed_at", "error", err)
+		} else {
+			logger.Info("Optimizations table confirmed_at migration completed")
+		}
+	}
+
+	// Check and migrate optimizations table for batch_id if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('optimizations')
+		WHERE name = 'batch_id'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating optimizations table to add batch_id")
+
+		_, err = db.Exec(`
+			ALTER TABLE optimizations ADD COLUMN batch_id TEXT DEFAULT NULL;
+			CREATE INDEX IF NOT EXISTS idx_optimizations_batch_id ON optimizations(batch_id);
+		`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate optimizations table for batch_id", "error", err)
+		} else {
+			logger.Info("Optimizations table batch_id migration completed")
+		}
+	}
+
+	// Check and migrate designs table for grain if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('designs')
+		WHERE name = 'grain'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating designs table to add grain")
+
+		_, err = db.Exec(`ALTER TABLE designs ADD COLUMN grain TEXT DEFAULT ''`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate designs table for grain", "error", err)
+		} else {
+			logger.Info("Designs table grain migration completed")
+		}
+	}
+
+	// Check and migrate glass_sheets table for defects if needed
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM pragma_table_info('glass_sheets')
+		WHERE name = 'defects'
+	`).Scan(&columnExists)
+
+	if err == nil && !columnExists {
+		logger.Info("Migrating glass_sheets table to add defects")
+
+		_, err = db.Exec(`ALTER TABLE glass_sheets ADD COLUMN defects TEXT DEFAULT '[]'`)
+
+		if err != nil {
+			logger.Warn("Failed to migrate glass_sheets table for defects", "error", err)
+		} else {
+			logger.Info("Glass sheets table defects migration completed")
+		}
+	}
+
 	// Ensure all tables exist (for cases where some tables are missing)
 	logger.Info("Ensuring all required tables and indexes exist")
 	_, err = db.Exec(`
@@ -319,6 +484,7 @@ func runMigrations(db *sql.DB, logger *slog.Logger) error {
 			supplier TEXT DEFAULT '',
 			grade TEXT DEFAULT 'standard',
 			properties TEXT,
+			defects TEXT DEFAULT '[]',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		);
 
@@ -329,5 +495,56 @@ func runMigrations(db *sql.DB, logger *slog.Logger) error {
 		logger.Warn("Failed to ensure glass_sheets table", "error", err)
 	}
 
+	_, err = db.Exec(`
+		-- Create optimization_jobs if not exists
+		CREATE TABLE IF NOT EXISTS optimization_jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'queued',
+			request_data TEXT NOT NULL,
+			progress TEXT DEFAULT '{}',
+			optimization_id INTEGER DEFAULT NULL,
+			error TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			started_at DATETIME DEFAULT NULL,
+			finished_at DATETIME DEFAULT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (optimization_id) REFERENCES optimizations(id) ON DELETE SET NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_optimization_jobs_user_id ON optimization_jobs(user_id);
+		CREATE INDEX IF NOT EXISTS idx_optimization_jobs_status ON optimization_jobs(status);
+	`)
+
+	if err != nil {
+		logger.Warn("Failed to ensure optimization_jobs table", "error", err)
+	}
+
+	_, err = db.Exec(`
+		-- Create remnants if not exists
+		CREATE TABLE IF NOT EXISTS remnants (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			sheet_id INTEGER NOT NULL,
+			width REAL NOT NULL,
+			height REAL NOT NULL,
+			thickness REAL NOT NULL,
+			material TEXT DEFAULT 'clear',
+			status TEXT NOT NULL DEFAULT 'pending',
+			source_optimization_id INTEGER DEFAULT NULL,
+			consumed_by_optimization_id INTEGER DEFAULT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (sheet_id) REFERENCES glass_sheets(id) ON DELETE CASCADE,
+			FOREIGN KEY (source_optimization_id) REFERENCES optimizations(id) ON DELETE SET NULL,
+			FOREIGN KEY (consumed_by_optimization_id) REFERENCES optimizations(id) ON DELETE SET NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_remnants_status ON remnants(status);
+		CREATE INDEX IF NOT EXISTS idx_remnants_source_optimization_id ON remnants(source_optimization_id);
+	`)
+
+	if err != nil {
+		logger.Warn("Failed to ensure remnants table", "error", err)
+	}
+
 	return nil
 }
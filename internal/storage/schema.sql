@@ -69,6 +69,7 @@ CREATE TABLE IF NOT EXISTS designs (
     width REAL NOT NULL,
     height REAL NOT NULL,
     thickness REAL NOT NULL,
+    grain TEXT DEFAULT '',  -- Way the glass pattern must run in the piece
     design_data TEXT NOT NULL,  -- JSON blob with holes, shapes, etc.
     user_id INTEGER NOT NULL,       -- Owner of the design
     project_id INTEGER DEFAULT NULL,  -- Link to project
@@ -95,6 +96,7 @@ CREATE TABLE IF NOT EXISTS glass_sheets (
     supplier TEXT DEFAULT '',
     grade TEXT DEFAULT 'standard',
     properties TEXT,  -- JSON blob for additional properties
+    defects TEXT DEFAULT '[]',  -- JSON array of marked flaws
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -105,15 +107,21 @@ CREATE TABLE IF NOT EXISTS optimizations (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
     sheet_id INTEGER NOT NULL,
+    sheet_ids TEXT DEFAULT NULL,  -- JSON array of candidate sheet IDs the layout chose from
+    matching_sheets INTEGER DEFAULT 0,  -- Layout chose from every in-stock sheet of the same glass
     design_ids TEXT NOT NULL,  -- JSON array of design IDs
     layout_data TEXT NOT NULL,  -- JSON blob with optimization results
     waste_percentage REAL NOT NULL,
     total_area REAL NOT NULL,
     used_area REAL NOT NULL,
     algorithm TEXT DEFAULT 'blf',  -- blf, genetic, greedy
+    seed INTEGER DEFAULT 0,  -- random seed the layout was produced with
+    options TEXT DEFAULT NULL,  -- JSON options the layout was produced with
     execution_time REAL DEFAULT 0,  -- in seconds
     user_id INTEGER NOT NULL,        -- Owner of the optimization
     project_id INTEGER DEFAULT NULL,  -- Link to project
+    confirmed_at DATETIME DEFAULT NULL,  -- Set once the layout is released for cutting
+    batch_id TEXT DEFAULT NULL,  -- Shared by the optimizations of one request split by glass type
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (sheet_id) REFERENCES glass_sheets(id) ON DELETE CASCADE,
@@ -124,3 +132,43 @@ CREATE INDEX IF NOT EXISTS idx_optimizations_user_id ON optimizations(user_id);
 CREATE INDEX IF NOT EXISTS idx_optimizations_project_id ON optimizations(project_id);
 CREATE INDEX IF NOT EXISTS idx_optimizations_sheet_id ON optimizations(sheet_id);
 CREATE INDEX IF NOT EXISTS idx_optimizations_created_at ON optimizations(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_optimizations_batch_id ON optimizations(batch_id);
+
+-- Optimization jobs table (background optimization queue)
+CREATE TABLE IF NOT EXISTS optimization_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,          -- Owner of the job
+    status TEXT NOT NULL DEFAULT 'queued',  -- queued, running, completed, failed, cancelled
+    request_data TEXT NOT NULL,        -- JSON optimization request
+    progress TEXT DEFAULT '{}',        -- JSON progress of the latest run
+    optimization_id INTEGER DEFAULT NULL,  -- Result once the run has finished
+    error TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME DEFAULT NULL,
+    finished_at DATETIME DEFAULT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (optimization_id) REFERENCES optimizations(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_optimization_jobs_user_id ON optimization_jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_optimization_jobs_status ON optimization_jobs(status);
+
+-- Remnants table (offcuts kept in stock)
+CREATE TABLE IF NOT EXISTS remnants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sheet_id INTEGER NOT NULL,         -- Catalogue sheet the glass came from
+    width REAL NOT NULL,
+    height REAL NOT NULL,
+    thickness REAL NOT NULL,
+    material TEXT DEFAULT 'clear',
+    status TEXT NOT NULL DEFAULT 'pending',  -- pending, available, consumed
+    source_optimization_id INTEGER DEFAULT NULL,       -- Optimization that left the offcut
+    consumed_by_optimization_id INTEGER DEFAULT NULL,  -- Optimization that used it up
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (sheet_id) REFERENCES glass_sheets(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_optimization_id) REFERENCES optimizations(id) ON DELETE SET NULL,
+    FOREIGN KEY (consumed_by_optimization_id) REFERENCES optimizations(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_remnants_status ON remnants(status);
+CREATE INDEX IF NOT EXISTS idx_remnants_source_optimization_id ON remnants(source_optimization_id);
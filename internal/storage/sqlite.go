@@ -3,6 +3,7 @@ package storage
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"strings"
 	"time"
@@ -55,6 +56,7 @@ type Storage interface {
 	UpdateGlassSheet(sheet *models.GlassSheet) error
 	DeleteGlassSheet(id int) error
 	SearchGlassSheets(query string, limit, offset int) ([]models.GlassSheet, int, error)
+	GetInStockGlassSheets(material string, thickness float64) ([]models.GlassSheet, error)
 
 	// Optimization operations
 	CreateOptimization(opt *models.Optimization) error
@@ -62,6 +64,22 @@ type Storage interface {
 	GetOptimizations(userID int64, limit, offset int) ([]models.Optimization, int, error)
 	UpdateOptimization(opt *models.Optimization, userID int64) error
 	DeleteOptimization(id int, userID int64) error
+	GetOptimizationBatch(batchID string, userID int64) ([]models.Optimization, error)
+
+	// Optimization job operations
+	CreateOptimizationJob(job *models.OptimizationJob) error
+	GetOptimizationJob(id int, userID int64) (*models.OptimizationJob, error)
+	GetOptimizationJobs(userID int64, limit, offset int) ([]models.OptimizationJob, int, error)
+	GetUnfinishedOptimizationJobs() ([]models.OptimizationJob, error)
+	UpdateOptimizationJob(job *models.OptimizationJob) error
+
+	// Remnant operations
+	CreateRemnant(remnant *models.Remnant) error
+	GetRemnant(id int) (*models.Remnant, error)
+	GetRemnants(status string, limit, offset int) ([]models.Remnant, int, error)
+	GetAvailableRemnants(material string, thickness float64) ([]models.Remnant, error)
+	DeleteRemnant(id int) error
+	ConfirmOptimization(id int, userID int64, remnantIDs []int) error
 
 	// Project operations
 	CreateProject(project *models.Project) error
@@ -505,8 +523,8 @@ func (s *SQLiteStorage) CreateDesign(design *models.Design) error {
 	}
 
 	query := `
-		INSERT INTO designs (name, description, width, height, thickness, design_data, user_id, project_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO designs (name, description, width, height, thickness, grain, design_data, user_id, project_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now()
@@ -519,6 +537,7 @@ func (s *SQLiteStorage) CreateDesign(design *models.Design) error {
 		design.Width,
 		design.Height,
 		design.Thickness,
+		design.Grain,
 		design.DesignData,
 		design.UserID,
 		design.ProjectID,
@@ -544,7 +563,7 @@ func (s *SQLiteStorage) CreateDesign(design *models.Design) error {
 
 func (s *SQLiteStorage) GetDesign(id int, userID int64) (*models.Design, error) {
 	query := `
-		SELECT id, name, description, width, height, thickness, design_data, user_id, project_id, created_at, updated_at
+		SELECT id, name, description, width, height, thickness, grain, design_data, user_id, project_id, created_at, updated_at
 		FROM designs
 		WHERE id = ? AND user_id = ?
 	`
@@ -558,6 +577,7 @@ func (s *SQLiteStorage) GetDesign(id int, userID int64) (*models.Design, error)
 		&design.Width,
 		&design.Height,
 		&design.Thickness,
+		&design.Grain,
 		&design.DesignData,
 		&design.UserID,
 		&projectID,
@@ -597,7 +617,7 @@ func (s *SQLiteStorage) GetDesigns(userID int64, limit, offset int) ([]models.De
 
 	// Get designs with pagination
 	query := `
-		SELECT id, name, description, width, height, thickness, design_data, user_id, project_id, created_at, updated_at
+		SELECT id, name, description, width, height, thickness, grain, design_data, user_id, project_id, created_at, updated_at
 		FROM designs
 		WHERE user_id = ?
 		ORDER BY created_at DESC
@@ -621,6 +641,7 @@ func (s *SQLiteStorage) GetDesigns(userID int64, limit, offset int) ([]models.De
 			&design.Width,
 			&design.Height,
 			&design.Thickness,
+			&design.Grain,
 			&design.DesignData,
 			&design.UserID,
 			&projectID,
@@ -663,7 +684,7 @@ func (s *SQLiteStorage) UpdateDesign(design *models.Design, userID int64) error
 
 	query := `
 		UPDATE designs
-		SET name = ?, description = ?, width = ?, height = ?, thickness = ?, design_data = ?, project_id = ?, updated_at = ?
+		SET name = ?, description = ?, width = ?, height = ?, thickness = ?, grain = ?, design_data = ?, project_id = ?, updated_at = ?
 		WHERE id = ? AND user_id = ?
 	`
 
@@ -675,6 +696,7 @@ func (s *SQLiteStorage) UpdateDesign(design *models.Design, userID int64) error
 		design.Width,
 		design.Height,
 		design.Thickness,
+		design.Grain,
 		design.DesignData,
 		design.ProjectID,
 		design.UpdatedAt,
@@ -744,7 +766,7 @@ func (s *SQLiteStorage) SearchDesigns(query string, userID int64, limit, offset
 	// Get designs with search and pagination
 	// Search designs
 	searchQuery := `
-		SELECT id, name, description, width, height, thickness, design_data, user_id, project_id, created_at, updated_at
+		SELECT id, name, description, width, height, thickness, grain, design_data, user_id, project_id, created_at, updated_at
 		FROM designs
 		WHERE user_id = ? AND (LOWER(name) LIKE ? OR LOWER(description) LIKE ?)
 		ORDER BY created_at DESC
@@ -768,6 +790,7 @@ func (s *SQLiteStorage) SearchDesigns(query string, userID int64, limit, offset
 			&design.Width,
 			&design.Height,
 			&design.Thickness,
+			&design.Grain,
 			&design.DesignData,
 			&design.UserID,
 			&projectID,
@@ -806,9 +829,14 @@ func (s *SQLiteStorage) CreateGlassSheet(sheet *models.GlassSheet) error {
 		return models.NewInternalError("failed to marshal sheet properties", err)
 	}
 
+	defects, err := json.Marshal(sheet.Defects)
+	if err != nil {
+		return models.NewInternalError("failed to marshal sheet defects", err)
+	}
+
 	query := `
-		INSERT INTO glass_sheets (name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO glass_sheets (name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, defects, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now()
@@ -825,6 +853,7 @@ func (s *SQLiteStorage) CreateGlassSheet(sheet *models.GlassSheet) error {
 		sheet.Supplier,
 		sheet.Grade,
 		sheet.Properties,
+		string(defects),
 		sheet.CreatedAt,
 	)
 
@@ -846,30 +875,13 @@ func (s *SQLiteStorage) CreateGlassSheet(sheet *models.GlassSheet) error {
 
 func (s *SQLiteStorage) GetGlassSheet(id int) (*models.GlassSheet, error) {
 	query := `
-		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, created_at
+		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, defects, created_at
 		FROM glass_sheets
 		WHERE id = ?
 	`
 
 	sheet := &models.GlassSheet{}
-	var properties sql.NullString
-
-	err := s.db.QueryRow(query, id).Scan(
-		&sheet.ID,
-		&sheet.Name,
-		&sheet.Width,
-		&sheet.Height,
-		&sheet.Thickness,
-		&sheet.PricePerSqm,
-		&sheet.InStock,
-		&sheet.Material,
-		&sheet.Supplier,
-		&sheet.Grade,
-		&properties,
-		&sheet.CreatedAt,
-	)
-
-	if err != nil {
+	if err := s.scanGlassSheet(s.db.QueryRow(query, id), sheet); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, models.NewNotFoundError("glass sheet")
 		}
@@ -877,14 +889,6 @@ func (s *SQLiteStorage) GetGlassSheet(id int) (*models.GlassSheet, error) {
 		return nil, models.NewDatabaseError("failed to get glass sheet", err)
 	}
 
-	if properties.Valid {
-		sheet.Properties = properties.String
-		if err := sheet.UnmarshalProperties(); err != nil {
-			s.logger.Error("Failed to unmarshal sheet properties", "error", err, "id", id)
-			return nil, models.NewInternalError("failed to unmarshal sheet properties", err)
-		}
-	}
-
 	return sheet, nil
 }
 
@@ -899,7 +903,7 @@ func (s *SQLiteStorage) GetGlassSheets(limit, offset int) ([]models.GlassSheet,
 
 	// Get sheets with pagination
 	query := `
-		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, created_at
+		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, defects, created_at
 		FROM glass_sheets
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?
@@ -914,35 +918,11 @@ func (s *SQLiteStorage) GetGlassSheets(limit, offset int) ([]models.GlassSheet,
 	var sheets []models.GlassSheet
 	for rows.Next() {
 		sheet := models.GlassSheet{}
-		var properties sql.NullString
-
-		err := rows.Scan(
-			&sheet.ID,
-			&sheet.Name,
-			&sheet.Width,
-			&sheet.Height,
-			&sheet.Thickness,
-			&sheet.PricePerSqm,
-			&sheet.InStock,
-			&sheet.Material,
-			&sheet.Supplier,
-			&sheet.Grade,
-			&properties,
-			&sheet.CreatedAt,
-		)
-		if err != nil {
+		if err := s.scanGlassSheet(rows, &sheet); err != nil {
 			s.logger.Error("Failed to scan glass sheet row", "error", err)
 			continue
 		}
 
-		if properties.Valid {
-			sheet.Properties = properties.String
-			if err := sheet.UnmarshalProperties(); err != nil {
-				s.logger.Error("Failed to unmarshal sheet properties", "error", err, "id", sheet.ID)
-				continue
-			}
-		}
-
 		sheets = append(sheets, sheet)
 	}
 
@@ -958,9 +938,14 @@ func (s *SQLiteStorage) UpdateGlassSheet(sheet *models.GlassSheet) error {
 		return models.NewInternalError("failed to marshal sheet properties", err)
 	}
 
+	defects, err := json.Marshal(sheet.Defects)
+	if err != nil {
+		return models.NewInternalError("failed to marshal sheet defects", err)
+	}
+
 	query := `
 		UPDATE glass_sheets
-		SET name = ?, width = ?, height = ?, thickness = ?, price_per_sqm = ?, in_stock = ?, material = ?, supplier = ?, grade = ?, properties = ?
+		SET name = ?, width = ?, height = ?, thickness = ?, price_per_sqm = ?, in_stock = ?, material = ?, supplier = ?, grade = ?, properties = ?, defects = ?
 		WHERE id = ?
 	`
 
@@ -975,6 +960,7 @@ func (s *SQLiteStorage) UpdateGlassSheet(sheet *models.GlassSheet) error {
 		sheet.Supplier,
 		sheet.Grade,
 		sheet.Properties,
+		string(defects),
 		sheet.ID,
 	)
 
@@ -1035,7 +1021,7 @@ func (s *SQLiteStorage) SearchGlassSheets(query string, limit, offset int) ([]mo
 
 	// Get sheets with search and pagination
 	searchQuery := `
-		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, created_at
+		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, defects, created_at
 		FROM glass_sheets
 		WHERE LOWER(name) LIKE ? OR LOWER(material) LIKE ? OR LOWER(supplier) LIKE ?
 		ORDER BY created_at DESC
@@ -1051,39 +1037,81 @@ func (s *SQLiteStorage) SearchGlassSheets(query string, limit, offset int) ([]mo
 	var sheets []models.GlassSheet
 	for rows.Next() {
 		sheet := models.GlassSheet{}
-		var properties sql.NullString
-
-		err := rows.Scan(
-			&sheet.ID,
-			&sheet.Name,
-			&sheet.Width,
-			&sheet.Height,
-			&sheet.Thickness,
-			&sheet.PricePerSqm,
-			&sheet.InStock,
-			&sheet.Material,
-			&sheet.Supplier,
-			&sheet.Grade,
-			&properties,
-			&sheet.CreatedAt,
-		)
-		if err != nil {
+		if err := s.scanGlassSheet(rows, &sheet); err != nil {
 			s.logger.Error("Failed to scan glass sheet row", "error", err)
 			continue
 		}
 
-		if properties.Valid {
-			sheet.Properties = properties.String
-			if err := sheet.UnmarshalProperties(); err != nil {
-				s.logger.Error("Failed to unmarshal sheet properties", "error", err, "id", sheet.ID)
-				continue
-			}
+		sheets = append(sheets, sheet)
+	}
+
+	return sheets, total, nil
+}
+
+// GetInStockGlassSheets returns the sheets of the given glass that have stock
+// left, in catalogue order
+func (s *SQLiteStorage) GetInStockGlassSheets(material string, thickness float64) ([]models.GlassSheet, error) {
+	query := `
+		SELECT id, name, width, height, thickness, price_per_sqm, in_stock, material, supplier, grade, properties, defects, created_at
+		FROM glass_sheets
+		WHERE material = ? AND thickness = ? AND in_stock > 0
+		ORDER BY id
+	`
+
+	rows, err := s.db.Query(query, material, thickness)
+	if err != nil {
+		return nil, models.NewDatabaseError("failed to query glass sheets", err)
+	}
+	defer rows.Close()
+
+	var sheets []models.GlassSheet
+	for rows.Next() {
+		sheet := models.GlassSheet{}
+		if err := s.scanGlassSheet(rows, &sheet); err != nil {
+			s.logger.Error("Failed to scan glass sheet row", "error", err)
+			continue
 		}
 
 		sheets = append(sheets, sheet)
 	}
 
-	return sheets, total, nil
+	return sheets, nil
+}
+
+// scanGlassSheet scans a database row into a GlassSheet struct
+func (s *SQLiteStorage) scanGlassSheet(row interface{ Scan(...interface{}) error }, sheet *models.GlassSheet) error {
+	var properties, defects sql.NullString
+
+	err := row.Scan(
+		&sheet.ID,
+		&sheet.Name,
+		&sheet.Width,
+		&sheet.Height,
+		&sheet.Thickness,
+		&sheet.PricePerSqm,
+		&sheet.InStock,
+		&sheet.Material,
+		&sheet.Supplier,
+		&sheet.Grade,
+		&properties,
+		&defects,
+		&sheet.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal JSON data
+	if properties.Valid {
+		sheet.Properties = properties.String
+		if err := sheet.UnmarshalProperties(); err != nil {
+			return err
+		}
+	}
+	if defects.Valid && defects.String != "" {
+		return json.Unmarshal([]byte(defects.String), &sheet.Defects)
+	}
+	return nil
 }
 
 // Optimization operations
@@ -1105,9 +1133,17 @@ func (s *SQLiteStorage) CreateOptimization(opt *models.Optimization) error {
 		return models.NewInternalError("failed to marshal layout data", err)
 	}
 
+	if err := opt.MarshalOptions(); err != nil {
+		return models.NewInternalError("failed to marshal options", err)
+	}
+
+	if err := opt.MarshalSheetIDs(); err != nil {
+		return models.NewInternalError("failed to marshal sheet IDs", err)
+	}
+
 	query := `
-		INSERT INTO optimizations (name, sheet_id, design_ids, layout_data, waste_percentage, total_area, used_area, algorithm, execution_time, user_id, project_id, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO optimizations (name, sheet_id, sheet_ids, matching_sheets, design_ids, layout_data, waste_percentage, total_area, used_area, algorithm, seed, options, execution_time, user_id, project_id, batch_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now()
@@ -1116,15 +1152,20 @@ func (s *SQLiteStorage) CreateOptimization(opt *models.Optimization) error {
 	result, err := s.db.Exec(query,
 		opt.Name,
 		opt.SheetID,
+		sql.NullString{String: opt.SheetIDsData, Valid: opt.SheetIDsData != ""},
+		opt.MatchingSheets,
 		opt.DesignIDs,
 		opt.LayoutData,
 		opt.WastePercentage,
 		opt.TotalArea,
 		opt.UsedArea,
 		opt.Algorithm,
+		opt.Seed,
+		sql.NullString{String: opt.OptionsData, Valid: opt.OptionsData != ""},
 		opt.ExecutionTime,
 		opt.UserID,
 		opt.ProjectID,
+		sql.NullString{String: opt.BatchID, Valid: opt.BatchID != ""},
 		opt.CreatedAt,
 	)
 
@@ -1146,28 +1187,38 @@ func (s *SQLiteStorage) CreateOptimization(opt *models.Optimization) error {
 
 func (s *SQLiteStorage) GetOptimization(id int, userID int64) (*models.Optimization, error) {
 	query := `
-		SELECT id, name, sheet_id, design_ids, layout_data, waste_percentage,
-		       total_area, used_area, algorithm, execution_time, user_id, project_id, created_at
+		SELECT id, name, sheet_id, sheet_ids, matching_sheets, design_ids, layout_data, waste_percentage,
+		       total_area, used_area, algorithm, seed, options, execution_time, user_id, project_id, confirmed_at, batch_id, created_at
 		FROM optimizations
 		WHERE id = ? AND user_id = ?
 	`
 
 	opt := &models.Optimization{}
 	var projectID sql.NullInt64
+	var confirmedAt sql.NullTime
+	var batchID sql.NullString
+	var options sql.NullString
+	var sheetIDs sql.NullString
 
 	err := s.db.QueryRow(query, id, userID).Scan(
 		&opt.ID,
 		&opt.Name,
 		&opt.SheetID,
+		&sheetIDs,
+		&opt.MatchingSheets,
 		&opt.DesignIDs,
 		&opt.LayoutData,
 		&opt.WastePercentage,
 		&opt.TotalArea,
 		&opt.UsedArea,
 		&opt.Algorithm,
+		&opt.Seed,
+		&options,
 		&opt.ExecutionTime,
 		&opt.UserID,
 		&projectID,
+		&confirmedAt,
+		&batchID,
 		&opt.CreatedAt,
 	)
 
@@ -1183,6 +1234,12 @@ func (s *SQLiteStorage) GetOptimization(id int, userID int64) (*models.Optimizat
 		pid := int(projectID.Int64)
 		opt.ProjectID = &pid
 	}
+	if confirmedAt.Valid {
+		opt.ConfirmedAt = &confirmedAt.Time
+	}
+	opt.BatchID = batchID.String
+	opt.OptionsData = options.String
+	opt.SheetIDsData = sheetIDs.String
 
 	// Unmarshal JSON data
 	if err := opt.UnmarshalDesignIDs(); err != nil {
@@ -1195,6 +1252,16 @@ func (s *SQLiteStorage) GetOptimization(id int, userID int64) (*models.Optimizat
 		return nil, models.NewInternalError("failed to unmarshal layout data", err)
 	}
 
+	if err := opt.UnmarshalOptions(); err != nil {
+		s.logger.Error("Failed to unmarshal options", "error", err, "id", id)
+		return nil, models.NewInternalError("failed to unmarshal options", err)
+	}
+
+	if err := opt.UnmarshalSheetIDs(); err != nil {
+		s.logger.Error("Failed to unmarshal sheet IDs", "error", err, "id", id)
+		return nil, models.NewInternalError("failed to unmarshal sheet IDs", err)
+	}
+
 	// Calculate derived values
 	opt.WastedArea = opt.TotalArea - opt.UsedArea
 
@@ -1212,8 +1279,8 @@ func (s *SQLiteStorage) GetOptimizations(userID int64, limit, offset int) ([]mod
 
 	// Get optimizations with pagination
 	query := `
-		SELECT id, name, sheet_id, design_ids, layout_data, waste_percentage,
-		       total_area, used_area, algorithm, execution_time, user_id, project_id, created_at
+		SELECT id, name, sheet_id, sheet_ids, matching_sheets, design_ids, layout_data, waste_percentage,
+		       total_area, used_area, algorithm, seed, options, execution_time, user_id, project_id, confirmed_at, batch_id, created_at
 		FROM optimizations
 		WHERE user_id = ?
 		ORDER BY created_at DESC
@@ -1230,20 +1297,30 @@ func (s *SQLiteStorage) GetOptimizations(userID int64, limit, offset int) ([]mod
 	for rows.Next() {
 		opt := models.Optimization{}
 		var projectID sql.NullInt64
+		var confirmedAt sql.NullTime
+		var batchID sql.NullString
+		var options sql.NullString
+		var sheetIDs sql.NullString
 
 		err := rows.Scan(
 			&opt.ID,
 			&opt.Name,
 			&opt.SheetID,
+			&sheetIDs,
+			&opt.MatchingSheets,
 			&opt.DesignIDs,
 			&opt.LayoutData,
 			&opt.WastePercentage,
 			&opt.TotalArea,
 			&opt.UsedArea,
 			&opt.Algorithm,
+			&opt.Seed,
+			&options,
 			&opt.ExecutionTime,
 			&opt.UserID,
 			&projectID,
+			&confirmedAt,
+			&batchID,
 			&opt.CreatedAt,
 		)
 		if err != nil {
@@ -1255,6 +1332,12 @@ func (s *SQLiteStorage) GetOptimizations(userID int64, limit, offset int) ([]mod
 			pid := int(projectID.Int64)
 			opt.ProjectID = &pid
 		}
+		if confirmedAt.Valid {
+			opt.ConfirmedAt = &confirmedAt.Time
+		}
+		opt.BatchID = batchID.String
+		opt.OptionsData = options.String
+		opt.SheetIDsData = sheetIDs.String
 
 		// Unmarshal JSON data
 		if err := opt.UnmarshalDesignIDs(); err != nil {
@@ -1267,6 +1350,16 @@ func (s *SQLiteStorage) GetOptimizations(userID int64, limit, offset int) ([]mod
 			continue
 		}
 
+		if err := opt.UnmarshalOptions(); err != nil {
+			s.logger.Error("Failed to unmarshal options", "error", err, "id", opt.ID)
+			continue
+		}
+
+		if err := opt.UnmarshalSheetIDs(); err != nil {
+			s.logger.Error("Failed to unmarshal sheet IDs", "error", err, "id", opt.ID)
+			continue
+		}
+
 		// Calculate derived values
 		opt.WastedArea = opt.TotalArea - opt.UsedArea
 		if opt.Sheet != nil {
@@ -1296,21 +1389,33 @@ func (s *SQLiteStorage) UpdateOptimization(opt *models.Optimization, userID int6
 		return models.NewInternalError("failed to marshal layout data", err)
 	}
 
+	if err := opt.MarshalOptions(); err != nil {
+		return models.NewInternalError("failed to marshal options", err)
+	}
+
+	if err := opt.MarshalSheetIDs(); err != nil {
+		return models.NewInternalError("failed to marshal sheet IDs", err)
+	}
+
 	query := `
 		UPDATE optimizations
-		SET name = ?, sheet_id = ?, design_ids = ?, layout_data = ?, waste_percentage = ?, total_area = ?, used_area = ?, algorithm = ?, execution_time = ?
+		SET name = ?, sheet_id = ?, sheet_ids = ?, matching_sheets = ?, design_ids = ?, layout_data = ?, waste_percentage = ?, total_area = ?, used_area = ?, algorithm = ?, seed = ?, options = ?, execution_time = ?
 		WHERE id = ? AND user_id = ?
 	`
 
 	result, err := s.db.Exec(query,
 		opt.Name,
 		opt.SheetID,
+		sql.NullString{String: opt.SheetIDsData, Valid: opt.SheetIDsData != ""},
+		opt.MatchingSheets,
 		opt.DesignIDs,
 		opt.LayoutData,
 		opt.WastePercentage,
 		opt.TotalArea,
 		opt.UsedArea,
 		opt.Algorithm,
+		opt.Seed,
+		sql.NullString{String: opt.OptionsData, Valid: opt.OptionsData != ""},
 		opt.ExecutionTime,
 		opt.ID,
 		userID,
@@ -1339,6 +1444,16 @@ func (s *SQLiteStorage) DeleteOptimization(id int, userID int64) error {
 		return models.NewValidationError("user ID is required")
 	}
 
+	// Offcuts of a layout that was never confirmed were never cut
+	_, err := s.db.Exec(`
+		DELETE FROM remnants
+		WHERE status = ? AND source_optimization_id IN (SELECT id FROM optimizations WHERE id = ? AND user_id = ?)
+	`, models.RemnantStatusPending, id, userID)
+	if err != nil {
+		s.logger.Error("Failed to delete pending remnants", "error", err, "optimization_id", id)
+		return models.NewDatabaseError("failed to delete optimization", err)
+	}
+
 	query := "DELETE FROM optimizations WHERE id = ? AND user_id = ?"
 
 	result, err := s.db.Exec(query, id, userID)
@@ -1360,6 +1475,455 @@ func (s *SQLiteStorage) DeleteOptimization(id int, userID int64) error {
 	return nil
 }
 
+// Optimization job operations
+
+func (s *SQLiteStorage) CreateOptimizationJob(job *models.OptimizationJob) error {
+	if job.UserID == 0 {
+		return models.NewValidationError("user ID is required")
+	}
+
+	if err := job.MarshalRequestData(); err != nil {
+		return models.NewInternalError("failed to marshal job request", err)
+	}
+
+	if err := job.MarshalProgressData(); err != nil {
+		return models.NewInternalError("failed to marshal job progress", err)
+	}
+
+	if job.Status == "" {
+		job.Status = models.JobStatusQueued
+	}
+
+	query := `
+		INSERT INTO optimization_jobs (user_id, status, request_data, progress, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	job.CreatedAt = time.Now()
+
+	result, err := s.db.Exec(query, job.UserID, job.Status, job.RequestData, job.ProgressData, job.CreatedAt)
+	if err != nil {
+		s.logger.Error("Failed to create optimization job", "error", err, "user_id", job.UserID)
+		return models.NewDatabaseError("failed to create optimization job", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.NewDatabaseError("failed to get insert ID", err)
+	}
+
+	job.ID = int(id)
+
+	s.logger.Info("Optimization job created successfully", "id", job.ID, "user_id", job.UserID)
+	return nil
+}
+
+func (s *SQLiteStorage) GetOptimizationJob(id int, userID int64) (*models.OptimizationJob, error) {
+	query := `
+		SELECT id, user_id, status, request_data, progress, optimization_id, error,
+		       created_at, started_at, finished_at
+		FROM optimization_jobs
+		WHERE id = ? AND user_id = ?
+	`
+
+	job := &models.OptimizationJob{}
+	if err := s.scanOptimizationJob(s.db.QueryRow(query, id, userID), job); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.NewNotFoundError("optimization job")
+		}
+		s.logger.Error("Failed to get optimization job", "error", err, "id", id)
+		return nil, models.NewDatabaseError("failed to get optimization job", err)
+	}
+
+	return job, nil
+}
+
+func (s *SQLiteStorage) GetOptimizationJobs(userID int64, limit, offset int) ([]models.OptimizationJob, int, error) {
+	// Get total count
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM optimization_jobs WHERE user_id = ?", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, models.NewDatabaseError("failed to count optimization jobs", err)
+	}
+
+	query := `
+		SELECT id, user_id, status, request_data, progress, optimization_id, error,
+		       created_at, started_at, finished_at
+		FROM optimization_jobs
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?
+	`
+
+	jobs, err := s.queryOptimizationJobs(query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// GetUnfinishedOptimizationJobs returns the queued and running jobs of all
+// users in submission order, so that the queue can be rebuilt after a restart
+func (s *SQLiteStorage) GetUnfinishedOptimizationJobs() ([]models.OptimizationJob, error) {
+	query := `
+		SELECT id, user_id, status, request_data, progress, optimization_id, error,
+		       created_at, started_at, finished_at
+		FROM optimization_jobs
+		WHERE status IN (?, ?)
+		ORDER BY id
+	`
+
+	return s.queryOptimizationJobs(query, models.JobStatusQueued, models.JobStatusRunning)
+}
+
+func (s *SQLiteStorage) UpdateOptimizationJob(job *models.OptimizationJob) error {
+	if err := job.MarshalProgressData(); err != nil {
+		return models.NewInternalError("failed to marshal job progress", err)
+	}
+
+	query := `
+		UPDATE optimization_jobs
+		SET status = ?, progress = ?, optimization_id = ?, error = ?, started_at = ?, finished_at = ?
+		WHERE id = ? AND user_id = ?
+	`
+
+	result, err := s.db.Exec(query,
+		job.Status,
+		job.ProgressData,
+		job.OptimizationID,
+		job.Error,
+		job.StartedAt,
+		job.FinishedAt,
+		job.ID,
+		job.UserID,
+	)
+
+	if err != nil {
+		s.logger.Error("Failed to update optimization job", "error", err, "id", job.ID)
+		return models.NewDatabaseError("failed to update optimization job", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.NewDatabaseError("failed to get affected rows", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.NewNotFoundError("optimization job")
+	}
+
+	return nil
+}
+
+// queryOptimizationJobs runs a job query and scans every row
+func (s *SQLiteStorage) queryOptimizationJobs(query string, args ...interface{}) ([]models.OptimizationJob, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, models.NewDatabaseError("failed to query optimization jobs", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.OptimizationJob
+	for rows.Next() {
+		job := models.OptimizationJob{}
+		if err := s.scanOptimizationJob(rows, &job); err != nil {
+			s.logger.Error("Failed to scan optimization job row", "error", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// scanOptimizationJob scans a database row into an OptimizationJob struct
+func (s *SQLiteStorage) scanOptimizationJob(row interface{ Scan(...interface{}) error }, job *models.OptimizationJob) error {
+	var optimizationID sql.NullInt64
+	var progress, jobError sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.Status, &job.RequestData, &progress,
+		&optimizationID, &jobError, &job.CreatedAt, &startedAt, &finishedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Handle nullable fields
+	job.ProgressData = progress.String
+	job.Error = jobError.String
+	if optimizationID.Valid {
+		oid := int(optimizationID.Int64)
+		job.OptimizationID = &oid
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	// Unmarshal JSON data
+	if err := job.UnmarshalRequestData(); err != nil {
+		return err
+	}
+	return job.UnmarshalProgressData()
+}
+
+// Remnant operations
+
+func (s *SQLiteStorage) CreateRemnant(remnant *models.Remnant) error {
+	if remnant.Width <= 0 || remnant.Height <= 0 {
+		return models.NewValidationError("remnant dimensions must be greater than 0")
+	}
+
+	if remnant.Status == "" {
+		remnant.Status = models.RemnantStatusPending
+	}
+
+	query := `
+		INSERT INTO remnants (sheet_id, width, height, thickness, material, status, source_optimization_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	remnant.CreatedAt = time.Now()
+
+	result, err := s.db.Exec(query,
+		remnant.SheetID,
+		remnant.Width,
+		remnant.Height,
+		remnant.Thickness,
+		remnant.Material,
+		remnant.Status,
+		remnant.SourceOptimizationID,
+		remnant.CreatedAt,
+	)
+	if err != nil {
+		s.logger.Error("Failed to create remnant", "error", err, "sheet_id", remnant.SheetID)
+		return models.NewDatabaseError("failed to create remnant", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.NewDatabaseError("failed to get insert ID", err)
+	}
+
+	remnant.ID = int(id)
+	return nil
+}
+
+func (s *SQLiteStorage) GetRemnant(id int) (*models.Remnant, error) {
+	query := `
+		SELECT id, sheet_id, width, height, thickness, material, status,
+		       source_optimization_id, consumed_by_optimization_id, created_at
+		FROM remnants
+		WHERE id = ?
+	`
+
+	remnant := &models.Remnant{}
+	if err := s.scanRemnant(s.db.QueryRow(query, id), remnant); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.NewNotFoundError("remnant")
+		}
+		s.logger.Error("Failed to get remnant", "error", err, "id", id)
+		return nil, models.NewDatabaseError("failed to get remnant", err)
+	}
+
+	return remnant, nil
+}
+
+// GetRemnants lists remnants with the given status, or all remnants when
+// status is empty, newest first
+func (s *SQLiteStorage) GetRemnants(status string, limit, offset int) ([]models.Remnant, int, error) {
+	where := ""
+	var args []interface{}
+	if status != "" {
+		where = "WHERE status = ?"
+		args = append(args, status)
+	}
+
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM remnants "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, models.NewDatabaseError("failed to count remnants", err)
+	}
+
+	query := `
+		SELECT id, sheet_id, width, height, thickness, material, status,
+		       source_optimization_id, consumed_by_optimization_id, created_at
+		FROM remnants
+		` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	remnants, err := s.queryRemnants(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return remnants, total, nil
+}
+
+// GetAvailableRemnants returns the remnants in stock of the given glass,
+// smallest first
+func (s *SQLiteStorage) GetAvailableRemnants(material string, thickness float64) ([]models.Remnant, error) {
+	query := `
+		SELECT id, sheet_id, width, height, thickness, material, status,
+		       source_optimization_id, consumed_by_optimization_id, created_at
+		FROM remnants
+		WHERE status = ? AND material = ? AND thickness = ?
+		ORDER BY width * height, id
+	`
+
+	return s.queryRemnants(query, models.RemnantStatusAvailable, material, thickness)
+}
+
+func (s *SQLiteStorage) DeleteRemnant(id int) error {
+	result, err := s.db.Exec("DELETE FROM remnants WHERE id = ?", id)
+	if err != nil {
+		s.logger.Error("Failed to delete remnant", "error", err, "id", id)
+		return models.NewDatabaseError("failed to delete remnant", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.NewDatabaseError("failed to get affected rows", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.NewNotFoundError("remnant")
+	}
+
+	s.logger.Info("Remnant deleted successfully", "id", id)
+	return nil
+}
+
+// ConfirmOptimization releases an optimization for cutting in one
+// transaction: the remnants it cuts from are consumed and the offcuts it
+// leaves become available stock. It fails with a conflict when one of the
+// remnants has been used up by another optimization in the meantime.
+func (s *SQLiteStorage) ConfirmOptimization(id int, userID int64, remnantIDs []int) error {
+	if userID == 0 {
+		return models.NewValidationError("user ID is required")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.NewDatabaseError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE optimizations SET confirmed_at = ?
+		WHERE id = ? AND user_id = ? AND confirmed_at IS NULL
+	`, time.Now(), id, userID)
+	if err != nil {
+		return models.NewDatabaseError("failed to confirm optimization", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.NewDatabaseError("failed to get affected rows", err)
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		err := tx.QueryRow("SELECT COUNT(*) > 0 FROM optimizations WHERE id = ? AND user_id = ?", id, userID).Scan(&exists)
+		if err != nil {
+			return models.NewDatabaseError("failed to get optimization", err)
+		}
+		if !exists {
+			return models.NewNotFoundError("optimization")
+		}
+		return models.NewConflictError("optimization is already confirmed")
+	}
+
+	for _, remnantID := range remnantIDs {
+		result, err := tx.Exec(`
+			UPDATE remnants SET status = ?, consumed_by_optimization_id = ?
+			WHERE id = ? AND status = ?
+		`, models.RemnantStatusConsumed, id, remnantID, models.RemnantStatusAvailable)
+		if err != nil {
+			return models.NewDatabaseError("failed to consume remnant", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return models.NewDatabaseError("failed to get affected rows", err)
+		}
+
+		if rowsAffected == 0 {
+			return models.NewConflictError(fmt.Sprintf("remnant %d is no longer available; run the optimization again", remnantID))
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE remnants SET status = ?
+		WHERE source_optimization_id = ? AND status = ?
+	`, models.RemnantStatusAvailable, id, models.RemnantStatusPending)
+	if err != nil {
+		return models.NewDatabaseError("failed to release remnants", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.NewDatabaseError("failed to commit transaction", err)
+	}
+
+	s.logger.Info("Optimization confirmed successfully", "id", id, "remnants_used", len(remnantIDs))
+	return nil
+}
+
+// queryRemnants runs a remnant query and scans every row
+func (s *SQLiteStorage) queryRemnants(query string, args ...interface{}) ([]models.Remnant, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, models.NewDatabaseError("failed to query remnants", err)
+	}
+	defer rows.Close()
+
+	var remnants []models.Remnant
+	for rows.Next() {
+		remnant := models.Remnant{}
+		if err := s.scanRemnant(rows, &remnant); err != nil {
+			s.logger.Error("Failed to scan remnant row", "error", err)
+			continue
+		}
+		remnants = append(remnants, remnant)
+	}
+
+	return remnants, nil
+}
+
+// scanRemnant scans a database row into a Remnant struct
+func (s *SQLiteStorage) scanRemnant(row interface{ Scan(...interface{}) error }, remnant *models.Remnant) error {
+	var material sql.NullString
+	var sourceID, consumedByID sql.NullInt64
+
+	err := row.Scan(
+		&remnant.ID, &remnant.SheetID, &remnant.Width, &remnant.Height, &remnant.Thickness,
+		&material, &remnant.Status, &sourceID, &consumedByID, &remnant.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Handle nullable fields
+	remnant.Material = material.String
+	if sourceID.Valid {
+		id := int(sourceID.Int64)
+		remnant.SourceOptimizationID = &id
+	}
+	if consumedByID.Valid {
+		id := int(consumedByID.Int64)
+		remnant.ConsumedByOptimizationID = &id
+	}
+
+	return nil
+}
+
 // Project operations
 
 func (s *SQLiteStorage) CreateProject(project *models.Project) error {
@@ -1863,7 +2427,7 @@ func (s *SQLiteStorage) GetDesignsByProject(projectID int, userID int64) ([]mode
 	}
 
 	query := `
-		SELECT id, name, description, width, height, thickness, design_data, user_id, project_id, created_at, updated_at
+		SELECT id, name, description, width, height, thickness, grain, design_data, user_id, project_id, created_at, updated_at
 		FROM designs
 		WHERE project_id = ? AND user_id = ?
 		ORDER BY created_at DESC
@@ -1887,6 +2451,7 @@ func (s *SQLiteStorage) GetDesignsByProject(projectID int, userID int64) ([]mode
 			&design.Width,
 			&design.Height,
 			&design.Thickness,
+			&design.Grain,
 			&design.DesignData,
 			&design.UserID,
 			&projectID,
@@ -1921,8 +2486,8 @@ func (s *SQLiteStorage) GetOptimizationsByProject(projectID int, userID int64) (
 	}
 
 	query := `
-		SELECT o.id, o.name, o.sheet_id, o.design_ids, o.layout_data, o.waste_percentage,
-		       o.total_area, o.used_area, o.algorithm, o.execution_time, o.user_id, o.project_id, o.created_at
+		SELECT o.id, o.name, o.sheet_id, o.sheet_ids, o.matching_sheets, o.design_ids, o.layout_data, o.waste_percentage,
+		       o.total_area, o.used_area, o.algorithm, o.seed, o.options, o.execution_time, o.user_id, o.project_id, o.confirmed_at, o.batch_id, o.created_at
 		FROM optimizations o
 		WHERE o.project_id = ? AND o.user_id = ?
 		ORDER BY o.created_at DESC
@@ -1938,20 +2503,124 @@ func (s *SQLiteStorage) GetOptimizationsByProject(projectID int, userID int64) (
 	for rows.Next() {
 		opt := models.Optimization{}
 		var projectID sql.NullInt64
+		var confirmedAt sql.NullTime
+		var batchID sql.NullString
+		var options sql.NullString
+		var sheetIDs sql.NullString
+
+		err := rows.Scan(
+			&opt.ID,
+			&opt.Name,
+			&opt.SheetID,
+			&sheetIDs,
+			&opt.MatchingSheets,
+			&opt.DesignIDs,
+			&opt.LayoutData,
+			&opt.WastePercentage,
+			&opt.TotalArea,
+			&opt.UsedArea,
+			&opt.Algorithm,
+			&opt.Seed,
+			&options,
+			&opt.ExecutionTime,
+			&opt.UserID,
+			&projectID,
+			&confirmedAt,
+			&batchID,
+			&opt.CreatedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan optimization row", "error", err)
+			continue
+		}
+
+		if projectID.Valid {
+			pid := int(projectID.Int64)
+			opt.ProjectID = &pid
+		}
+		if confirmedAt.Valid {
+			opt.ConfirmedAt = &confirmedAt.Time
+		}
+		opt.BatchID = batchID.String
+		opt.OptionsData = options.String
+		opt.SheetIDsData = sheetIDs.String
+
+		// Unmarshal JSON data
+		if err := opt.UnmarshalDesignIDs(); err != nil {
+			s.logger.Error("Failed to unmarshal design IDs", "error", err, "id", opt.ID)
+			continue
+		}
+
+		if err := opt.UnmarshalLayoutData(); err != nil {
+			s.logger.Error("Failed to unmarshal layout data", "error", err, "id", opt.ID)
+			continue
+		}
+
+		if err := opt.UnmarshalOptions(); err != nil {
+			s.logger.Error("Failed to unmarshal options", "error", err, "id", opt.ID)
+			continue
+		}
+
+		if err := opt.UnmarshalSheetIDs(); err != nil {
+			s.logger.Error("Failed to unmarshal sheet IDs", "error", err, "id", opt.ID)
+			continue
+		}
+
+		optimizations = append(optimizations, opt)
+	}
+
+	return optimizations, nil
+}
+
+// GetOptimizationBatch gets the optimizations run together for one request, in
+// the order they were run
+func (s *SQLiteStorage) GetOptimizationBatch(batchID string, userID int64) ([]models.Optimization, error) {
+	if userID == 0 {
+		return nil, models.NewValidationError("user ID is required")
+	}
+
+	query := `
+		SELECT o.id, o.name, o.sheet_id, o.sheet_ids, o.matching_sheets, o.design_ids, o.layout_data, o.waste_percentage,
+		       o.total_area, o.used_area, o.algorithm, o.seed, o.options, o.execution_time, o.user_id, o.project_id, o.confirmed_at, o.batch_id, o.created_at
+		FROM optimizations o
+		WHERE o.batch_id = ? AND o.user_id = ?
+		ORDER BY o.id
+	`
+
+	rows, err := s.db.Query(query, batchID, userID)
+	if err != nil {
+		return nil, models.NewDatabaseError("failed to query optimization batch", err)
+	}
+	defer rows.Close()
+
+	var optimizations []models.Optimization
+	for rows.Next() {
+		opt := models.Optimization{}
+		var projectID sql.NullInt64
+		var confirmedAt sql.NullTime
+		var batch sql.NullString
+		var options sql.NullString
+		var sheetIDs sql.NullString
 
 		err := rows.Scan(
 			&opt.ID,
 			&opt.Name,
 			&opt.SheetID,
+			&sheetIDs,
+			&opt.MatchingSheets,
 			&opt.DesignIDs,
 			&opt.LayoutData,
 			&opt.WastePercentage,
 			&opt.TotalArea,
 			&opt.UsedArea,
 			&opt.Algorithm,
+			&opt.Seed,
+			&options,
 			&opt.ExecutionTime,
 			&opt.UserID,
 			&projectID,
+			&confirmedAt,
+			&batch,
 			&opt.CreatedAt,
 		)
 		if err != nil {
@@ -1963,6 +2632,12 @@ func (s *SQLiteStorage) GetOptimizationsByProject(projectID int, userID int64) (
 			pid := int(projectID.Int64)
 			opt.ProjectID = &pid
 		}
+		if confirmedAt.Valid {
+			opt.ConfirmedAt = &confirmedAt.Time
+		}
+		opt.BatchID = batch.String
+		opt.OptionsData = options.String
+		opt.SheetIDsData = sheetIDs.String
 
 		// Unmarshal JSON data
 		if err := opt.UnmarshalDesignIDs(); err != nil {
@@ -1975,6 +2650,16 @@ func (s *SQLiteStorage) GetOptimizationsByProject(projectID int, userID int64) (
 			continue
 		}
 
+		if err := opt.UnmarshalOptions(); err != nil {
+			s.logger.Error("Failed to unmarshal options", "error", err, "id", opt.ID)
+			continue
+		}
+
+		if err := opt.UnmarshalSheetIDs(); err != nil {
+			s.logger.Error("Failed to unmarshal sheet IDs", "error", err, "id", opt.ID)
+			continue
+		}
+
 		optimizations = append(optimizations, opt)
 	}
 
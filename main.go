@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"glass-optimizer/internal/handlers"
 	"glass-optimizer/internal/models"
 	"glass-optimizer/internal/services"
@@ -12,10 +12,17 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout bounds how long the server waits for open requests to
+// finish when it is stopped
+const shutdownTimeout = 30 * time.Second
+
 var templates *template.Template
 
 func main() {
@@ -40,10 +47,28 @@ func main() {
 	// Create services
 	jwtSecret := getEnv("JWT_SECRET", "vitrari-dev-secret-change-in-production")
 	authService := services.NewAuthService(store, logger, jwtSecret)
+	optimizerService := services.NewOptimizerService(store, logger)
+
+	workers, err := strconv.Atoi(getEnv("OPTIMIZER_WORKERS", "2"))
+	if err != nil {
+		log.Fatalf("Invalid OPTIMIZER_WORKERS: %v", err)
+	}
+	// Stopping the server cancels the running jobs, which are queued again
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobService := services.NewJobService(store, optimizerService, logger, workers)
+	if err := jobService.Start(ctx); err != nil {
+		log.Fatalf("Failed to start optimization job queue: %v", err)
+	}
 
 	// Create handlers
 	projectHandler := handlers.NewProjectHandler(store, logger)
+	sheetHandler := handlers.NewSheetHandler(store, logger)
+	remnantHandler := handlers.NewRemnantHandler(store, logger)
 	authHandler := handlers.NewAuthHandler(authService, logger)
+	optimizerHandler := handlers.NewOptimizerHandler(optimizerService, logger)
+	jobHandler := handlers.NewJobHandler(jobService, logger)
 
 	// Create middleware
 	authMiddleware := services.NewAuthMiddleware(authService, logger)
@@ -117,7 +142,20 @@ func main() {
 		handleDesigns(w, r, store, logger)
 	})))
 
-	mux.Handle("/api/sheets", authMiddleware.RequireAuth(http.HandlerFunc(handleSheets)))
+	// Sheet catalogue and remnant stock routes: any user can read, only admins can modify
+	stockRoute := func(next http.HandlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				authMiddleware.RequireAuth(next).ServeHTTP(w, r)
+			} else {
+				authMiddleware.AdminAuth(next).ServeHTTP(w, r)
+			}
+		})
+	}
+	mux.Handle("/api/sheets", stockRoute(sheetHandler.HandleSheets))
+	mux.Handle("/api/sheets/", stockRoute(sheetHandler.HandleSheetByID))
+	mux.Handle("/api/remnants", stockRoute(remnantHandler.HandleRemnants))
+	mux.Handle("/api/remnants/", stockRoute(remnantHandler.HandleRemnantByID))
 
 	// Project routes (protected)
 	mux.Handle("/api/projects/", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -140,7 +178,13 @@ func main() {
 	mux.Handle("/api/optimizations", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handleOptimizations(w, r, store, logger)
 	})))
-	mux.Handle("/api/optimize", authMiddleware.RequireAuth(http.HandlerFunc(handleOptimize)))
+	mux.Handle("/api/optimizations/", authMiddleware.RequireAuth(optimizerHandler.Router()))
+	mux.Handle("/api/optimize", authMiddleware.RequireAuth(http.HandlerFunc(optimizerHandler.RunOptimization)))
+
+	// Background optimization jobs
+	jobRoutes := authMiddleware.RequireAuth(jobHandler.Router())
+	mux.Handle("/api/jobs", jobRoutes)
+	mux.Handle("/api/jobs/", jobRoutes)
 
 	// Apply global middleware chain
 	handler := authMiddleware.SecurityHeaders(
@@ -155,9 +199,22 @@ func main() {
 	log.Printf("Starting Vitrari server on port %s", port)
 	log.Printf("Open http://localhost:%s in your browser", port)
 
-	if err := http.ListenAndServe(":"+port, handler); err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: ":" + port, Handler: handler}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down Vitrari server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
 	}
+	jobService.Wait()
 }
 
 func getEnv(key, fallback string) string {
@@ -499,36 +556,6 @@ func handleDesignMove(w http.ResponseWriter, r *http.Request, store storage.Stor
 	})
 }
 
-func handleSheets(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	sheets := []map[string]interface{}{
-		{
-			"id":            1,
-			"name":          "Standard 2m x 3m",
-			"width":         2000,
-			"height":        3000,
-			"thickness":     6,
-			"price_per_sqm": 45.50,
-			"in_stock":      15,
-		},
-		{
-			"id":            2,
-			"name":          "Large 2.5m x 3.5m",
-			"width":         2500,
-			"height":        3500,
-			"thickness":     6,
-			"price_per_sqm": 48.00,
-			"in_stock":      8,
-		},
-	}
-
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"sheets": sheets,
-		"total":  len(sheets),
-	})
-}
-
 func handleOptimizations(w http.ResponseWriter, r *http.Request, store storage.Storage, logger *slog.Logger) {
 	// Get user from context
 	user := getUserFromContext(r)
@@ -567,330 +594,6 @@ func handleOptimizations(w http.ResponseWriter, r *http.Request, store storage.S
 	})
 }
 
-func handleOptimize(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Get user from context
-	user := getUserFromContext(r)
-	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-
-	// Parse the optimization request
-	var req struct {
-		Name    string `json:"name"`
-		SheetID int    `json:"sheet_id"`
-		Designs []struct {
-			DesignID int     `json:"design_id"`
-			Quantity int     `json:"quantity"`
-			Priority int     `json:"priority"`
-			Width    float64 `json:"width"`
-			Height   float64 `json:"height"`
-			Name     string  `json:"name"`
-		} `json:"designs"`
-		Algorithm string `json:"algorithm"`
-		Options   struct {
-			AllowRotation bool    `json:"allow_rotation"`
-			AllowFlipping bool    `json:"allow_flipping"`
-			MinimumGap    float64 `json:"minimum_gap"`
-			EdgeMargin    float64 `json:"edge_margin"`
-		} `json:"options"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding optimization request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Received optimization request: name=%s, sheet_id=%d, designs=%d, algorithm=%s",
-		req.Name, req.SheetID, len(req.Designs), req.Algorithm)
-
-	// Validate required fields
-	if req.SheetID == 0 {
-		http.Error(w, "Sheet ID is required", http.StatusBadRequest)
-		return
-	}
-
-	if len(req.Designs) == 0 {
-		http.Error(w, "At least one piece is required", http.StatusBadRequest)
-		return
-	}
-
-	// Get the selected sheet
-	sheets := []map[string]interface{}{
-		{
-			"id":            1,
-			"name":          "Standard 2m x 3m",
-			"width":         2000,
-			"height":        3000,
-			"thickness":     6,
-			"price_per_sqm": 45.50,
-			"in_stock":      15,
-		},
-		{
-			"id":            2,
-			"name":          "Large 2.5m x 3.5m",
-			"width":         2500,
-			"height":        3500,
-			"thickness":     6,
-			"price_per_sqm": 48.00,
-			"in_stock":      8,
-		},
-	}
-
-	var selectedSheet map[string]interface{}
-	for _, sheet := range sheets {
-		if int(sheet["id"].(int)) == req.SheetID {
-			selectedSheet = sheet
-			break
-		}
-	}
-
-	if selectedSheet == nil {
-		http.Error(w, "Sheet not found", http.StatusNotFound)
-		return
-	}
-
-	// Multi-sheet optimization algorithm
-	sheetWidth := float64(selectedSheet["width"].(int))
-	sheetHeight := float64(selectedSheet["height"].(int))
-	sheetArea := sheetWidth * sheetHeight
-	pricePerSqm := selectedSheet["price_per_sqm"].(float64)
-	sheetAreaSqm := sheetArea / 1000000.0 // Convert mm² to m²
-
-	// Calculate total pieces requested
-	totalPieces := 0
-	allPieces := []map[string]interface{}{}
-
-	// Expand pieces with quantities
-	for _, design := range req.Designs {
-		totalPieces += design.Quantity
-		for i := 0; i < design.Quantity; i++ {
-			allPieces = append(allPieces, map[string]interface{}{
-				"id":          fmt.Sprintf("piece_%d_%d", design.DesignID, i+1),
-				"design_id":   design.DesignID,
-				"design_name": design.Name,
-				"width":       design.Width,
-				"height":      design.Height,
-				"area":        design.Width * design.Height,
-			})
-		}
-	}
-
-	log.Printf("Starting multi-sheet optimization: %d total pieces requested", totalPieces)
-
-	// Sort pieces by area (largest first for better packing)
-	for i := 0; i < len(allPieces)-1; i++ {
-		for j := i + 1; j < len(allPieces); j++ {
-			if allPieces[i]["area"].(float64) < allPieces[j]["area"].(float64) {
-				allPieces[i], allPieces[j] = allPieces[j], allPieces[i]
-			}
-		}
-	}
-
-	// Multi-sheet placement
-	optimizationSheets := []map[string]interface{}{}
-	totalPlacedPieces := 0
-
-	for len(allPieces) > 0 {
-		sheetNum := len(optimizationSheets) + 1
-		log.Printf("Starting sheet %d with %d remaining pieces", sheetNum, len(allPieces))
-
-		// Initialize new sheet
-		currentSheet := map[string]interface{}{
-			"sheet_number": sheetNum,
-			"width":        sheetWidth,
-			"height":       sheetHeight,
-			"pieces":       []map[string]interface{}{},
-		}
-
-		// Track available rectangles for this sheet
-		availableRects := []map[string]interface{}{
-			{
-				"x":      req.Options.EdgeMargin,
-				"y":      req.Options.EdgeMargin,
-				"width":  sheetWidth - 2*req.Options.EdgeMargin,
-				"height": sheetHeight - 2*req.Options.EdgeMargin,
-			},
-		}
-
-		piecesPlacedThisSheet := 0
-
-		// Try to place pieces on current sheet
-		for i := 0; i < len(allPieces); i++ {
-			piece := allPieces[i]
-			pieceWidth := piece["width"].(float64)
-			pieceHeight := piece["height"].(float64)
-
-			placed := false
-
-			// Try both orientations if rotation is allowed
-			orientations := []map[string]float64{
-				{"width": pieceWidth, "height": pieceHeight, "rotation": 0},
-			}
-			if req.Options.AllowRotation {
-				orientations = append(orientations, map[string]float64{
-					"width": pieceHeight, "height": pieceWidth, "rotation": 90,
-				})
-			}
-
-			// Try each orientation and each available rectangle
-			for _, orientation := range orientations {
-				if placed {
-					break
-				}
-
-				w := orientation["width"]
-				h := orientation["height"]
-				rotation := orientation["rotation"]
-
-				for rectIdx, rect := range availableRects {
-					if w <= rect["width"].(float64) && h <= rect["height"].(float64) {
-						// Place the piece
-						placedPiece := map[string]interface{}{
-							"id":          piece["id"],
-							"design_id":   piece["design_id"],
-							"design_name": piece["design_name"],
-							"x":           rect["x"].(float64),
-							"y":           rect["y"].(float64),
-							"width":       w,
-							"height":      h,
-							"rotation":    rotation,
-							"flipped":     false,
-							"sheet":       sheetNum,
-						}
-
-						currentSheet["pieces"] = append(currentSheet["pieces"].([]map[string]interface{}), placedPiece)
-						piecesPlacedThisSheet++
-						totalPlacedPieces++
-						placed = true
-
-						// Update available rectangles
-						newRects := []map[string]interface{}{}
-
-						// Add rectangles to the right and below the placed piece
-						rightRect := map[string]interface{}{
-							"x":      rect["x"].(float64) + w + req.Options.MinimumGap,
-							"y":      rect["y"].(float64),
-							"width":  rect["width"].(float64) - w - req.Options.MinimumGap,
-							"height": h,
-						}
-						if rightRect["width"].(float64) > 0 {
-							newRects = append(newRects, rightRect)
-						}
-
-						belowRect := map[string]interface{}{
-							"x":      rect["x"].(float64),
-							"y":      rect["y"].(float64) + h + req.Options.MinimumGap,
-							"width":  rect["width"].(float64),
-							"height": rect["height"].(float64) - h - req.Options.MinimumGap,
-						}
-						if belowRect["height"].(float64) > 0 {
-							newRects = append(newRects, belowRect)
-						}
-
-						// Replace the used rectangle with new ones
-						availableRects = append(availableRects[:rectIdx], availableRects[rectIdx+1:]...)
-						availableRects = append(availableRects, newRects...)
-
-						// Remove placed piece from remaining pieces
-						allPieces = append(allPieces[:i], allPieces[i+1:]...)
-						i-- // Adjust index since we removed an element
-
-						log.Printf("Placed piece %s on sheet %d at (%.0f,%.0f) size %.0fx%.0f rotation=%.0f°",
-							piece["id"], sheetNum, rect["x"].(float64), rect["y"].(float64), w, h, rotation)
-
-						break
-					}
-				}
-			}
-		}
-
-		if piecesPlacedThisSheet > 0 {
-			optimizationSheets = append(optimizationSheets, currentSheet)
-			log.Printf("Sheet %d completed with %d pieces", sheetNum, piecesPlacedThisSheet)
-		} else {
-			// No more pieces can be placed
-			log.Printf("No more pieces can be placed. Stopping optimization.")
-			break
-		}
-	}
-
-	log.Printf("Optimization completed: %d pieces placed across %d sheets, %d pieces unplaced",
-		totalPlacedPieces, len(optimizationSheets), len(allPieces))
-
-	// Calculate overall statistics
-	totalUsedArea := 0.0
-	totalSheetArea := float64(len(optimizationSheets)) * sheetArea
-
-	for _, sheet := range optimizationSheets {
-		pieces := sheet["pieces"].([]map[string]interface{})
-		for _, piece := range pieces {
-			width := piece["width"].(float64)
-			height := piece["height"].(float64)
-			totalUsedArea += width * height
-		}
-	}
-
-	overallUtilization := 0.0
-	if totalSheetArea > 0 {
-		overallUtilization = (totalUsedArea / totalSheetArea) * 100
-	}
-
-	totalCost := float64(len(optimizationSheets)) * sheetAreaSqm * pricePerSqm
-
-	// Return first sheet for primary visualization, with overall statistics
-	firstSheet := map[string]interface{}{
-		"pieces": []map[string]interface{}{},
-	}
-	if len(optimizationSheets) > 0 {
-		firstSheet = optimizationSheets[0]
-	}
-
-	result := map[string]interface{}{
-		"optimization": map[string]interface{}{
-			"id":   1,
-			"name": req.Name,
-			"layout": map[string]interface{}{
-				"sheet_width":  sheetWidth,
-				"sheet_height": sheetHeight,
-				"pieces":       firstSheet["pieces"],
-				"statistics": map[string]interface{}{
-					"utilization_rate":    overallUtilization,
-					"waste_rate":          100 - overallUtilization,
-					"placed_pieces":       totalPlacedPieces,
-					"total_pieces":        totalPieces,
-					"unplaced_pieces":     len(allPieces),
-					"sheets_used":         len(optimizationSheets),
-					"total_sheet_area_m2": totalSheetArea / 1000000.0,
-					"used_area_m2":        totalUsedArea / 1000000.0,
-					"waste_area_m2":       (totalSheetArea - totalUsedArea) / 1000000.0,
-					"cutting_length":      0,
-					"cutting_time":        0,
-				},
-			},
-			"sheets": optimizationSheets,
-			"sheet_details": map[string]interface{}{
-				"sheet_type":     selectedSheet["name"],
-				"sheet_size":     fmt.Sprintf("%.0fx%.0fmm", sheetWidth, sheetHeight),
-				"price_per_m2":   pricePerSqm,
-				"cost_per_sheet": sheetAreaSqm * pricePerSqm,
-			},
-			"execution_time": 0.1,
-			"total_cost":     totalCost,
-		},
-	}
-
-	json.NewEncoder(w).Encode(result)
-}
-
 // Vitrari Authentication page handler
 func handleAuth(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
@@ -124,6 +124,15 @@ const translations = {
     bottomLeftFill: "Bottom-Left Fill",
     geneticAlgorithm: "Genetic Algorithm",
     greedyAlgorithm: "Greedy Algorithm",
+    guillotineAlgorithm: "Guillotine (Score & Break)",
+    maxRectsBssf: "MaxRects (Best Short Side)",
+    maxRectsBlsf: "MaxRects (Best Long Side)",
+    maxRectsBaf: "MaxRects (Best Area)",
+    maxRectsCp: "MaxRects (Contact Point)",
+    skylineAlgorithm: "Skyline",
+    nestingAlgorithm: "True-Shape Nesting",
+    exactAlgorithm: "Exact (Small Jobs)",
+    autoAlgorithm: "Auto (Best of All)",
     runOptimization: "Run Optimization",
     results: "Results",
     utilization: "Utilization",
@@ -285,6 +294,15 @@ const translations = {
     bottomLeftFill: "Llenado Inferior-Izquierdo",
     geneticAlgorithm: "Algoritmo Genético",
     greedyAlgorithm: "Algoritmo Codicioso",
+    guillotineAlgorithm: "Guillotina (Rayado y Corte)",
+    maxRectsBssf: "MaxRects (Mejor Lado Corto)",
+    maxRectsBlsf: "MaxRects (Mejor Lado Largo)",
+    maxRectsBaf: "MaxRects (Mejor Área)",
+    maxRectsCp: "MaxRects (Punto de Contacto)",
+    skylineAlgorithm: "Skyline",
+    nestingAlgorithm: "Anidado de Forma Real",
+    exactAlgorithm: "Exacto (Trabajos Pequeños)",
+    autoAlgorithm: "Automático (Mejor de Todos)",
     runOptimization: "Ejecutar Optimización",
     results: "Resultados",
     utilization: "Utilización",